@@ -11,10 +11,13 @@
 //   $> ng-gengo ./eval/testdata/defer1.ng
 //   $> ng-gengo ./eval/testdata/defer1.ng > defer1.go
 //   $> ng-gengo -pkg=main ./eval/testdata/defer1.ng
+//   $> ng-gengo -test -pkg=foo ./foo.ng > foo_test.go
 //
 //  options:
 //    -pkg string
 //      	name of the output Go package (default "main")
+//    -test
+//      	generate a Go _test.go file from TestXxx functions
 //
 package main
 
@@ -40,6 +43,7 @@ ex:
  $> ng-gengo ./eval/testdata/defer1.ng
  $> ng-gengo ./eval/testdata/defer1.ng > defer1.go
  $> ng-gengo -pkg=main ./eval/testdata/defer1.ng
+ $> ng-gengo -test -pkg=foo ./foo.ng > foo_test.go
 
 options:
 `,
@@ -48,6 +52,7 @@ options:
 	}
 
 	pkg := flag.String("pkg", "main", "name of the output Go package")
+	test := flag.Bool("test", false, "generate a Go _test.go file from TestXxx functions")
 
 	flag.Parse()
 
@@ -61,7 +66,11 @@ options:
 		log.Fatalf("missing path to Neugram script")
 	}
 
-	src, err := gengo.GenGo(flag.Arg(0), *pkg)
+	genGo := gengo.GenGo
+	if *test {
+		genGo = gengo.GenGoTest
+	}
+	src, err := genGo(flag.Arg(0), *pkg)
 	if err != nil {
 		log.Fatalf("could not generate Go package %s: %v", *pkg, err)
 	}
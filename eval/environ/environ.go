@@ -11,12 +11,16 @@ import (
 )
 
 type Environ struct {
-	mu sync.Mutex
-	m  map[string]string
+	mu       sync.Mutex
+	m        map[string]string
+	exported map[string]bool // shell variables passed to child processes
 }
 
 func New() *Environ {
-	return &Environ{m: make(map[string]string)}
+	return &Environ{
+		m:        make(map[string]string),
+		exported: make(map[string]bool),
+	}
 }
 
 func NewFrom(vals []string) *Environ {
@@ -38,12 +42,49 @@ func (e *Environ) Get(key string) string {
 	return v
 }
 
+// Lookup returns the value of key and whether it is set.
+func (e *Environ) Lookup(key string) (string, bool) {
+	e.mu.Lock()
+	v, ok := e.m[key]
+	e.mu.Unlock()
+	return v, ok
+}
+
+// Set sets the value of key.
 func (e *Environ) Set(key, value string) {
 	e.mu.Lock()
 	e.m[key] = value
 	e.mu.Unlock()
 }
 
+// Delete removes key.
+func (e *Environ) Delete(key string) {
+	e.mu.Lock()
+	delete(e.m, key)
+	delete(e.exported, key)
+	e.mu.Unlock()
+}
+
+// Export marks the shell variable key to be passed to child
+// processes with the value it has when they start.
+func (e *Environ) Export(key string) {
+	e.mu.Lock()
+	e.exported[key] = true
+	e.mu.Unlock()
+}
+
+// Exported returns the keys marked by Export.
+func (e *Environ) Exported() []string {
+	e.mu.Lock()
+	l := make([]string, 0, len(e.exported))
+	for k := range e.exported {
+		l = append(l, k)
+	}
+	e.mu.Unlock()
+	sort.Strings(l)
+	return l
+}
+
 func (e *Environ) List() []string {
 	e.mu.Lock()
 	l := make([]string, 0, len(e.m))
@@ -264,15 +264,18 @@ func (p *Program) evalFile() error {
 			j := &shell.Job{
 				State:  p.ShellState,
 				Cmd:    cmd,
+				Params: p,
 				Stdin:  os.Stdin,
 				Stdout: os.Stdout,
 				Stderr: os.Stderr,
+
+				Interrupt: p.sigint,
 			}
 			if err := j.Start(); err != nil {
 				return err
 			}
 			done, err := j.Wait()
-			if err != nil {
+			if err != nil && j.ErrExit() {
 				return err
 			}
 			if !done {
@@ -414,15 +417,21 @@ func (p *Program) Alias() *environ.Environ {
 
 // Get is part of the implementation of shell.Params.
 func (p *Program) Get(name string) string {
+	val, _ := p.Lookup(name)
+	return val
+}
+
+// Lookup is part of the implementation of shell.Lookuper.
+func (p *Program) Lookup(name string) (string, bool) {
 	v := p.Cur.Lookup(name)
 	if v == (reflect.Value{}) {
-		return p.Environ().Get(name)
+		return p.Environ().Lookup(name)
 	}
 	vi := v.Interface()
 	if s, ok := vi.(string); ok {
-		return s
+		return s, true
 	}
-	return fmt.Sprint(vi)
+	return fmt.Sprint(vi), true
 }
 
 // Set is part of the implementation of shell.Params.
@@ -518,10 +527,10 @@ func (p *Program) evalStmt(s stmt.Stmt) []reflect.Value {
 	p.mostRecentLabel = ""
 	switch s := s.(type) {
 	case *stmt.Const:
-		return p.evalConst(s)
+		return p.evalConst(s, 0)
 	case *stmt.ConstSet:
-		for _, v := range s.Consts {
-			p.evalConst(v)
+		for i, v := range s.Consts {
+			p.evalConst(v, i)
 		}
 		return nil
 	case *stmt.Var:
@@ -770,6 +779,37 @@ func (p *Program) evalStmt(s stmt.Stmt) []reflect.Value {
 		}
 		src := p.evalExprOne(s.Expr)
 		switch src.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n := reflect.New(src.Type()).Elem()
+		intLoop:
+			for ; lessThan(n, src); incr(n) {
+				if key != (reflect.Value{}) {
+					key.Set(n)
+				}
+				p.evalStmt(s.Body)
+				if p.interrupted() {
+					break
+				}
+				switch p.branchType {
+				default:
+					break intLoop
+				case brNone:
+				case brBreak:
+					if p.branchLabel == mostRecentLabel {
+						p.branchType = brNone
+						p.branchLabel = ""
+					}
+					break intLoop
+				case brContinue:
+					if p.branchLabel == mostRecentLabel {
+						p.branchType = brNone
+						p.branchLabel = ""
+						continue intLoop
+					}
+					break intLoop
+				}
+			}
 		case reflect.Array, reflect.Slice:
 			slen := src.Len()
 		sliceLoop:
@@ -892,17 +932,20 @@ func (p *Program) evalStmt(s stmt.Stmt) []reflect.Value {
 		return nil
 
 	case *stmt.Simple:
-		res := p.evalExpr(s.Expr)
 		if fn, isFunc := s.Expr.(*expr.FuncLiteral); isFunc && fn.Name != "" {
-			s := &Scope{
+			// Define the variable before evaluating the
+			// function, so the function may refer to itself.
+			v := reflect.New(p.reflector.ToRType(fn.Type)).Elem()
+			p.Cur = &Scope{
 				Parent:   p.Cur,
 				VarName:  fn.Name,
-				Var:      res[0],
+				Var:      v,
 				Implicit: true,
 			}
-			p.Cur = s
+			v.Set(p.evalFuncLiteral(fn, nil))
+			return []reflect.Value{v}
 		}
-		return res
+		return p.evalExpr(s.Expr)
 	case *stmt.Send:
 		ch := p.evalExprOne(s.Chan)
 		v := p.evalExprOne(s.Value)
@@ -914,7 +957,12 @@ func (p *Program) evalStmt(s stmt.Stmt) []reflect.Value {
 		}
 		return nil
 	case *stmt.TypeDecl:
-		if _, isIface := s.Type.Type.(*tipe.Interface); isIface {
+		if s.Alias {
+			return nil
+		}
+		// An interface with unions is only a type constraint,
+		// it has no values.
+		if iface, isIface := s.Type.Type.(*tipe.Interface); isIface && len(iface.Embeds) == 0 {
 			p.ifaceDecl(s.Type)
 		}
 		return nil
@@ -1135,9 +1183,18 @@ func (p *Program) evalExprOne(e expr.Expr) reflect.Value {
 	return v[0]
 }
 
-func (p *Program) evalConst(s *stmt.Const) []reflect.Value {
+// evalConst evaluates s, the iota'th constant specification
+// in its declaration.
+func (p *Program) evalConst(s *stmt.Const, iota int) []reflect.Value {
 	types := make([]tipe.Type, 0, len(s.NameList))
 	vals := make([]reflect.Value, 0, len(s.NameList))
+	outer := p.Cur
+	p.Cur = &Scope{
+		Parent:   p.Cur,
+		VarName:  "iota",
+		Var:      reflect.ValueOf(UntypedInt{big.NewInt(int64(iota))}),
+		Implicit: true,
+	}
 	for _, rhs := range s.Values {
 		v := p.evalExpr(rhs)
 		t := p.Types.Type(rhs)
@@ -1149,6 +1206,7 @@ func (p *Program) evalConst(s *stmt.Const) []reflect.Value {
 		// TODO: insert an implicit interface type conversion here
 		vals = append(vals, v...)
 	}
+	p.Cur = outer
 	if s.Type != nil {
 		types = make([]tipe.Type, len(s.NameList))
 		for i := range types {
@@ -1383,6 +1441,9 @@ func (p interpPanic) Error() string {
 
 func (p *Program) prepCall(e *expr.Call) (fn reflect.Value, args []reflect.Value) {
 	fn = p.evalExprOne(e.Func)
+	if sig := p.Types.Inferred(e); sig != nil {
+		fn = instantiateFunc(fn, p.reflector.ToRType(sig))
+	}
 	args = make([]reflect.Value, 0, len(e.Args))
 	i := 0
 	for _, arg := range e.Args {
@@ -1572,6 +1633,10 @@ func (p *Program) evalExpr(e expr.Expr) []reflect.Value {
 		panic(interpPanic{fmt.Errorf("eval: undefined identifier: %q", e.Name)})
 	case *expr.Index:
 		container := p.evalExprOne(e.Left)
+		if fn, isFunc := p.Types.Type(e.Left).(*tipe.Func); isFunc && len(fn.TypeParams) > 0 {
+			t := p.reflector.ToRType(p.Types.Type(e))
+			return []reflect.Value{instantiateFunc(container, t)}
+		}
 		if len(e.Indicies) != 1 {
 			panic(interpPanic{fmt.Errorf("eval: TODO table slicing")})
 		}
@@ -1591,6 +1656,22 @@ func (p *Program) evalExpr(e expr.Expr) []reflect.Value {
 			}
 			return []reflect.Value{container.Slice(i, j)}
 		}
+		if r, isRange := e.Indicies[0].(*expr.Range); isRange {
+			if container.Kind() == reflect.Ptr {
+				container = container.Elem() // pointer to array
+			}
+			i, j := 0, container.Len()
+			if r.Start != nil {
+				i = int(p.evalExprOne(r.Start).Int())
+			}
+			if r.End != nil {
+				j = int(p.evalExprOne(r.End).Int())
+				if r.Inclusive {
+					j++
+				}
+			}
+			return []reflect.Value{container.Slice(i, j)}
+		}
 		k := p.evalExprOne(e.Indicies[0])
 		if env, ok := container.Interface().(evalMap); ok {
 			return []reflect.Value{reflect.ValueOf(env.GetVal(k.String()))}
@@ -1648,11 +1729,14 @@ func (p *Program) evalExpr(e expr.Expr) []reflect.Value {
 				v = lhs.FieldByName(e.Right.Name)
 			}
 		}
+		if v == (reflect.Value{}) {
+			v = promotedMethod(lhs, e.Right.Name)
+		}
 		return []reflect.Value{v}
 	case *expr.Shell:
 		p.pushScope()
 		defer p.popScope()
-		res, err := shell.Run(p.ShellState, p, e)
+		res, err := shell.Run(p.ShellState, p, e, p.sigint)
 		str := reflect.ValueOf(res)
 		if e.ElideError {
 			// Dynamic elision of final error.
@@ -1673,9 +1757,18 @@ func (p *Program) evalExpr(e expr.Expr) []reflect.Value {
 	case *expr.SliceLiteral:
 		t := p.reflector.ToRType(e.Type)
 		return p.evalSliceLiteral(t, e.Keys, e.Values)
+	case *expr.TableLiteral:
+		return []reflect.Value{p.evalTableLiteral(e)}
+	case *expr.TableIndex:
+		return []reflect.Value{p.evalTableIndex(e)}
 	case *expr.Type:
 		t := p.reflector.ToRType(e.Type)
 		return []reflect.Value{reflect.ValueOf(t)}
+	case *expr.IfExpr:
+		if p.evalExprOne(e.Cond).Bool() {
+			return p.evalExpr(e.Then)
+		}
+		return p.evalExpr(e.Else)
 	case *expr.TypeAssert:
 		v := p.evalExprOne(e.Left)
 		if e.Type == nil {
@@ -1706,11 +1799,13 @@ func (p *Program) evalExpr(e expr.Expr) []reflect.Value {
 			panic(Panic{val: fmt.Errorf("value of type %s cannot be converted to %s", v.Type(), e.Type)})
 		}
 		return []reflect.Value{v}
+	case *expr.Paren:
+		v := p.evalExprOne(e.Expr)
+		t := p.reflector.ToRType(p.Types.Type(e))
+		return []reflect.Value{convert(v, t)}
 	case *expr.Unary:
 		var v reflect.Value
 		switch e.Op {
-		case token.LeftParen:
-			v = p.evalExprOne(e.Expr)
 		case token.Ref:
 			v = p.evalExprOne(e.Expr)
 			return []reflect.Value{v.Addr()}
@@ -1752,6 +1847,26 @@ func (p *Program) evalExpr(e expr.Expr) []reflect.Value {
 				panic(interpPanic{err})
 			}
 			v = reflect.ValueOf(res)
+		case token.Xor: // bitwise complement, ^x is m ^ x with all bits of m set
+			rhs := p.evalExprOne(e.Expr)
+			var lhs interface{}
+			if _, ok := rhs.Interface().(UntypedInt); ok {
+				lhs = UntypedInt{big.NewInt(-1)}
+			} else {
+				m := reflect.New(rhs.Type()).Elem()
+				switch m.Kind() {
+				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+					m.SetInt(-1)
+				default:
+					m.SetUint(^uint64(0))
+				}
+				lhs = m.Interface()
+			}
+			res, err := binOp(token.Xor, lhs, rhs.Interface())
+			if err != nil {
+				panic(interpPanic{err})
+			}
+			v = reflect.ValueOf(res)
 		case token.ChanOp:
 			ch := p.evalExprOne(e.Expr)
 			res, ok := ch.Recv()
@@ -1768,6 +1883,46 @@ func (p *Program) evalExpr(e expr.Expr) []reflect.Value {
 	panic(interpPanic{fmt.Errorf("TODO evalExpr(%s), %T", format.Expr(e), e)})
 }
 
+// promotedMethod finds the method name promoted from an embedded
+// field of the struct v. Embedded fields are searched breadth-first,
+// the type checker has already rejected ambiguous selectors.
+//
+// Structs built by reflect.StructOf do not carry the methods of
+// their embedded fields, so the search is done by hand.
+func promotedMethod(v reflect.Value, name string) reflect.Value {
+	current := []reflect.Value{v}
+	for len(current) > 0 {
+		var next []reflect.Value
+		for _, v := range current {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					continue
+				}
+				v = v.Elem()
+			}
+			if v.Kind() != reflect.Struct {
+				continue
+			}
+			for i := 0; i < v.NumField(); i++ {
+				if !v.Type().Field(i).Anonymous {
+					continue
+				}
+				f := v.Field(i)
+				m := f.MethodByName(name)
+				if m == (reflect.Value{}) && f.Kind() != reflect.Ptr && f.CanAddr() {
+					m = f.Addr().MethodByName(name)
+				}
+				if m != (reflect.Value{}) {
+					return m
+				}
+				next = append(next, f)
+			}
+		}
+		current = next
+	}
+	return reflect.Value{}
+}
+
 // setField sets a struct field to value.
 // It sets the value even if the field is unexported.
 func setField(field, value reflect.Value) {
@@ -1890,6 +2045,26 @@ func (p *Program) evalArrayLiteral(t reflect.Type, keys, values []expr.Expr) []r
 	return []reflect.Value{array}
 }
 
+// lessThan reports whether the integer x is less than y.
+func lessThan(x, y reflect.Value) bool {
+	switch x.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return x.Int() < y.Int()
+	default:
+		return x.Uint() < y.Uint()
+	}
+}
+
+// incr increments the integer x.
+func incr(x reflect.Value) {
+	switch x.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x.SetInt(x.Int() + 1)
+	default:
+		x.SetUint(x.Uint() + 1)
+	}
+}
+
 func (p *Program) evalSliceLiteral(t reflect.Type, keys, values []expr.Expr) []reflect.Value {
 	switch len(keys) {
 	case 0:
@@ -1918,6 +2093,76 @@ func (p *Program) evalSliceLiteral(t reflect.Type, keys, values []expr.Expr) []r
 	}
 }
 
+// evalTableLiteral evaluates a table literal. A table is represented
+// by a struct of its column names and rows, see reflector.toRType.
+func (p *Program) evalTableLiteral(e *expr.TableLiteral) reflect.Value {
+	t := p.reflector.ToRType(e.Type)
+	table := reflect.New(t).Elem()
+	cols := make([]string, len(e.ColNames))
+	for i, colName := range e.ColNames {
+		cols[i] = p.evalExprOne(colName).String()
+	}
+	table.Field(0).Set(reflect.ValueOf(cols))
+	rowsType := t.Field(1).Type
+	rows := reflect.MakeSlice(rowsType, len(e.Rows), len(e.Rows))
+	for i, row := range e.Rows {
+		rows.Index(i).Set(p.evalSliceLiteral(rowsType.Elem(), nil, row)[0])
+	}
+	table.Field(1).Set(rows)
+	return table
+}
+
+// evalTableIndex evaluates x["C1"|"C2", Rows], a new table of the
+// named columns of the selected rows of x.
+func (p *Program) evalTableIndex(e *expr.TableIndex) reflect.Value {
+	table := p.evalExprOne(e.Expr)
+	cols := table.Field(0).Interface().([]string)
+	colIndex := make([]int, len(e.ColNames))
+	for i, name := range e.ColNames {
+		colIndex[i] = -1
+		for j, col := range cols {
+			if col == name {
+				colIndex[i] = j
+				break
+			}
+		}
+		if colIndex[i] == -1 {
+			panic(Panic{val: fmt.Errorf("unknown column %q", name)})
+		}
+	}
+
+	rows := table.Field(1)
+	i, j := 0, rows.Len()
+	if e.Rows.Exact != nil {
+		i = int(p.evalExprOne(e.Rows.Exact).Int())
+		j = i + 1
+	}
+	if e.Rows.Start != nil {
+		i = int(p.evalExprOne(e.Rows.Start).Int())
+	}
+	if e.Rows.End != nil {
+		j = int(p.evalExprOne(e.Rows.End).Int())
+		if e.Rows.Inclusive {
+			j++
+		}
+	}
+	rows = rows.Slice(i, j)
+
+	res := reflect.New(table.Type()).Elem()
+	res.Field(0).Set(reflect.ValueOf(append([]string(nil), e.ColNames...)))
+	resRows := reflect.MakeSlice(rows.Type(), rows.Len(), rows.Len())
+	for r := 0; r < rows.Len(); r++ {
+		row := rows.Index(r)
+		resRow := reflect.MakeSlice(row.Type(), len(colIndex), len(colIndex))
+		for k, c := range colIndex {
+			resRow.Index(k).Set(row.Index(c))
+		}
+		resRows.Index(r).Set(resRow)
+	}
+	res.Field(1).Set(resRows)
+	return res
+}
+
 type reflector struct {
 	mu  sync.RWMutex
 	fwd map[tipe.Type]reflect.Type
@@ -2072,7 +2317,12 @@ func (r *reflector) toRType(t tipe.Type) reflect.Type {
 		rtype = reflect.SliceOf(r.toRType(t.Elem))
 	case *tipe.Ellipsis:
 		rtype = reflect.SliceOf(r.toRType(t.Elem))
-	// TODO case *Table:
+	case *tipe.Table:
+		// A table is its column names and its rows.
+		rtype = reflect.StructOf([]reflect.StructField{
+			{Name: "Cols", Type: reflect.TypeOf([]string(nil))},
+			{Name: "Rows", Type: reflect.SliceOf(reflect.SliceOf(r.toRType(t.Type)))},
+		})
 	case *tipe.Pointer:
 		rtype = reflect.PtrTo(r.toRType(t.Elem))
 	case *tipe.Chan:
@@ -2102,6 +2352,116 @@ func (r *reflector) toRType(t tipe.Type) reflect.Type {
 	return rtype
 }
 
+// instantiateFunc adapts the generic function fn, which holds the
+// values of its type parameters as interface{}, to the instantiated
+// function type t.
+func instantiateFunc(fn reflect.Value, t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+		return callErased(fn, t.IsVariadic(), args, t)
+	})
+}
+
+// callErased calls fn with args converted to its parameter types,
+// and returns its results converted to the results of type t.
+// If variadic is set the final argument is a slice of the
+// variadic parameters.
+func callErased(fn reflect.Value, variadic bool, args []reflect.Value, t reflect.Type) []reflect.Value {
+	ft := fn.Type()
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		argt := ft.In(i)
+		if i == ft.NumIn()-1 && ft.IsVariadic() && !variadic {
+			argt = argt.Elem()
+		}
+		in[i] = convertErased(arg, argt)
+	}
+	var res []reflect.Value
+	if variadic {
+		res = fn.CallSlice(in)
+	} else {
+		res = fn.Call(in)
+	}
+	for i, v := range res {
+		res[i] = convertErased(v, t.Out(i))
+	}
+	return res
+}
+
+// convertErased converts v to t, where one of them is the other
+// with some types replaced by interface{}, as the type parameters
+// of a generic function are. Slices, arrays, maps and pointers are
+// copied, so a generic function does not modify the caller's values
+// through them.
+func convertErased(v reflect.Value, t reflect.Type) reflect.Value {
+	if v.Type() == t {
+		return v
+	}
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Zero(t)
+		}
+		v = v.Elem()
+		if v.Type() == t {
+			return v
+		}
+	}
+	if t.Kind() == reflect.Interface {
+		if !v.Type().AssignableTo(t) {
+			panic(interpPanic{fmt.Errorf("eval: cannot use %s as %s", v.Type(), t)})
+		}
+		res := reflect.New(t).Elem()
+		res.Set(v)
+		return res
+	}
+	if v.Kind() != t.Kind() {
+		panic(interpPanic{fmt.Errorf("eval: cannot use %s as %s", v.Type(), t)})
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(t)
+		}
+		res := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(convertErased(v.Index(i), t.Elem()))
+		}
+		return res
+	case reflect.Array:
+		res := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(convertErased(v.Index(i), t.Elem()))
+		}
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(t)
+		}
+		res := reflect.MakeMapWithSize(t, v.Len())
+		for _, k := range v.MapKeys() {
+			res.SetMapIndex(convertErased(k, t.Key()), convertErased(v.MapIndex(k), t.Elem()))
+		}
+		return res
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(t)
+		}
+		res := reflect.New(t.Elem())
+		res.Elem().Set(convertErased(v.Elem(), t.Elem()))
+		return res
+	case reflect.Func:
+		if v.IsNil() {
+			return reflect.Zero(t)
+		}
+		return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+			return callErased(v, t.IsVariadic(), args, t)
+		})
+	}
+	if !v.Type().AssignableTo(t) {
+		panic(interpPanic{fmt.Errorf("eval: cannot use %s as %s", v.Type(), t)})
+	}
+	return v
+}
+
 func (r *reflector) FromRType(rtype reflect.Type) tipe.Type {
 	panic("TODO FromRType")
 }
@@ -234,3 +234,42 @@ func TestPrograms(t *testing.T) {
 		})
 	}
 }
+
+func TestShellXTrace(t *testing.T) {
+	stderr, err := ioutil.TempFile("", "xtrace.stderr.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stderr.Name())
+	origStderr := os.Stderr
+	os.Stderr = stderr
+	defer func() { os.Stderr = origStderr }()
+
+	shellState := &shell.State{
+		Env:   environ.NewFrom(os.Environ()),
+		Alias: environ.New(),
+	}
+	p := New("xtrace", shellState)
+	if _, err := p.Eval(mustParse(`x := $$ set -x; echo -n "a b" | Y="it's" tr a c 2>/dev/null $$`), nil); err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = origStderr
+	if err := stderr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := p.Eval(mustParse("x"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res[0].Interface(), "c b"; got != want {
+		t.Errorf("x=%q, want %q", got, want)
+	}
+	b, err := ioutil.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "+ echo -n 'a b'\n+ Y='it'\\''s' tr a c 2>/dev/null\n"; got != want {
+		t.Errorf("trace=%q, want %q", got, want)
+	}
+}
@@ -866,7 +866,7 @@ func binOp(op token.Token, x, y interface{}) (interface{}, error) {
 			res := xv.MethodByName("Or").Call([]reflect.Value{yv})
 			return res[0].Interface(), nil
 		}
-	case token.Pow:
+	case token.Xor:
 		switch x := x.(type) {
 		case int:
 			switch y := y.(type) {
@@ -0,0 +1,134 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"neugram.io/ng/format"
+	"neugram.io/ng/syntax/expr"
+	"neugram.io/ng/syntax/token"
+)
+
+// arith evaluates the expression of an arithmetic expansion in
+// integer arithmetic. Identifiers refer to shell parameters, which
+// must hold integers. An unset or empty parameter is 0.
+func (p substParams) arith(e expr.Expr) (int64, error) {
+	switch e := e.(type) {
+	case *expr.BasicLiteral:
+		if v, ok := e.Value.(*big.Int); ok && v.IsInt64() {
+			return v.Int64(), nil
+		}
+	case *expr.Ident:
+		val, ok := p.Lookup(e.Name)
+		if !ok && p.j.State.option('u') {
+			return 0, fmt.Errorf("unbound variable: %s", e.Name)
+		}
+		val = strings.TrimSpace(val)
+		if val == "" {
+			return 0, nil
+		}
+		v, err := strconv.ParseInt(val, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s: invalid arithmetic operand %q", e.Name, val)
+		}
+		return v, nil
+	case *expr.Paren:
+		return p.arith(e.Expr)
+	case *expr.Unary:
+		x, err := p.arith(e.Expr)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case token.Add:
+			return x, nil
+		case token.Sub:
+			return -x, nil
+		case token.Xor:
+			return ^x, nil
+		case token.Not:
+			return boolArith(x == 0), nil
+		}
+	case *expr.Binary:
+		x, err := p.arith(e.Left)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case token.LogicalAnd:
+			if x == 0 {
+				return 0, nil
+			}
+		case token.LogicalOr:
+			if x != 0 {
+				return 1, nil
+			}
+		}
+		y, err := p.arith(e.Right)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case token.Add:
+			return x + y, nil
+		case token.Sub:
+			return x - y, nil
+		case token.Mul:
+			return x * y, nil
+		case token.Div, token.Rem:
+			if y == 0 {
+				return 0, errors.New("division by zero")
+			}
+			if e.Op == token.Div {
+				return x / y, nil
+			}
+			return x % y, nil
+		case token.TwoLess, token.TwoGreater:
+			if y < 0 {
+				return 0, fmt.Errorf("negative shift count %d", y)
+			}
+			if e.Op == token.TwoLess {
+				return x << uint64(y), nil
+			}
+			return x >> uint64(y), nil
+		case token.Ref:
+			return x & y, nil
+		case token.Pipe:
+			return x | y, nil
+		case token.Xor:
+			return x ^ y, nil
+		case token.RefPow:
+			return x &^ y, nil
+		case token.Equal:
+			return boolArith(x == y), nil
+		case token.NotEqual:
+			return boolArith(x != y), nil
+		case token.Less:
+			return boolArith(x < y), nil
+		case token.LessEqual:
+			return boolArith(x <= y), nil
+		case token.Greater:
+			return boolArith(x > y), nil
+		case token.GreaterEqual:
+			return boolArith(x >= y), nil
+		case token.LogicalAnd, token.LogicalOr:
+			return boolArith(y != 0), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid arithmetic expression: %s", format.Expr(e))
+}
+
+// boolArith converts a truth value to 1 or 0, as in C.
+func boolArith(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -5,6 +5,7 @@
 package shell
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -27,6 +28,31 @@ type State struct {
 	Env   *environ.Environ
 	Alias *environ.Environ
 
+	// mu guards the options, ExitStatus and funcs, which are used
+	// by the jobs of every goroutine running shell commands. The
+	// options and ExitStatus may be set directly only before the
+	// State is in use.
+	mu sync.Mutex
+
+	// NoErrExit turns off the default set -e behavior, under which
+	// a failing command stops the commands that follow it.
+	// It is set by "set +e" and cleared by "set -e".
+	NoErrExit bool
+
+	// NoUnset makes the expansion of an unset parameter an error.
+	// It is set by "set -u" and cleared by "set +u".
+	NoUnset bool
+
+	// XTrace prints each command to stderr before it is run.
+	// It is set by "set -x" and cleared by "set +x".
+	XTrace bool
+
+	// ExitStatus is the exit status of the most recently run
+	// pipeline, the value of $?.
+	ExitStatus int
+
+	funcs map[string]*expr.ShellFuncDef // shell functions by name
+
 	bgMu sync.Mutex
 	bg   []*Job
 }
@@ -48,6 +74,10 @@ type Job struct {
 	Stderr *os.File
 	Params Params
 
+	// Interrupt, if non-nil, delivers signals that are forwarded
+	// to the process group of the running job while waiting on it.
+	Interrupt <-chan os.Signal
+
 	mu      sync.Mutex
 	err     error
 	pgid    int
@@ -55,6 +85,7 @@ type Job struct {
 	cond    sync.Cond
 	done    bool
 	running bool
+	errexit bool
 }
 
 func (j *Job) Start() (err error) {
@@ -113,12 +144,51 @@ func (j *Job) Continue() error {
 	return err
 }
 
+// ErrExit reports whether the job stopped early, either because
+// a command failed under set -e or because it was interrupted.
+// A script running the job should stop too.
+func (j *Job) ErrExit() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.errexit
+}
+
+// Signal sends sig to the process group of the running job.
+// It does nothing if no process of the job is running.
+func (j *Job) Signal(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal: %v", sig)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.pgid == 0 {
+		return nil
+	}
+	return syscall.Kill(-j.pgid, s)
+}
+
 func shellListString(cmd *expr.ShellList) string {
 	return format.Expr(cmd)
 }
 
 // Wait waits until the job is stopped or complete.
 func (j *Job) Wait() (done bool, err error) {
+	if j.Interrupt != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			for {
+				select {
+				case sig := <-j.Interrupt:
+					j.Signal(sig)
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
@@ -170,36 +240,78 @@ type stdio struct {
 	err *os.File
 }
 
-func (j *Job) execShellList(cmd *expr.ShellList, sio stdio) error {
+// closeExcept closes the files of s that are not in keep,
+// such as those opened by a redirection.
+func (s stdio) closeExcept(keep stdio) {
+	for _, f := range []*os.File{s.in, s.out, s.err} {
+		if f != keep.in && f != keep.out && f != keep.err {
+			f.Close()
+		}
+	}
+}
+
+func (j *Job) execShellList(cmd *expr.ShellList, sio stdio) (err error) {
 	for _, andor := range cmd.AndOr {
-		if err := j.execShellAndOr(andor, sio); err != nil {
+		var errexit bool
+		errexit, err = j.execShellAndOr(andor, sio)
+		if err == nil {
+			continue
+		}
+		_, interrupted := err.(signalError)
+		if interrupted || errexit && j.State.option('e') {
+			j.mu.Lock()
+			j.errexit = true
+			j.mu.Unlock()
 			return err
 		}
 	}
-	return nil
+	return err
 }
 
-func (j *Job) execShellAndOr(andor *expr.ShellAndOr, sio stdio) error {
+// execShellAndOr runs the pipelines of andor. The errexit result
+// reports whether err is subject to set -e: a failure of any
+// pipeline but the last one is tested by && or ||, and does not
+// stop the shell.
+func (j *Job) execShellAndOr(andor *expr.ShellAndOr, sio stdio) (errexit bool, err error) {
 	for i, p := range andor.Pipeline {
 		err := j.execPipeline(p, sio)
+		j.State.setExitStatus(exitStatus(err))
+		if _, interrupted := err.(signalError); interrupted {
+			return true, err
+		}
 		if i < len(andor.Pipeline)-1 {
 			switch andor.Sep[i] {
 			case token.LogicalAnd:
 				if err != nil {
-					return err
+					return false, err
 				}
 			case token.LogicalOr:
 				if err == nil {
-					return nil
+					return false, nil
 				}
 			default:
 				panic("unknown AndOr separator: " + andor.Sep[i].String())
 			}
 		} else if err != nil {
-			return err
+			return true, err
 		}
 	}
-	return nil
+	return false, nil
+}
+
+// exitStatus converts the result of a pipeline to its exit status.
+// Following other shells, a process killed by a signal has the
+// status 128 plus the signal number.
+func exitStatus(err error) int {
+	switch err := err.(type) {
+	case nil:
+		return 0
+	case exitError:
+		return err.code
+	case signalError:
+		return 128 + int(err.sig)
+	}
+	return 1
 }
 
 func (j *Job) execPipeline(plcmd *expr.ShellPipeline, sio stdio) (err error) {
@@ -252,18 +364,22 @@ func (j *Job) execPipeline(plcmd *expr.ShellPipeline, sio stdio) (err error) {
 	for i := range sios {
 		sios[i].err = sio.err
 	}
-	defer func() {
-		if err != nil {
-			for i := 0; i < len(sios)-1; i++ {
-				if sios[i].out != nil {
-					sios[i].out.Close()
-				}
-				if sios[i+1].in != nil {
-					sios[i+1].in.Close()
-				}
+	// closePipes closes this process's ends of the pipes between
+	// the stages. Once the stages are started they hold their own
+	// copies, and ours must be closed so a stage sees EOF when the
+	// stage writing to it exits, and is sent SIGPIPE when the stage
+	// reading from it exits.
+	closePipes := func() {
+		for i := 0; i < len(sios)-1; i++ {
+			if sios[i].out != nil {
+				sios[i].out.Close()
+			}
+			if sios[i+1].in != nil {
+				sios[i+1].in.Close()
 			}
 		}
-	}()
+	}
+	defer closePipes()
 	for i := 0; i < len(sios)-1; i++ {
 		r, w, err := os.Pipe()
 		if err != nil {
@@ -279,16 +395,27 @@ func (j *Job) execPipeline(plcmd *expr.ShellPipeline, sio stdio) (err error) {
 		if cmd.Subshell != nil {
 			return fmt.Errorf("missing subshell support") // TODO
 		}
+		if cmd.FuncDef != nil {
+			j.State.defineFunc(cmd.FuncDef)
+			continue
+		}
 		p, err := j.setupSimpleCmd(cmd.SimpleCmd, sios[i])
 		if err != nil {
 			return err
 		}
 		if p != nil {
+			if cmd.PipeStderr {
+				// As with 2>&1, after the command's own
+				// redirections.
+				p.sio.err = p.sio.out
+			}
 			pl.proc = append(pl.proc, p)
 		}
 	}
 	if len(pl.proc) > 0 {
-		if err := pl.start(); err != nil {
+		err := pl.start()
+		closePipes()
+		if err != nil {
 			return err
 		}
 		if err := pl.waitUntilDone(); err != nil {
@@ -301,42 +428,55 @@ func (j *Job) execPipeline(plcmd *expr.ShellPipeline, sio stdio) (err error) {
 func (j *Job) setupSimpleCmd(cmd *expr.ShellSimpleCmd, sio stdio) (*proc, error) {
 	if len(cmd.Args) == 0 {
 		for _, v := range cmd.Assign {
-			j.Params.Set(v.Key, v.Value)
+			val, err := shell.ExpandAssign(v.Value, j.expandParams(cmd))
+			if err != nil {
+				return nil, err
+			}
+			if j.State.option('x') {
+				xtrace(sio.err, []string{v.Key + "=" + val}, nil, nil)
+			}
+			j.Params.Set(v.Key, val)
 		}
 		return nil, nil
 	}
-	argv, err := shell.Expansion(cmd.Args, j.Params)
+	argv, err := shell.Expansion(cmd.Args, j.expandParams(cmd))
 	if err != nil {
 		return nil, err
 	}
-	if a := j.State.Alias.Get(argv[0]); a != "" {
-		// TODO: This is entirely wrong. The alias string needs to be
-		// parsed like a typical shell command. That is:
-		//	alias["gsm"] = `go build "-ldflags=-w -s"`
-		// should be three args, not four.
-		aliasArgs := strings.Split(a, " ")
-		argv = append(aliasArgs, argv[1:]...)
+	argv, err = j.expandAlias(argv, j.expandParams(cmd))
+	if err != nil {
+		return nil, err
 	}
-	switch argv[0] {
-	case "cd":
-		dir := ""
-		if len(argv) == 1 {
-			dir = j.State.Env.Get("HOME")
-		} else {
-			dir = argv[1]
-		}
-		wd := ""
-		if filepath.IsAbs(dir) {
-			wd = filepath.Clean(dir)
-		} else {
-			wd = filepath.Join(j.State.Env.Get("PWD"), dir)
-		}
-		if err := os.Chdir(wd); err != nil {
+	if len(argv) == 0 {
+		return nil, nil
+	}
+	var assign []string
+	for _, kv := range cmd.Assign {
+		val, err := shell.ExpandAssign(kv.Value, j.expandParams(cmd))
+		if err != nil {
 			return nil, err
 		}
-		j.State.Env.Set("PWD", wd)
-		fmt.Fprintf(os.Stdout, "%s\n", wd)
-		return nil, nil
+		assign = append(assign, kv.Key+"="+val)
+	}
+	if j.State.option('x') {
+		xtrace(sio.err, assign, argv, cmd.Redirect)
+	}
+	if fn := j.State.lookupFunc(argv[0]); fn != nil {
+		sio, err = j.redirect(cmd, sio)
+		if err != nil {
+			return nil, err
+		}
+		p := &proc{
+			job:  j,
+			argv: argv,
+			sio:  sio,
+			fn:   fn,
+		}
+		return p, nil
+	}
+	switch argv[0] {
+	case "cd":
+		return nil, j.cd(argv[1:])
 	case "fg":
 		return nil, j.State.bgFg(strings.Join(argv[1:], " "))
 	case "jobs":
@@ -344,17 +484,37 @@ func (j *Job) setupSimpleCmd(cmd *expr.ShellSimpleCmd, sio stdio) (*proc, error)
 		return nil, nil
 	case "export":
 		return nil, j.export(argv[1:])
+	case "set":
+		return nil, j.State.set(argv[1:])
+	case "alias":
+		return nil, j.State.alias(argv[1:], sio.out)
+	case "unalias":
+		return nil, j.State.unalias(argv[1:])
+	case "test", "[":
+		return nil, test(argv)
+	case "read":
+		// Its input may be written by an earlier stage of the
+		// pipeline, so read runs as a stage of its own.
+		sio, err = j.redirect(cmd, sio)
+		if err != nil {
+			return nil, err
+		}
+		p := &proc{
+			job:  j,
+			argv: argv,
+			sio:  sio,
+			builtin: func(sio stdio) error {
+				return j.read(argv[1:], sio.in)
+			},
+		}
+		return p, nil
 	case "exit", "logout":
 		return nil, fmt.Errorf("ng does not know %q, try $$", argv[0])
 	}
-	env := j.State.Env.List()
-	if len(cmd.Assign) != 0 {
-		baseEnv := env
-		env = make([]string, 0, len(cmd.Assign)+len(baseEnv))
-		for _, kv := range cmd.Assign {
-			env = append(env, kv.Key+"="+kv.Value)
-		}
-		env = append(env, baseEnv...)
+	env := j.environ(assign)
+	sio, err = j.redirect(cmd, sio)
+	if err != nil {
+		return nil, err
 	}
 	p := &proc{
 		job:  j,
@@ -362,9 +522,41 @@ func (j *Job) setupSimpleCmd(cmd *expr.ShellSimpleCmd, sio stdio) (*proc, error)
 		sio:  sio,
 		env:  env,
 	}
+	return p, nil
+}
+
+// funcParams are the parameters of a shell function call. They
+// extend the caller's parameters with the positional parameters
+// $1, $2, ..., and their number, $#.
+type funcParams struct {
+	Params
+	args []string
+}
+
+func (p funcParams) Get(name string) string {
+	val, _ := p.Lookup(name)
+	return val
+}
+
+// Lookup is part of the implementation of shell.Lookuper.
+func (p funcParams) Lookup(name string) (string, bool) {
+	if name == "#" {
+		return strconv.Itoa(len(p.args)), true
+	}
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		if n > len(p.args) {
+			return "", false
+		}
+		return p.args[n-1], true
+	}
+	return lookupParam(p.Params, name)
+}
+
+// redirect returns sio with the redirections of cmd applied.
+func (j *Job) redirect(cmd *expr.ShellSimpleCmd, sio stdio) (stdio, error) {
 	for _, r := range cmd.Redirect {
 		switch r.Token {
-		case token.Greater, token.TwoGreater, token.AndGreater:
+		case token.Greater, token.TwoGreater, token.AndGreater, token.AndTwoGreater:
 			flag := os.O_RDWR | os.O_CREATE
 			if r.Token == token.Greater || r.Token == token.AndGreater {
 				flag |= os.O_TRUNC
@@ -373,41 +565,175 @@ func (j *Job) setupSimpleCmd(cmd *expr.ShellSimpleCmd, sio stdio) (*proc, error)
 			}
 			f, err := os.OpenFile(r.Filename, flag, 0666)
 			if err != nil {
-				return nil, err
+				return sio, err
 			}
-			if r.Token == token.AndGreater {
-				p.sio.out = f
-				p.sio.err = f
+			if r.Token == token.AndGreater || r.Token == token.AndTwoGreater {
+				sio.out = f
+				sio.err = f
 			} else if r.Number == nil || *r.Number == 1 {
-				p.sio.out = f
+				sio.out = f
 			} else if *r.Number == 2 {
-				p.sio.err = f
+				sio.err = f
 			}
 		case token.GreaterAnd:
 			dstnum, err := strconv.Atoi(r.Filename)
 			if err != nil {
-				return nil, fmt.Errorf("bad redirect target: %q", r.Filename)
+				return sio, fmt.Errorf("bad redirect target: %q", r.Filename)
 			}
 			var dst *os.File
 			switch dstnum {
 			case 1:
-				dst = p.sio.out
+				dst = sio.out
 			case 2:
-				dst = p.sio.err
+				dst = sio.err
 			}
 			switch *r.Number {
 			case 1:
-				p.sio.out = dst
+				sio.out = dst
 			case 2:
-				p.sio.err = dst
+				sio.err = dst
+			}
+		case token.TwoLess:
+			// The body is expanded, unless the delimiter is quoted.
+			body := r.Heredoc
+			if shell.HeredocExpanded(r.Filename) {
+				var err error
+				body, err = shell.ExpandHeredoc(body, j.expandParams(cmd))
+				if err != nil {
+					return sio, err
+				}
+			}
+			f, err := stdinString(body)
+			if err != nil {
+				return sio, err
 			}
+			sio.in = f
+		case token.ThreeLess:
+			// Like an assignment, a here-string is not word split.
+			word, err := shell.ExpandAssign(r.Filename, j.expandParams(cmd))
+			if err != nil {
+				return sio, err
+			}
+			f, err := stdinString(word + "\n")
+			if err != nil {
+				return sio, err
+			}
+			sio.in = f
 		case token.Less:
-			return nil, fmt.Errorf("TODO: %s", r.Token)
+			return sio, fmt.Errorf("TODO: %s", r.Token)
 		default:
-			return nil, fmt.Errorf("unknown shell redirect %s", r.Token)
+			return sio, fmt.Errorf("unknown shell redirect %s", r.Token)
 		}
 	}
-	return p, nil
+	return sio, nil
+}
+
+// stdinString returns a file from which s can be read,
+// for use as the stdin of a process.
+func stdinString(s string) (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		io.WriteString(w, s)
+		w.Close()
+	}()
+	return r, nil
+}
+
+// xtrace writes a command to w as it is about to be run,
+// for the shell option set -x.
+func xtrace(w io.Writer, assign, argv []string, redirect []*expr.ShellRedirect) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('+')
+	for _, kv := range assign {
+		i := strings.Index(kv, "=")
+		buf.WriteByte(' ')
+		buf.WriteString(kv[:i+1])
+		buf.WriteString(shell.QuoteArg(kv[i+1:]))
+	}
+	for _, arg := range argv {
+		buf.WriteByte(' ')
+		buf.WriteString(shell.QuoteArg(arg))
+	}
+	for _, r := range redirect {
+		buf.WriteByte(' ')
+		if r.Number != nil {
+			fmt.Fprintf(buf, "%d", *r.Number)
+		}
+		buf.WriteString(r.Token.String())
+		buf.WriteString(r.Filename)
+	}
+	buf.WriteByte('\n')
+	w.Write(buf.Bytes())
+}
+
+// expandParams returns the parameters used to expand the words
+// of cmd, following the shell options of j.State.
+func (j *Job) expandParams(cmd *expr.ShellSimpleCmd) shell.Params {
+	params := substParams{Params: j.Params, j: j, cmd: cmd}
+	if j.State.option('u') {
+		return shell.NoUnset(params)
+	}
+	return params
+}
+
+// substParams are the parameters of a command. They extend the
+// job's parameters with the exit status $?, and with the command
+// substitutions, $(cmd), and arithmetic expansions, $((expr)),
+// of the command's words.
+type substParams struct {
+	Params
+	j   *Job
+	cmd *expr.ShellSimpleCmd
+}
+
+func (p substParams) Get(name string) string {
+	if name == "?" {
+		return strconv.Itoa(p.j.State.exitStatus())
+	}
+	return p.Params.Get(name)
+}
+
+func (p substParams) Lookup(name string) (string, bool) {
+	if name == "?" {
+		return p.Get(name), true
+	}
+	return lookupParam(p.Params, name)
+}
+
+// CmdSubst runs the command of the substitution src and returns
+// its standard output, less any trailing newlines.
+func (p substParams) CmdSubst(src string) (string, error) {
+	for _, sub := range p.cmd.CmdSubst {
+		if sub.Src != src {
+			continue
+		}
+		e := &expr.Shell{
+			Cmds:    []*expr.ShellList{sub.Cmd},
+			TrapOut: true,
+		}
+		out, err := Run(p.j.State, p.j.Params, e, p.j.Interrupt)
+		return strings.TrimRight(out, "\n"), err
+	}
+	return "", fmt.Errorf("unknown command substitution: %s", src)
+}
+
+// ArithExpand evaluates the expression of the arithmetic expansion
+// src and returns its value in decimal.
+func (p substParams) ArithExpand(src string) (string, error) {
+	for _, arith := range p.cmd.Arith {
+		if arith.Src != src {
+			continue
+		}
+		v, err := p.arith(arith.Expr)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(v, 10), nil
+	}
+	return "", fmt.Errorf("unknown arithmetic expansion: %s", src)
 }
 
 func startPgidLeader() (*os.Process, error) {
@@ -440,6 +766,9 @@ func (pl *pipeline) start() (err error) {
 	defer pl.job.mu.Unlock()
 
 	for _, p := range pl.proc {
+		if p.inProcess() {
+			continue
+		}
 		p.path, err = findExecInPath(p.argv[0], pl.job.State.Env)
 		if err != nil {
 			return err
@@ -457,6 +786,12 @@ func (pl *pipeline) start() (err error) {
 		}
 	}()
 	for i, p := range pl.proc {
+		if p.inProcess() {
+			if err := p.startFunc(); err != nil {
+				return err
+			}
+			continue
+		}
 		attr := &os.ProcAttr{
 			Env:   p.env,
 			Files: []*os.File{p.sio.in, p.sio.out, p.sio.err},
@@ -506,14 +841,30 @@ type exitError struct {
 
 func (err exitError) Error() string { return fmt.Sprintf("exit code: %d", err.code) }
 
+type signalError struct {
+	sig syscall.Signal
+}
+
+func (err signalError) Error() string { return fmt.Sprintf("signal: %v", err.sig) }
+
 func (p *proc) waitUntilDone() error {
+	if p.inProcess() {
+		err := <-p.fnDone
+		if p.sio.in != p.job.Stdin {
+			p.sio.in.Close()
+		}
+		if p.sio.out != p.job.Stdout {
+			p.sio.out.Close()
+		}
+		return err
+	}
 	pid := p.process.Pid
 	//pid := pl.job.pgid
 	for {
 		wstatus := new(syscall.WaitStatus)
 		_, err := syscall.Wait4(pid, wstatus, syscall.WUNTRACED|syscall.WCONTINUED, nil)
 		switch {
-		case err != nil || wstatus.Exited():
+		case err != nil || wstatus.Exited() || wstatus.Signaled():
 			// TODO: should we close these right after the process forks?
 			if p.sio.in != p.job.Stdin {
 				p.sio.in.Close()
@@ -521,7 +872,13 @@ func (p *proc) waitUntilDone() error {
 			if p.sio.out != p.job.Stdout {
 				p.sio.out.Close()
 			}
+			// The process is reaped, release any resources
+			// os.StartProcess holds for it, such as a pidfd.
+			p.process.Release()
 			//fmt.Fprintf(os.Stderr, "process exited with %v\n", err)
+			if wstatus.Signaled() {
+				return signalError{sig: wstatus.Signal()}
+			}
 			if c := wstatus.ExitStatus(); c != 0 {
 				return exitError{code: c}
 			}
@@ -533,8 +890,6 @@ func (p *proc) waitUntilDone() error {
 			p.job.cond.L.Unlock()
 		case wstatus.Continued():
 			// BUG: on darwin at least, this isn't firing.
-		case wstatus.Signaled():
-			// ignore
 		default:
 			panic(fmt.Sprintf("unexpected wstatus: %#+v", wstatus))
 		}
@@ -548,6 +903,74 @@ type proc struct {
 	path    string
 	process *os.Process
 	sio     stdio
+
+	// fn is set for a call of a shell function, and builtin for
+	// a builtin run as a pipeline stage. Either runs on a goroutine
+	// in place of a process. Its result is sent on fnDone.
+	fn      *expr.ShellFuncDef
+	builtin func(sio stdio) error
+	fnDone  chan error
+}
+
+// inProcess reports whether p runs on a goroutine rather than
+// as a process of its own.
+func (p *proc) inProcess() bool {
+	return p.fn != nil || p.builtin != nil
+}
+
+// startFunc starts running the body of the shell function p.fn,
+// with the arguments of p as its positional parameters, or the
+// builtin p.builtin.
+//
+// Like a process, the function has its own copies of its stdio
+// files, so the job can close them once the function is started.
+func (p *proc) startFunc() error {
+	var sio stdio
+	for _, f := range []struct{ dst, src **os.File }{
+		{&sio.in, &p.sio.in},
+		{&sio.out, &p.sio.out},
+		{&sio.err, &p.sio.err},
+	} {
+		// The copies are not inherited by the processes
+		// started by other stages, or a stage reading from
+		// the pipe we write to would never see EOF.
+		syscall.ForkLock.RLock()
+		fd, err := syscall.Dup(int((*f.src).Fd()))
+		if err == nil {
+			syscall.CloseOnExec(fd)
+		}
+		syscall.ForkLock.RUnlock()
+		if err != nil {
+			sio.closeExcept(stdio{})
+			return err
+		}
+		*f.dst = os.NewFile(uintptr(fd), (*f.src).Name())
+	}
+	p.fnDone = make(chan error, 1)
+	if p.builtin != nil {
+		go func() {
+			err := p.builtin(sio)
+			sio.closeExcept(stdio{})
+			p.fnDone <- err
+		}()
+		return nil
+	}
+	j := &Job{
+		State:     p.job.State,
+		Cmd:       p.fn.Body,
+		Stdin:     sio.in,
+		Stdout:    sio.out,
+		Stderr:    sio.err,
+		Params:    funcParams{Params: p.job.Params, args: p.argv[1:]},
+		Interrupt: p.job.Interrupt,
+	}
+	j.cond.L = &j.mu
+	go func() {
+		err := j.execShellList(p.fn.Body, sio)
+		sio.closeExcept(stdio{})
+		p.fnDone <- err
+	}()
+	return nil
 }
 
 // TODO: make interactive a property of a *shell.State.
@@ -661,19 +1084,231 @@ func (s *State) bgFg(spec string) error {
 	return j.Continue()
 }
 
-func (j *Job) export(pairs []string) error {
-	for _, p := range pairs {
-		parts := strings.SplitN(p, "=", 2)
-		val := ""
-		if len(parts) > 1 {
-			val = parts[1]
+// expandAlias replaces an alias in the first word of argv with the
+// words of its value. The new first word is expanded in turn, unless
+// it is an alias already expanded, so ls='ls -F' is not a cycle.
+func (j *Job) expandAlias(argv []string, params shell.Params) ([]string, error) {
+	seen := make(map[string]bool)
+	for len(argv) > 0 && !seen[argv[0]] {
+		a, ok := j.State.Alias.Lookup(argv[0])
+		if !ok {
+			break
+		}
+		seen[argv[0]] = true
+		// The value is parsed like the words of a command, so
+		//	alias gsm='go build "-ldflags=-w -s"'
+		// is three words, not four.
+		words, err := shell.Expansion(shell.SplitWords(a), params)
+		if err != nil {
+			return nil, fmt.Errorf("alias %s: %v", argv[0], err)
+		}
+		argv = append(words, argv[1:]...)
+	}
+	return argv, nil
+}
+
+// alias implements the alias builtin. An argument name=value defines
+// an alias, and an argument name prints the alias to w. With no
+// arguments, all aliases are printed.
+func (s *State) alias(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		args = s.Alias.Keys("")
+	}
+	for _, arg := range args {
+		if i := strings.Index(arg, "="); i >= 0 {
+			s.Alias.Set(arg[:i], arg[i+1:])
+			continue
 		}
-		j.State.Env.Set(parts[0], val)
+		a, ok := s.Alias.Lookup(arg)
+		if !ok {
+			return fmt.Errorf("alias: %s: not found", arg)
+		}
+		fmt.Fprintf(w, "alias %s='%s'\n", arg, strings.Replace(a, "'", `'\''`, -1))
 	}
 	return nil
 }
 
-func Run(shellState *State, p Params, e *expr.Shell) (string, error) {
+// unalias implements the unalias builtin, which removes the named
+// aliases, or with -a all aliases.
+func (s *State) unalias(args []string) error {
+	if len(args) == 1 && args[0] == "-a" {
+		args = s.Alias.Keys("")
+	}
+	for _, arg := range args {
+		if _, ok := s.Alias.Lookup(arg); !ok {
+			return fmt.Errorf("unalias: %s: not found", arg)
+		}
+		s.Alias.Delete(arg)
+	}
+	return nil
+}
+
+// cd implements the cd builtin. It changes the working directory to
+// the named directory, or with no argument to $HOME. The argument -
+// changes to the previous directory, $OLDPWD.
+func (j *Job) cd(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("cd: too many arguments")
+	}
+	env := j.State.Env
+	dir := ""
+	if len(args) == 0 {
+		dir = env.Get("HOME")
+		if dir == "" {
+			return fmt.Errorf("cd: HOME not set")
+		}
+	} else if args[0] == "-" {
+		dir = env.Get("OLDPWD")
+		if dir == "" {
+			return fmt.Errorf("cd: OLDPWD not set")
+		}
+	} else {
+		dir = args[0]
+	}
+	wd := ""
+	if filepath.IsAbs(dir) {
+		wd = filepath.Clean(dir)
+	} else {
+		wd = filepath.Join(env.Get("PWD"), dir)
+	}
+	if err := os.Chdir(wd); err != nil {
+		if pe, ok := err.(*os.PathError); ok {
+			err = pe.Err
+		}
+		return fmt.Errorf("cd: %s: %v", dir, err)
+	}
+	if old := env.Get("PWD"); old != "" {
+		env.Set("OLDPWD", old)
+	}
+	env.Set("PWD", wd)
+	fmt.Fprintf(os.Stdout, "%s\n", wd)
+	return nil
+}
+
+func (s *State) defineFunc(fn *expr.ShellFuncDef) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.funcs == nil {
+		s.funcs = make(map[string]*expr.ShellFuncDef)
+	}
+	s.funcs[fn.Name] = fn
+}
+
+func (s *State) lookupFunc(name string) *expr.ShellFuncDef {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.funcs[name]
+}
+
+// export implements the export builtin. Each argument is either
+// NAME=value, which sets and exports NAME, or NAME, which exports
+// the shell variable NAME, set now or later.
+func (j *Job) export(args []string) error {
+	for _, arg := range args {
+		name := arg
+		if i := strings.Index(arg, "="); i >= 0 {
+			name = arg[:i]
+			j.Params.Set(name, arg[i+1:])
+			j.State.Env.Set(name, arg[i+1:])
+		}
+		j.State.Env.Export(name)
+	}
+	return nil
+}
+
+// environ returns the environment of a command: the environment
+// variables, the current values of the exported shell variables,
+// and the command's assignments, in increasing precedence.
+func (j *Job) environ(assign []string) []string {
+	env := environ.NewFrom(j.State.Env.List())
+	for _, name := range j.State.Env.Exported() {
+		if val, ok := lookupParam(j.Params, name); ok {
+			env.Set(name, val)
+		}
+	}
+	for _, kv := range assign {
+		i := strings.Index(kv, "=")
+		env.Set(kv[:i], kv[i+1:])
+	}
+	return env.List()
+}
+
+// lookupParam returns the value of the named parameter
+// and whether it is set.
+func lookupParam(params Params, name string) (string, bool) {
+	if l, ok := params.(shell.Lookuper); ok {
+		return l.Lookup(name)
+	}
+	val := params.Get(name)
+	return val, val != ""
+}
+
+// set implements the set builtin. The supported options are
+// errexit (-e), nounset (-u), and xtrace (-x). A '+' prefix
+// disables an option.
+func (s *State) set(args []string) error {
+	for _, arg := range args {
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			return fmt.Errorf("set: %s: invalid option", arg)
+		}
+		on := arg[0] == '-'
+		for _, c := range arg[1:] {
+			if err := s.setOption(c, on); err != nil {
+				return fmt.Errorf("set: %c%c: %v", arg[0], c, err)
+			}
+		}
+	}
+	return nil
+}
+
+// option reports whether the shell option named by the flag c of
+// the set builtin is on. Errexit, 'e', is on unless NoErrExit is set.
+func (s *State) option(c rune) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch c {
+	case 'e':
+		return !s.NoErrExit
+	case 'u':
+		return s.NoUnset
+	case 'x':
+		return s.XTrace
+	}
+	return false
+}
+
+func (s *State) setOption(c rune, on bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch c {
+	case 'e':
+		s.NoErrExit = !on
+	case 'u':
+		s.NoUnset = on
+	case 'x':
+		s.XTrace = on
+	default:
+		return fmt.Errorf("invalid option")
+	}
+	return nil
+}
+
+func (s *State) exitStatus() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ExitStatus
+}
+
+func (s *State) setExitStatus(status int) {
+	s.mu.Lock()
+	s.ExitStatus = status
+	s.mu.Unlock()
+}
+
+// Run runs the shell commands of e and returns their output if
+// e traps it. Signals received on sigint interrupt the running
+// command. The sigint channel may be nil.
+func Run(shellState *State, p Params, e *expr.Shell, sigint <-chan os.Signal) (string, error) {
 	res := make(chan string)
 	out := os.Stdout
 	if e.DropOut {
@@ -690,6 +1325,7 @@ func Run(shellState *State, p Params, e *expr.Shell) (string, error) {
 			if err != nil {
 				panic(err)
 			}
+			r.Close()
 			res <- string(b)
 		}()
 	} else {
@@ -705,13 +1341,15 @@ func Run(shellState *State, p Params, e *expr.Shell) (string, error) {
 			Stdin:  os.Stdin,
 			Stdout: out,
 			Stderr: os.Stderr,
+
+			Interrupt: sigint,
 		}
 		if err = j.Start(); err != nil {
 			break
 		}
 		var done bool
 		done, err = j.Wait()
-		if err != nil {
+		if err != nil && j.ErrExit() {
 			break
 		}
 		if !done {
@@ -725,6 +1363,17 @@ func Run(shellState *State, p Params, e *expr.Shell) (string, error) {
 	return str, err
 }
 
+// Call runs the shell function fn with the positional parameters
+// args, and returns its standard output. The function need not be
+// defined in shellState.
+func Call(shellState *State, p Params, fn *expr.ShellFuncDef, args ...string) (string, error) {
+	e := &expr.Shell{
+		Cmds:    []*expr.ShellList{fn.Body},
+		TrapOut: true,
+	}
+	return Run(shellState, funcParams{Params: p, args: args}, e, nil)
+}
+
 var devNull *os.File
 
 func init() {
@@ -0,0 +1,152 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shell_test
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"neugram.io/ng/eval/environ"
+	"neugram.io/ng/eval/shell"
+	"neugram.io/ng/parser"
+	"neugram.io/ng/syntax/expr"
+	"neugram.io/ng/syntax/stmt"
+)
+
+func parseShell(src string) (*expr.Shell, error) {
+	s, err := parser.ParseStmt([]byte(src))
+	if err != nil {
+		return nil, fmt.Errorf("ParseStmt(%q): %v", src, err)
+	}
+	return s.(*stmt.Assign).Right[0].(*expr.Shell), nil
+}
+
+// TestConcurrentState runs shell commands sharing a State from many
+// goroutines. Run it with -race.
+func TestConcurrentState(t *testing.T) {
+	state := &shell.State{
+		Env:   environ.NewFrom(os.Environ()),
+		Alias: environ.New(),
+	}
+
+	const n = 16
+	errc := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("NGCONCURRENT%d", i)
+			src := fmt.Sprintf("x := $$ set -eu +x; %s=%d; alias e%d=echo; true; e%d $? $%s $$", key, i, i, i, key)
+			e, err := parseShell(src)
+			if err != nil {
+				errc <- err
+				return
+			}
+			// Under set -e, a failing command stops the list.
+			failSrc := fmt.Sprintf("x := $$ set -e; e%d -n one; false; e%d -n two $$", i, i)
+			failE, err := parseShell(failSrc)
+			if err != nil {
+				errc <- err
+				return
+			}
+			for j := 0; j < 5; j++ {
+				out, err := shell.Run(state, state.Env, e, nil)
+				if err != nil {
+					errc <- fmt.Errorf("%s: %v", src, err)
+					return
+				}
+				if want := fmt.Sprintf("0 %d\n", i); out != want {
+					errc <- fmt.Errorf("%s: output %q, want %q", src, out, want)
+					return
+				}
+				if got, want := state.Env.Get(key), fmt.Sprint(i); got != want {
+					errc <- fmt.Errorf("%s=%q, want %q", key, got, want)
+					return
+				}
+				out, err = shell.Run(state, state.Env, failE, nil)
+				if err == nil {
+					errc <- fmt.Errorf("%s: missing error", failSrc)
+					return
+				}
+				if out != "one" {
+					errc <- fmt.Errorf("%s: output %q, want %q", failSrc, out, "one")
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		t.Error(err)
+	}
+}
+
+func TestErrExit(t *testing.T) {
+	tests := []struct {
+		src     string
+		out     string
+		wantErr bool
+	}{
+		{"x := $$ set -e; echo -n one; false; echo -n two $$", "one", true},
+		{"x := $$ set +e; echo -n one; false; echo -n two $$", "onetwo", false},
+		{"x := $$ set -e; false || echo -n one; echo -n two $$", "onetwo", false},
+	}
+	for _, test := range tests {
+		state := &shell.State{
+			Env:   environ.NewFrom(os.Environ()),
+			Alias: environ.New(),
+		}
+		e, err := parseShell(test.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		out, err := shell.Run(state, state.Env, e, nil)
+		if test.wantErr && err == nil {
+			t.Errorf("%s: missing error", test.src)
+		} else if !test.wantErr && err != nil {
+			t.Errorf("%s: %v", test.src, err)
+		}
+		if out != test.out {
+			t.Errorf("%s: output %q, want %q", test.src, out, test.out)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	state := &shell.State{
+		Env:   environ.NewFrom(os.Environ()),
+		Alias: environ.New(),
+	}
+	src := "x := $$ twice() { echo -n $1$1 $#; } $$"
+	e, err := parseShell(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := e.Cmds[0].AndOr[0].Pipeline[0].Cmd[0].FuncDef
+	out, err := shell.Call(state, state.Env, fn, "ab", "c")
+	if err != nil {
+		t.Fatalf("%s: %v", src, err)
+	}
+	if want := "abab 2"; out != want {
+		t.Errorf("%s: output %q, want %q", src, out, want)
+	}
+}
+
+type fakeSignal struct{}
+
+func (fakeSignal) String() string { return "fake" }
+func (fakeSignal) Signal()        {}
+
+func TestSignalUnsupported(t *testing.T) {
+	j := &shell.Job{}
+	if err := j.Signal(fakeSignal{}); err == nil {
+		t.Error("Signal(fakeSignal{}) returned no error")
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shell
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// read implements the read builtin. It reads a line from in, splits
+// it into fields around the characters of $IFS, and assigns them to
+// the named parameters, the last of which holds the rest of the line.
+// With no names the line is assigned to $REPLY.
+//
+// Unless the -r option is given, a backslash escapes the character
+// that follows it and a backslash-newline continues the line.
+// At the end of input read fails with exit status 1.
+func (j *Job) read(args []string, in io.Reader) error {
+	raw := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		if args[0] != "-r" {
+			return fmt.Errorf("read: %s: invalid option", args[0])
+		}
+		raw = true
+		args = args[1:]
+	}
+	names := args
+	if len(names) == 0 {
+		names = []string{"REPLY"}
+	}
+
+	line, escaped, err := readLine(in, raw)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	ifs, ok := lookupParam(j.Params, "IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	fields := splitIFS(line, escaped, ifs, len(names))
+	for i, name := range names {
+		val := ""
+		if i < len(fields) {
+			val = fields[i]
+		}
+		j.Params.Set(name, val)
+	}
+	if err == io.EOF {
+		return exitError{code: 1}
+	}
+	return nil
+}
+
+// readLine reads a line from r, less its newline. It reads a byte at
+// a time so as not to consume any input beyond the line. Each element
+// of escaped reports whether the byte of line was escaped by a
+// backslash. The error is io.EOF if the input ends before a newline.
+func readLine(r io.Reader, raw bool) (line []byte, escaped []bool, err error) {
+	esc := false
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 0 {
+			if err == nil {
+				continue
+			}
+			return line, escaped, err
+		}
+		c := b[0]
+		switch {
+		case esc:
+			esc = false
+			if c == '\n' {
+				continue // line continuation
+			}
+			line = append(line, c)
+			escaped = append(escaped, true)
+			continue
+		case c == '\\' && !raw:
+			esc = true
+			continue
+		case c == '\n':
+			return line, escaped, nil
+		}
+		line = append(line, c)
+		escaped = append(escaped, false)
+	}
+}
+
+// splitIFS splits line into at most n fields around the unescaped
+// bytes of ifs. As in other shells, a run of whitespace in ifs is a
+// single separator, and the last field holds the rest of line.
+func splitIFS(line []byte, escaped []bool, ifs string, n int) []string {
+	isSep := func(i int) bool {
+		return !escaped[i] && strings.IndexByte(ifs, line[i]) >= 0
+	}
+	isSpace := func(i int) bool {
+		return isSep(i) && (line[i] == ' ' || line[i] == '\t' || line[i] == '\n')
+	}
+
+	start, end := 0, len(line)
+	for start < end && isSpace(start) {
+		start++
+	}
+	for end > start && isSpace(end-1) {
+		end--
+	}
+	var fields []string
+	for len(fields) < n-1 {
+		i := start
+		for i < end && !isSep(i) {
+			i++
+		}
+		if i == end {
+			break
+		}
+		fields = append(fields, string(line[start:i]))
+		for i < end && isSpace(i) {
+			i++
+		}
+		if i < end && isSep(i) && !isSpace(i) {
+			i++
+			for i < end && isSpace(i) {
+				i++
+			}
+		}
+		start = i
+	}
+	return append(fields, string(line[start:end]))
+}
@@ -0,0 +1,114 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// test implements the test and [ builtins, which evaluate a
+// conditional expression and exit with status 0 if it is true
+// and status 1 if it is false.
+//
+// The supported expressions are:
+//
+//	-e file, -f file, -d file   file exists, is a regular file, is a directory
+//	-z str, -n str              str is empty, is not empty
+//	str                         str is not empty
+//	s1 = s2, s1 != s2           string comparison
+//	n1 -eq n2, -ne, -lt, -le, -gt, -ge
+//	                            integer comparison
+//	! expr                      negation
+func test(argv []string) error {
+	name, args := argv[0], argv[1:]
+	if name == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			return fmt.Errorf("[: missing ]")
+		}
+		args = args[:len(args)-1]
+	}
+	ok, err := testExpr(args)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if !ok {
+		return exitError{code: 1}
+	}
+	return nil
+}
+
+func testExpr(args []string) (bool, error) {
+	if len(args) > 1 && args[0] == "!" {
+		ok, err := testExpr(args[1:])
+		return !ok, err
+	}
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		return testUnary(args[0], args[1])
+	case 3:
+		return testBinary(args[0], args[1], args[2])
+	}
+	return false, fmt.Errorf("too many arguments")
+}
+
+func testUnary(op, x string) (bool, error) {
+	switch op {
+	case "-z":
+		return x == "", nil
+	case "-n":
+		return x != "", nil
+	case "-e", "-f", "-d":
+		fi, err := os.Stat(x)
+		if err != nil {
+			return false, nil
+		}
+		switch op {
+		case "-f":
+			return fi.Mode().IsRegular(), nil
+		case "-d":
+			return fi.IsDir(), nil
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("%s: unary operator expected", op)
+}
+
+func testBinary(x, op, y string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return x == y, nil
+	case "!=":
+		return x != y, nil
+	case "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+		a, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s: integer expression expected", x)
+		}
+		b, err := strconv.ParseInt(y, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s: integer expression expected", y)
+		}
+		switch op {
+		case "-eq":
+			return a == b, nil
+		case "-ne":
+			return a != b, nil
+		case "-lt":
+			return a < b, nil
+		case "-le":
+			return a <= b, nil
+		case "-gt":
+			return a > b, nil
+		}
+		return a >= b, nil
+	}
+	return false, fmt.Errorf("%s: binary operator expected", op)
+}
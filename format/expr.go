@@ -9,8 +9,10 @@ package format
 import (
 	"bytes"
 	"fmt"
+	"strconv"
 
 	"neugram.io/ng/syntax/expr"
+	"neugram.io/ng/syntax/shell"
 	"neugram.io/ng/syntax/stmt"
 	"neugram.io/ng/syntax/token"
 )
@@ -29,9 +31,10 @@ func (p *printer) expr(e expr.Expr) {
 	case *expr.Unary:
 		p.buf.WriteString(e.Op.String())
 		WriteExpr(p.buf, e.Expr)
-		if e.Op == token.LeftParen {
-			p.buf.WriteByte(')')
-		}
+	case *expr.Paren:
+		p.buf.WriteByte('(')
+		WriteExpr(p.buf, e.Expr)
+		p.buf.WriteByte(')')
 	case *expr.Bad:
 		fmt.Fprintf(p.buf, "bad(%q)", e.Error)
 	case *expr.Slice:
@@ -46,6 +49,18 @@ func (p *printer) expr(e expr.Expr) {
 			p.buf.WriteString(":")
 			p.expr(e.Max)
 		}
+	case *expr.Range:
+		if e.Start != nil {
+			p.expr(e.Start)
+		}
+		if e.Inclusive {
+			p.buf.WriteString("..=")
+		} else {
+			p.buf.WriteString("..")
+		}
+		if e.End != nil {
+			p.expr(e.End)
+		}
 	case *expr.Selector:
 		p.expr(e.Left)
 		p.buf.WriteString("." + e.Right.Name)
@@ -64,6 +79,7 @@ func (p *printer) expr(e expr.Expr) {
 			p.buf.WriteByte(' ')
 			p.buf.WriteString(e.Name)
 		}
+		p.typeParams(e.Type.TypeParams)
 		p.buf.WriteByte('(')
 
 		// Similar to tipeFuncSig, but with parameter names.
@@ -230,6 +246,32 @@ func (p *printer) expr(e expr.Expr) {
 			p.expr(idx)
 		}
 		p.buf.WriteString("]")
+	case *expr.TableIndex:
+		p.expr(e.Expr)
+		p.buf.WriteString("[")
+		for i, name := range e.ColNames {
+			if i > 0 {
+				p.buf.WriteString("|")
+			}
+			p.buf.WriteString(strconv.Quote(name))
+		}
+		if r := e.Rows; r.Start != nil || r.End != nil || r.Exact != nil {
+			p.buf.WriteString(", ")
+			if r.Exact != nil {
+				p.expr(r.Exact)
+			} else if r.Inclusive {
+				p.expr(&r)
+			} else {
+				if r.Start != nil {
+					p.expr(r.Start)
+				}
+				p.buf.WriteString(":")
+				if r.End != nil {
+					p.expr(r.End)
+				}
+			}
+		}
+		p.buf.WriteString("]")
 	case *expr.TypeAssert:
 		p.expr(e.Left)
 		p.buf.WriteString(".(")
@@ -239,6 +281,19 @@ func (p *printer) expr(e expr.Expr) {
 			WriteType(p.buf, e.Type)
 		}
 		p.buf.WriteString(")")
+	case *expr.IfExpr:
+		p.buf.WriteString("if ")
+		p.expr(e.Cond)
+		p.buf.WriteString(" { ")
+		p.expr(e.Then)
+		p.buf.WriteString(" } else ")
+		if _, isIf := e.Else.(*expr.IfExpr); isIf {
+			p.expr(e.Else)
+		} else {
+			p.buf.WriteString("{ ")
+			p.expr(e.Else)
+			p.buf.WriteString(" }")
+		}
 	case *expr.Call:
 		WriteExpr(p.buf, e.Func)
 		p.buf.WriteString("(")
@@ -250,7 +305,7 @@ func (p *printer) expr(e expr.Expr) {
 		}
 		p.buf.WriteString(")")
 	case *expr.Shell:
-		if len(e.Cmds) == 1 {
+		if len(e.Cmds) == 1 && len(heredocs(e.Cmds[0])) == 0 {
 			p.buf.WriteString("$$ ")
 			p.expr(e.Cmds[0])
 			p.buf.WriteString(" $$")
@@ -274,6 +329,11 @@ func (p *printer) expr(e expr.Expr) {
 			}
 			p.expr(andor)
 		}
+		for _, r := range heredocs(e) {
+			p.buf.WriteByte('\n')
+			p.buf.WriteString(r.Heredoc)
+			p.buf.WriteString(shell.HeredocDelim(r.Filename))
+		}
 	case *expr.ShellAndOr:
 		for i, pl := range e.Pipeline {
 			p.expr(pl)
@@ -299,7 +359,11 @@ func (p *printer) expr(e expr.Expr) {
 		}
 		for i, cmd := range e.Cmd {
 			if i > 0 {
-				p.buf.WriteString(" | ")
+				if e.Cmd[i-1] != nil && e.Cmd[i-1].PipeStderr {
+					p.buf.WriteString(" |& ")
+				} else {
+					p.buf.WriteString(" | ")
+				}
 			}
 			p.expr(cmd)
 		}
@@ -313,6 +377,15 @@ func (p *printer) expr(e expr.Expr) {
 			p.buf.WriteByte('(')
 			p.expr(e.Subshell)
 			p.buf.WriteByte(')')
+		} else if e.FuncDef != nil {
+			body := e.FuncDef.Body
+			p.printf("%s() { ", e.FuncDef.Name)
+			p.expr(body)
+			if n := len(body.AndOr); n > 0 && body.AndOr[n-1].Background {
+				p.buf.WriteString(" }")
+			} else {
+				p.buf.WriteString("; }")
+			}
 		} else {
 			p.printf("<bad shellcmd is empty>")
 		}
@@ -351,6 +424,26 @@ func (p *printer) expr(e expr.Expr) {
 	}
 }
 
+// heredocs returns the here-document redirections of the simple
+// commands in l, in order. Their bodies follow the line holding l.
+func heredocs(l *expr.ShellList) (rs []*expr.ShellRedirect) {
+	for _, andor := range l.AndOr {
+		for _, pl := range andor.Pipeline {
+			for _, cmd := range pl.Cmd {
+				if cmd.SimpleCmd == nil {
+					continue
+				}
+				for _, r := range cmd.SimpleCmd.Redirect {
+					if r.Token == token.TwoLess {
+						rs = append(rs, r)
+					}
+				}
+			}
+		}
+	}
+	return rs
+}
+
 func (p *printer) printf(format string, args ...interface{}) {
 	fmt.Fprintf(p.buf, format, args...)
 }
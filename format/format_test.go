@@ -6,14 +6,32 @@ import (
 	"neugram.io/ng/format"
 	"neugram.io/ng/parser"
 	"neugram.io/ng/syntax/stmt"
+	"neugram.io/ng/syntax/tipe"
 )
 
 var roundTripExprs = []string{
 	"$$ sleep 1 && X=V Y=U env | grep X= & echo first || false; echo last $$",
 	"$$ (echo a && echo b); echo c $$",
+	"$$ greet() { echo hello $1; echo bye; }; greet ng $$",
+	"$$ f() { sleep 1 & } $$",
+	"$$ tr a-z A-Z <<<$x | wc -c $$",
+	"$$ go build |& grep -v warning | head $$",
+	"$$ make &>>build.log $$",
+	"$$ make 2>&1 | tee out $$",
+	"$$ echo warning 1>&2 $$",
+	"$$ make 2>err.log >out.log $$",
+	"$$ make 2>>log $$",
+	"$$ make >>log <in $$",
+	"$$ make &>all $$",
 	`$$
 echo one
 echo two
+$$`,
+	`$$
+cat <<EOF | wc -l; echo done
+hello
+world
+EOF
 $$`,
 
 	// TODO: spacing around return statement
@@ -21,10 +39,26 @@ $$`,
 	"func() error {return nil}",
 	"func() (err error) {return nil}",
 	"func(x int, y bool) (b []byte, err error) {return nil, nil}",
+	"func F[T any](x T) T {return x}",
+	"func Keys[K, V any, N ~int | string](m map[K]V, n N) {}",
 
 	"x[:y]",
 	"x[y:z:t]",
 	"new(int)",
+	`x["C1"|"C2"]`,
+	`x["C1", 1:]`,
+	`x["C1"|"C2", :y]`,
+	`x["C1", 3]`,
+	`x["C1", 1..=y]`,
+	"x[1..3]",
+	"(a+b)*c",
+	"a+(b*c)",
+	"-(a-b)",
+	"((a))",
+	"x[i..=j]",
+	"if x>1 { a } else { b }",
+	"f(if ok { a } else if x { b } else { T{} })",
+	"x[..j]",
 }
 
 var roundTripStmts = []string{
@@ -35,6 +69,13 @@ var roundTripStmts = []string{
 	"path/filepath"
 )`,
 	"type Ints []int",
+	"type Byte uint8",
+	"type Byte = uint8",
+	"type A [N]int",
+	`type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}`,
 
 	`methodik foo struct {
 	S string
@@ -71,6 +112,29 @@ func TestRoundTrip(t *testing.T) {
 	}
 }
 
+// Redirect filenames may be separated from the operator by
+// spaces, which are dropped when formatting.
+var redirectTests = []struct {
+	src, want string
+}{
+	{"($$ make 2>> log $$)", "($$ make 2>>log $$)"},
+	{"($$ make &> all $$)", "($$ make &>all $$)"},
+	{"($$ make > out 2>&1 $$)", "($$ make >out 2>&1 $$)"},
+}
+
+func TestRedirect(t *testing.T) {
+	for _, test := range redirectTests {
+		s, err := parser.ParseStmt([]byte(test.src))
+		if err != nil {
+			t.Errorf("ParseStmt(%q): error: %v", test.src, err)
+			continue
+		}
+		if got := format.Stmt(s); got != test.want {
+			t.Errorf("Stmt(%q)=%q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
 var typeTests = []string{
 	`string`,
 	`uintptr`,
@@ -92,8 +156,44 @@ var typeTests = []string{
 	M0(int, int) (int, int)
 	M1(struct{})
 	M2(*int) error
+}`,
+	`interface {
+	~int | string
+	~float32 | ~float64
 }`,
 	`struct{}`,
+	`chan int`,
+	`chan<- int`,
+	`<-chan int`,
+	`chan<- <-chan int`,
+	`chan (<-chan int)`,
+	`map[string][]chan func() error`,
+	`func() func() int`,
+	`func(int, ...string) (int, error)`,
+	`func(...interface{})`,
+	`map[string][]int`,
+	`map[*int][]*string`,
+	`*struct {
+	A int
+	B *struct {
+		C []int
+	}
+}`,
+	`[]*map[string]int`,
+	`[len(a)]int`,
+	"struct {\n\tName string `json:\"name\"`\n\tAge  int\n}",
+	"struct {\n\tX int \"a`b\"\n}",
+	`[2*N]byte`,
+	`struct {
+	io.Reader
+	*Node
+	X int
+}`,
+	`interface {
+	io.Reader
+	Closer
+	Flush() error
+}`,
 }
 
 func TestTypes(t *testing.T) {
@@ -114,3 +214,19 @@ func TestTypes(t *testing.T) {
 		}
 	}
 }
+
+func TestTypeVariadicSlice(t *testing.T) {
+	// Func types converted from Go hold the variadic parameter as a slice.
+	typ := &tipe.Func{
+		Params: &tipe.Tuple{Elems: []tipe.Type{
+			tipe.String,
+			&tipe.Slice{Elem: &tipe.Interface{}},
+		}},
+		Results:  &tipe.Tuple{Elems: []tipe.Type{tipe.Int, tipe.Int}},
+		Variadic: true,
+	}
+	want := "func(string, ...interface{}) (int, int)"
+	if got := format.Type(typ); got != want {
+		t.Errorf("Type=%q, want %q", got, want)
+	}
+}
@@ -40,7 +40,11 @@ func (p *printer) stmt(s stmt.Stmt) {
 	case *stmt.TypeDecl:
 		p.buf.WriteString("type ")
 		p.buf.WriteString(s.Name)
+		p.typeParams(s.Type.TypeParams)
 		p.buf.WriteString(" ")
+		if s.Alias {
+			p.buf.WriteString("= ")
+		}
 		p.tipe(s.Type.Type)
 	case *stmt.MethodikDecl:
 		p.buf.WriteString("methodik ")
@@ -8,7 +8,9 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strconv"
 
+	"neugram.io/ng/syntax/expr"
 	"neugram.io/ng/syntax/tipe"
 )
 
@@ -29,27 +31,36 @@ func (p *printer) tipe(t tipe.Type) {
 		p.indent++
 		maxlen := 0
 		for _, sf := range t.Fields {
-			if len(sf.Name) > maxlen {
+			if !sf.Embedded && len(sf.Name) > maxlen {
 				maxlen = len(sf.Name)
 			}
 		}
 		for _, sf := range t.Fields {
 			p.newline()
-			name := sf.Name
-			if name == "" {
-				name = "*ERROR*No*Name*"
+			if !sf.Embedded {
+				name := sf.Name
+				if name == "" {
+					name = "*ERROR*No*Name*"
+				}
+				p.buf.WriteString(name)
+				for i := len(name); i <= maxlen; i++ {
+					p.buf.WriteByte(' ')
+				}
 			}
-			p.buf.WriteString(name)
-			for i := len(name); i <= maxlen; i++ {
+			p.tipe(sf.Type)
+			if sf.Tag != "" {
 				p.buf.WriteByte(' ')
+				p.buf.WriteString(StructTag(sf.Tag))
 			}
-			p.tipe(sf.Type)
 		}
 		p.indent--
 		p.newline()
 		p.buf.WriteByte('}')
 	case *tipe.Named:
 		p.buf.WriteString(t.Name)
+		p.typeArgs(t.TypeArgs)
+	case *tipe.TypeParam:
+		p.buf.WriteString(t.Name)
 	case *tipe.Pointer:
 		p.buf.WriteByte('*')
 		p.tipe(t.Elem)
@@ -59,9 +70,14 @@ func (p *printer) tipe(t tipe.Type) {
 			p.buf.WriteByte('.')
 		}
 		p.buf.WriteString(t.Name)
+		p.typeArgs(t.TypeArgs)
 	case *tipe.Array:
 		if t.Ellipsis {
 			p.buf.WriteString("[...]")
+		} else if x, ok := t.LenExpr.(expr.Expr); ok {
+			p.buf.WriteByte('[')
+			p.expr(x)
+			p.buf.WriteByte(']')
 		} else {
 			fmt.Fprintf(p.buf, "[%d]", t.Len)
 		}
@@ -73,12 +89,16 @@ func (p *printer) tipe(t tipe.Type) {
 		p.buf.WriteString("[|]")
 		p.tipe(t.Type)
 	case *tipe.Interface:
-		if len(t.Methods) == 0 {
+		if len(t.Methods) == 0 && len(t.Embeds) == 0 {
 			p.buf.WriteString("interface{}")
 			return
 		}
 		p.buf.WriteString("interface {")
 		p.indent++
+		for _, e := range t.Embeds {
+			p.newline()
+			p.tipe(e)
+		}
 		names := make([]string, 0, len(t.Methods))
 		for name := range t.Methods {
 			names = append(names, name)
@@ -92,6 +112,16 @@ func (p *printer) tipe(t tipe.Type) {
 		p.indent--
 		p.newline()
 		p.buf.WriteByte('}')
+	case *tipe.Union:
+		for i, term := range t.Terms {
+			if i > 0 {
+				p.buf.WriteString(" | ")
+			}
+			if term.Tilde {
+				p.buf.WriteByte('~')
+			}
+			p.tipe(term.Type)
+		}
 	case *tipe.Map:
 		p.buf.WriteString("map[")
 		p.tipe(t.Key)
@@ -106,7 +136,13 @@ func (p *printer) tipe(t tipe.Type) {
 			p.buf.WriteString("<-")
 		}
 		p.buf.WriteByte(' ')
-		p.tipe(t.Elem)
+		if ChanElemParens(t) {
+			p.buf.WriteByte('(')
+			p.tipe(t.Elem)
+			p.buf.WriteByte(')')
+		} else {
+			p.tipe(t.Elem)
+		}
 	case *tipe.Func:
 		p.buf.WriteString("func")
 		p.tipeFuncSig(t)
@@ -130,13 +166,53 @@ func (p *printer) tipe(t tipe.Type) {
 	}
 }
 
+// typeArgs prints the type arguments of an instantiation, [string].
+func (p *printer) typeArgs(args []tipe.Type) {
+	if len(args) == 0 {
+		return
+	}
+	p.buf.WriteByte('[')
+	for i, arg := range args {
+		if i > 0 {
+			p.buf.WriteString(", ")
+		}
+		p.tipe(arg)
+	}
+	p.buf.WriteByte(']')
+}
+
+// typeParams prints a type parameter list, [K, V any].
+func (p *printer) typeParams(params []*tipe.TypeParam) {
+	if len(params) == 0 {
+		return
+	}
+	p.buf.WriteByte('[')
+	for i, tp := range params {
+		if i > 0 {
+			p.buf.WriteString(", ")
+		}
+		p.buf.WriteString(tp.Name)
+		if i+1 < len(params) && params[i+1].Constraint == tp.Constraint {
+			continue // declared together, [K, V any]
+		}
+		p.buf.WriteByte(' ')
+		p.tipe(tp.Constraint)
+	}
+	p.buf.WriteByte(']')
+}
+
 func (p *printer) tipeFuncSig(t *tipe.Func) {
+	p.typeParams(t.TypeParams)
 	p.buf.WriteByte('(')
 	if t.Params != nil {
-		for i, elem := range t.Params.Elems {
+		for i := range t.Params.Elems {
 			if i > 0 {
 				p.buf.WriteString(", ")
 			}
+			elem, variadic := ParamType(t, i)
+			if variadic {
+				p.buf.WriteString("...")
+			}
 			p.tipe(elem)
 		}
 	}
@@ -170,3 +246,31 @@ func Type(t tipe.Type) string {
 	WriteType(buf, t)
 	return buf.String()
 }
+
+// StructTag returns tag as a Go string literal, preferring
+// the raw form conventionally used for struct tags.
+func StructTag(tag tipe.StructTag) string {
+	if strconv.CanBackquote(string(tag)) {
+		return "`" + string(tag) + "`"
+	}
+	return strconv.Quote(string(tag))
+}
+
+// ParamType returns the type of parameter i of t as it is written
+// in a signature. If it is the final parameter of a variadic
+// function held as a slice, as params imported from Go are, it
+// returns the element type and variadic is true.
+func ParamType(t *tipe.Func, i int) (typ tipe.Type, variadic bool) {
+	typ = t.Params.Elems[i]
+	if s, isSlice := typ.(*tipe.Slice); isSlice && t.Variadic && i == len(t.Params.Elems)-1 {
+		return s.Elem, true
+	}
+	return typ, false
+}
+
+// ChanElemParens reports whether the element type of t must be
+// parenthesized, as chan <-chan T would be read as chan<- chan T.
+func ChanElemParens(t *tipe.Chan) bool {
+	elem, ok := t.Elem.(*tipe.Chan)
+	return ok && t.Direction == tipe.ChanBoth && elem.Direction == tipe.ChanRecv
+}
@@ -0,0 +1,8 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gengo
+
+var FormatSource = formatSource
+var GoValue = goValue
@@ -0,0 +1,129 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gengo
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+)
+
+// GenGoPackage is like GenGo, but writes the generated program to
+// several files of package outGoPkgName, keyed by file name.
+//
+// The types and package-level variables are written to main.go,
+// the init block to init.go, and each function hoisted out of the
+// init block to its own func_NAME_gen.go file. The printing helpers
+// gengo adds are written to support.go. Each file imports only the
+// packages it uses.
+func GenGoPackage(filename, outGoPkgName string) (map[string][]byte, error) {
+	pkg, err := genGo(filename, outGoPkgName, false)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]byte)
+	for _, f := range pkg.files {
+		src, err := pkg.source(f)
+		if err != nil {
+			return nil, err
+		}
+		res[f.name] = src
+	}
+	return res, nil
+}
+
+// A goPackage is the Go package generated for a Neugram program.
+type goPackage struct {
+	name    string
+	imports []goImport
+	files   []*goFile
+}
+
+type goImport struct {
+	name string // empty unless the import is renamed
+	path string
+}
+
+// A goFile is the body of a generated Go file, its declarations
+// without the package clause and imports.
+type goFile struct {
+	name string
+	body *bytes.Buffer
+}
+
+// file returns the body of the named file, adding the file to
+// the package if it is new.
+func (pkg *goPackage) file(name string) *bytes.Buffer {
+	for _, f := range pkg.files {
+		if f.name == name {
+			return f.body
+		}
+	}
+	f := &goFile{name: name, body: new(bytes.Buffer)}
+	pkg.files = append(pkg.files, f)
+	return f.body
+}
+
+// source returns the gofmt'd source of a Go file holding the bodies
+// of files. It imports the packages of pkg the bodies use.
+func (pkg *goPackage) source(files ...*goFile) ([]byte, error) {
+	body := new(bytes.Buffer)
+	for _, f := range files {
+		body.Write(f.body.Bytes())
+		body.WriteString("\n\n")
+	}
+	used := usedPackages(body.Bytes())
+
+	imports := new(bytes.Buffer)
+	for _, imp := range pkg.imports {
+		name := imp.name
+		if name == "" {
+			name = path.Base(imp.path)
+		}
+		if used != nil && !used[name] {
+			continue
+		}
+		if imp.name != "" {
+			fmt.Fprintf(imports, "\t%s %q\n", imp.name, imp.path)
+		} else {
+			fmt.Fprintf(imports, "\t%q\n", imp.path)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// generated by ng, do not edit\n\npackage %s\n\n", pkg.name)
+	if imports.Len() > 0 {
+		fmt.Fprintf(buf, "import (\n%s)\n\n", imports.Bytes())
+	}
+	buf.Write(body.Bytes())
+	return formatSource(buf.Bytes())
+}
+
+// usedPackages returns the names of the packages referred to by
+// the Go declarations in body. If body cannot be parsed it returns
+// nil, and the error is left for go/format to report.
+func usedPackages(body []byte) map[string]bool {
+	src := append([]byte("package p\n\n"), body...)
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil
+	}
+	used := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, isSel := n.(*ast.SelectorExpr)
+		if !isSel {
+			return true
+		}
+		// Package names are left unresolved by the parser.
+		if x, isIdent := sel.X.(*ast.Ident); isIdent && x.Obj == nil {
+			used[x.Name] = true
+		}
+		return true
+	})
+	return used
+}
@@ -10,9 +10,12 @@ import (
 	"bytes"
 	"fmt"
 	goformat "go/format"
+	gotoken "go/token"
+	"math/big"
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -26,12 +29,39 @@ import (
 	"neugram.io/ng/typecheck"
 )
 
+// GenGo generates a Go program from the Neugram program in filename.
+// It is the files of GenGoPackage joined into one.
+//
+// If the generated source is not valid Go, the error is a
+// *FormatError and result holds the unformatted source.
 func GenGo(filename, outGoPkgName string) (result []byte, err error) {
+	pkg, err := genGo(filename, outGoPkgName, false)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.source(pkg.files...)
+}
+
+// GenGoTest is like GenGo, but generates a Go _test.go file.
+//
+// Top-level functions named TestXxx that take a single *testing.T
+// parameter are emitted as Go test functions, so the script can be
+// run with go test.
+func GenGoTest(filename, outGoPkgName string) (result []byte, err error) {
+	pkg, err := genGo(filename, outGoPkgName, true)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.source(pkg.files...)
+}
+
+// genGo generates the Go package for the Neugram program in filename.
+func genGo(filename, outGoPkgName string, test bool) (*goPackage, error) {
 	p := &printer{
-		buf:     new(bytes.Buffer),
-		c:       typecheck.New(filepath.Base(filename)), // TODO: extract a pkg name
-		imports: make(map[*tipe.Package]string),
-		eliders: make(map[tipe.Type]string),
+		c:        typecheck.New(filepath.Base(filename)), // TODO: extract a pkg name
+		imports:  make(map[*tipe.Package]string),
+		eliders:  make(map[tipe.Type]string),
+		topFuncs: make(map[*expr.FuncLiteral]bool),
 	}
 
 	abspath, err := filepath.Abs(filename)
@@ -47,13 +77,60 @@ func GenGo(filename, outGoPkgName string) (result []byte, err error) {
 	if outGoPkgName == "" {
 		outGoPkgName = "gengo_" + strings.TrimSuffix(filepath.Base(filename), ".ng")
 	}
-	p.printf(`// generated by ng, do not edit
-
-package %s
-
-`, outGoPkgName)
-
+	pkg := &goPackage{name: outGoPkgName}
+
+	// Named top-level functions are emitted as Go functions, so
+	// they can be called from Go and can refer to one another.
+	// A Go function cannot be redeclared or assigned to, so one
+	// that is stays a func variable set in init. Generic functions,
+	// and in test mode test functions, cannot be function values
+	// so they are always emitted as Go functions.
+	declared := make(map[string]int)
+	for _, s := range p.pkg.Syntax.Stmts {
+		if s, ok := s.(*stmt.Simple); ok {
+			if fn, ok := s.Expr.(*expr.FuncLiteral); ok && fn.Name != "" {
+				declared[fn.Name]++
+			}
+		}
+	}
+	assigned := make(map[*typecheck.Obj]bool)
+	syntax.Walk(p.pkg.Syntax, func(c *syntax.Cursor) bool {
+		var lhs []expr.Expr
+		switch node := c.Node.(type) {
+		case *stmt.Assign:
+			lhs = node.Left
+		case *expr.Unary:
+			if node.Op == token.Ref {
+				lhs = []expr.Expr{node.Expr}
+			}
+		}
+		for _, e := range lhs {
+			if ident, ok := e.(*expr.Ident); ok {
+				assigned[p.c.Ident(ident)] = true
+			}
+		}
+		return true
+	}, nil)
+	var topFuncs []*expr.FuncLiteral
+	for _, s := range p.pkg.Syntax.Stmts {
+		s, ok := s.(*stmt.Simple)
+		if !ok {
+			continue
+		}
+		fn, ok := s.Expr.(*expr.FuncLiteral)
+		if !ok || fn.Name == "" {
+			continue
+		}
+		mutable := declared[fn.Name] > 1 || assigned[p.pkg.GlobalNames[fn.Name]]
+		if !mutable || len(fn.Type.TypeParams) > 0 || test && isTestFunc(fn) {
+			p.topFuncs[fn] = true
+			topFuncs = append(topFuncs, fn)
+		}
+	}
 	usesShell := false
+	usesTable := false
+	shellFuncs := make(map[string]*expr.ShellFuncDef)
+	usesBigInt := false
 	builtins := make(map[string]bool)
 	importPaths := []string{}
 	preFn := func(c *syntax.Cursor) bool {
@@ -72,6 +149,16 @@ package %s
 			}
 		case *expr.ShellList:
 			usesShell = true
+		case *expr.TableIndex:
+			usesTable = true
+		case *expr.ShellFuncDef:
+			if gotoken.IsIdentifier(node.Name) {
+				shellFuncs[node.Name] = node // the last definition wins
+			}
+		case *expr.Shell:
+			if len(shellBigInts(node)) > 0 {
+				usesBigInt = true
+			}
 		}
 		return true
 	}
@@ -95,44 +182,35 @@ package %s
 		p.imports[p.c.Pkg(imp).Type] = name
 	}
 
-	p.printf("import (")
-	p.indent++
-
-	if builtins["printf"] || builtins["print"] || builtins["errorf"] {
-		p.newline()
-		p.printf(`"fmt"`)
+	if builtins["printf"] || builtins["print"] || builtins["errorf"] || usesShell || usesTable {
+		pkg.imports = append(pkg.imports, goImport{path: "fmt"})
+	}
+	if usesTable && !usesShell {
+		pkg.imports = append(pkg.imports, goImport{path: "reflect"})
 	}
 	if usesShell {
-		p.newline()
-		p.printf(`"fmt"`)
-		p.newline()
-		p.printf(`"os"`)
-		p.newline()
-		p.printf(`"reflect"`)
-		p.newline()
-		p.printf(`"neugram.io/ng/eval/environ"`)
-		p.newline()
-		p.printf(`"neugram.io/ng/eval/shell"`)
-		p.newline()
-		p.printf(`"neugram.io/ng/syntax/expr"`)
-		p.newline()
-		p.printf(`"neugram.io/ng/syntax/src"`)
-		p.newline()
-		p.printf(`"neugram.io/ng/syntax/token"`)
+		for _, ipath := range []string{
+			"os",
+			"reflect",
+			"neugram.io/ng/eval/environ",
+			"neugram.io/ng/eval/shell",
+			"neugram.io/ng/syntax/expr",
+			"neugram.io/ng/syntax/src",
+			"neugram.io/ng/syntax/token",
+		} {
+			pkg.imports = append(pkg.imports, goImport{path: ipath})
+		}
+	}
+	if usesBigInt {
+		pkg.imports = append(pkg.imports, goImport{path: "math/big"})
 	}
-
-	// Stable output is ensured by gofmt's sorting later.
 	for name, imp := range namedImports {
-		p.newline()
-		p.printf("%s %q", name, imp)
+		pkg.imports = append(pkg.imports, goImport{name: name, path: imp})
 	}
 
-	p.indent--
-	p.newline()
-	p.print(")")
-	p.newline()
-	p.newline()
-
+	// The imports, types and package-level variables are
+	// written to main.go.
+	p.buf = pkg.file("main.go")
 	if outGoPkgName == "main" {
 		p.printf("func main() {}")
 		p.newline()
@@ -143,13 +221,23 @@ package %s
 	for _, obj := range p.pkg.Globals {
 		switch obj.Kind {
 		case typecheck.ObjType:
+			if decl, ok := obj.Decl.(*stmt.TypeDecl); ok && decl.Alias {
+				p.printf("type %s = ", obj.Name)
+				p.tipe(obj.Type)
+				break
+			}
 			n := obj.Type.(*tipe.Named)
 			if len(n.Methods) > 0 {
 				continue // methodiks are hoisted elsewhere
 			}
-			p.printf("type %s ", obj.Name)
+			p.printf("type %s", obj.Name)
+			p.typeParams(n.TypeParams)
+			p.print(" ")
 			p.tipe(n.Type)
 		case typecheck.ObjVar:
+			if fn, isFunc := obj.Decl.(*expr.FuncLiteral); isFunc && p.topFuncs[fn] {
+				continue // emitted after init
+			}
 			p.printf("var %s ", obj.Name)
 			p.tipe(obj.Type)
 		case typecheck.ObjConst:
@@ -197,23 +285,56 @@ package %s
 		m := methodiksFlat[name]
 		p.printf("// methodik %s", m.Name)
 		p.newline()
-		p.printf("type %s %s", m.Name, format.Type(m.Type.Type))
+		recvTypeName := m.Name
+		if ptr, isPtr := m.Type.Type.(*tipe.Pointer); isPtr {
+			// Go does not allow methods on a named pointer type.
+			// Instead name the element type, put the methods on
+			// a pointer to it, and make the methodik an alias
+			// for that pointer.
+			recvTypeName = "gengo_methodik_" + m.Name
+			p.printf("type %s = *%s", m.Name, recvTypeName)
+			p.newline()
+			p.newline()
+			p.printf("type %s %s", recvTypeName, format.Type(ptr.Elem))
+		} else {
+			p.printf("type %s %s", m.Name, format.Type(m.Type.Type))
+		}
 		p.newline()
 		p.newline()
 		for _, method := range m.Methods {
-			p.funcLiteral(method, m.Name)
+			ptrRecv := method.PointerReceiver
+			if recvTypeName != m.Name {
+				if ptrRecv {
+					return nil, fmt.Errorf("gengo: methodik %s: pointer receiver %s on pointer type", m.Name, method.ReceiverName)
+				}
+				ptrRecv = true
+			}
+			p.funcLiteral(method, recvTypeName, ptrRecv)
 			p.newline()
 			p.newline()
 		}
 	}
 
+	p.buf = pkg.file("init.go")
 	p.print("func init() {")
 	p.indent++
 	for _, s := range p.pkg.Syntax.Stmts {
-		switch s.(type) {
-		case *stmt.TypeDecl:
+		switch s := s.(type) {
+		case *stmt.TypeDecl, *stmt.TypeDeclSet:
 			// handled above
 			continue
+		case *stmt.Simple:
+			if fn, isFunc := s.Expr.(*expr.FuncLiteral); isFunc && p.topFuncs[fn] {
+				continue
+			}
+		case *stmt.VarSet:
+			for _, v := range s.Vars {
+				p.globalVarInit(v)
+			}
+			continue
+		case *stmt.Var:
+			p.globalVarInit(s)
+			continue
 		}
 
 		p.newline()
@@ -238,21 +359,49 @@ package %s
 	p.newline()
 	p.print("}")
 
+	// Each function hoisted out of init has its own file.
+	for _, fn := range topFuncs {
+		p.buf = pkg.file("func_" + fn.Name + "_gen.go")
+		p.funcLiteral(fn, "", false)
+	}
+
+	// The helpers gengo adds are written to support.go.
+	p.buf = pkg.file("support.go")
 	p.printBuiltins(builtins)
 	p.printEliders()
+	if usesTable {
+		p.printTable()
+	}
 	if usesShell {
 		p.printShell()
+		p.printShellFuncs(shellFuncs)
 	}
 
-	res, err := goformat.Source(p.buf.Bytes())
-	if err != nil {
-		lines := new(bytes.Buffer)
-		for i, line := range strings.Split(p.buf.String(), "\n") {
-			fmt.Fprintf(lines, "%3d: %s\n", i+1, line)
-		}
-		return nil, fmt.Errorf("gengo: bad generated source: %v\n%s", err, lines.String())
+	return pkg, nil
+}
+
+// A FormatError reports generated source that is not valid Go.
+// Source holds the unformatted output, for inspecting the bug.
+type FormatError struct {
+	Err    error
+	Source []byte
+}
+
+func (e *FormatError) Error() string {
+	lines := new(bytes.Buffer)
+	for i, line := range strings.Split(string(e.Source), "\n") {
+		fmt.Fprintf(lines, "%3d: %s\n", i+1, line)
 	}
+	return fmt.Sprintf("gengo: bad generated source: %v\n%s", e.Err, lines.String())
+}
 
+// formatSource gofmts src. If src is not valid Go, it returns src
+// unchanged and a *FormatError.
+func formatSource(src []byte) ([]byte, error) {
+	res, err := goformat.Source(src)
+	if err != nil {
+		return src, &FormatError{Err: err, Source: src}
+	}
 	return res, nil
 }
 
@@ -265,12 +414,74 @@ type printer struct {
 	pkg     *typecheck.Package
 	eliders map[tipe.Type]string
 
+	topFuncs map[*expr.FuncLiteral]bool // emitted as top-level Go functions
+
 	underlying      bool // always print underlying type
 	typeCur         *tipe.Named
 	typePlugins     map[*tipe.Named]string // plugin pkg path
 	typePluginsUsed map[*tipe.Named]bool
 }
 
+// shellBigInts returns the integer literals of the arithmetic
+// expansions in e. Their values are *big.Int.
+func shellBigInts(e expr.Expr) (lits []*expr.BasicLiteral) {
+	syntax.Walk(e, func(c *syntax.Cursor) bool {
+		if lit, ok := c.Node.(*expr.BasicLiteral); ok {
+			if _, isBig := lit.Value.(*big.Int); isBig {
+				lits = append(lits, lit)
+			}
+		}
+		return true
+	}, nil)
+	return lits
+}
+
+func (p *printer) printTable() {
+	p.newline()
+	p.newline()
+	p.printf(`// gengo_table_index returns the named columns of rows [i, j)
+// of the table t. If exact is set it returns row i.
+func gengo_table_index(t interface{}, names []string, i, j int, exact bool) interface{} {
+	table := reflect.ValueOf(t)
+	cols := table.Field(0).Interface().([]string)
+	colIndex := make([]int, len(names))
+	for k, name := range names {
+		colIndex[k] = -1
+		for c, col := range cols {
+			if col == name {
+				colIndex[k] = c
+				break
+			}
+		}
+		if colIndex[k] == -1 {
+			panic(fmt.Errorf("unknown column %%q", name))
+		}
+	}
+
+	rows := table.Field(1)
+	if exact {
+		j = i + 1
+	} else if j == -1 {
+		j = rows.Len()
+	}
+	rows = rows.Slice(i, j)
+
+	res := reflect.New(table.Type()).Elem()
+	res.Field(0).Set(reflect.ValueOf(append([]string(nil), names...)))
+	resRows := reflect.MakeSlice(rows.Type(), rows.Len(), rows.Len())
+	for r := 0; r < rows.Len(); r++ {
+		row := rows.Index(r)
+		resRow := reflect.MakeSlice(row.Type(), len(colIndex), len(colIndex))
+		for k, c := range colIndex {
+			resRow.Index(k).Set(row.Index(c))
+		}
+		resRows.Index(r).Set(resRow)
+	}
+	res.Field(1).Set(resRows)
+	return res.Interface()
+}`)
+}
+
 func (p *printer) printShell() {
 	p.newline()
 	p.newline()
@@ -295,7 +506,7 @@ func (p *printer) printShell() {
 	p.newline()
 	p.newline()
 	p.printf(`func gengo_shell(e *expr.Shell, p gengo_shell_params) (string, error) {
-	str, err := shell.Run(shellState, p, e)
+	str, err := shell.Run(shellState, p, e, nil)
 	return str, err
 }
 
@@ -310,14 +521,19 @@ func gengo_shell_elide(e *expr.Shell, p gengo_shell_params) string {
 type gengo_shell_params map[string]reflect.Value
 
 func (p gengo_shell_params) Get(name string) string {
+	val, _ := p.Lookup(name)
+	return val
+}
+
+func (p gengo_shell_params) Lookup(name string) (string, bool) {
 	if v, found := p[name]; found {
 		vi := v.Interface()
 		if s, ok := vi.(string); ok {
-			return s
+			return s, true
 		}
-		return fmt.Sprint(vi)
+		return fmt.Sprint(vi), true
 	}
-	return shellState.Env.Get(name)
+	return shellState.Env.Lookup(name)
 }
 
 func (p gengo_shell_params) Set(name, value string) {
@@ -337,6 +553,42 @@ func init() { shell.Init() }
 `)
 }
 
+// printShellFuncs prints the shell functions defined by the program
+// as methods of shellFuncs, so Go code in the generated package can
+// call them. The parameters of such a call come from the environment:
+// the Neugram variables in scope where a function is defined are not
+// visible to it.
+func (p *printer) printShellFuncs(funcs map[string]*expr.ShellFuncDef) {
+	if len(funcs) == 0 {
+		return
+	}
+	p.newline()
+	p.newline()
+	p.printf(`// gengo_shell_funcs runs the shell functions of the program
+// in shellState.
+type gengo_shell_funcs struct {
+	state *shell.State
+}
+
+var shellFuncs = gengo_shell_funcs{state: shellState}`)
+
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p.newline()
+		p.newline()
+		p.printf("var gengo_shellfunc_%s = %s", name, goValue(funcs[name]))
+		p.newline()
+		p.newline()
+		p.printf(`func (f gengo_shell_funcs) %s(args ...string) (string, error) {
+	return shell.Call(f.state, gengo_shell_params{}, gengo_shellfunc_%s, args...)
+}`, name, name)
+	}
+}
+
 func (p *printer) printBuiltins(builtins map[string]bool) {
 	if builtins["print"] {
 		p.newline()
@@ -492,7 +744,7 @@ func (p *printer) expr(e expr.Expr) {
 				p.printf("%s := ", e.Name)
 			}
 		}
-		p.funcLiteral(e, "")
+		p.funcLiteral(e, "", false)
 	case *expr.Ident:
 		if pkgType, isPkg := p.c.Type(e).(*tipe.Package); isPkg {
 			p.print(p.imports[pkgType])
@@ -527,6 +779,21 @@ func (p *printer) expr(e expr.Expr) {
 		p.expr(e.Left)
 		p.print(".")
 		p.expr(e.Right)
+	case *expr.Range:
+		// x[low..high] is x[low:high], x[low..=high] is x[low:high+1].
+		if e.Start != nil {
+			p.expr(e.Start)
+		}
+		p.print(":")
+		if e.End != nil {
+			if e.Inclusive {
+				p.print("(")
+				p.expr(e.End)
+				p.print(")+1")
+			} else {
+				p.expr(e.End)
+			}
+		}
 	case *expr.Slice:
 		if e.Low != nil {
 			p.expr(e.Low)
@@ -541,9 +808,9 @@ func (p *printer) expr(e expr.Expr) {
 		}
 	case *expr.Shell:
 		if e.ElideError {
-			p.printf("gengo_shell_elide(%s, gengo_shell_params{", format.Debug(e))
+			p.printf("gengo_shell_elide(%s, gengo_shell_params{", goValue(e))
 		} else {
-			p.printf("gengo_shell(%s, gengo_shell_params{", format.Debug(e))
+			p.printf("gengo_shell(%s, gengo_shell_params{", goValue(e))
 		}
 		if len(e.FreeVars) > 0 {
 			p.indent++
@@ -599,6 +866,95 @@ func (p *printer) expr(e expr.Expr) {
 			}
 		}
 		p.print("}")
+	case *expr.TableLiteral:
+		p.tipe(e.Type)
+		p.print("{Cols: []string{")
+		for i, col := range e.ColNames {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.expr(col)
+		}
+		p.print("}, Rows: [][]")
+		p.tipe(e.Type.Type)
+		p.print("{")
+		p.indent++
+		for _, row := range e.Rows {
+			p.newline()
+			p.print("{")
+			for i, elem := range row {
+				if i > 0 {
+					p.print(", ")
+				}
+				p.expr(elem)
+			}
+			p.print("},")
+		}
+		p.indent--
+		p.newline()
+		p.print("}}")
+	case *expr.TableIndex:
+		// gengo_table_index(x, cols, start, end, exact).(T),
+		// where an end of -1 is the number of rows.
+		p.print("gengo_table_index(")
+		p.expr(e.Expr)
+		p.print(", []string{")
+		for i, name := range e.ColNames {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.print(strconv.Quote(name))
+		}
+		p.print("}, ")
+		switch r := e.Rows; {
+		case r.Exact != nil:
+			p.expr(r.Exact)
+			p.print(", -1, true")
+		default:
+			if r.Start != nil {
+				p.expr(r.Start)
+			} else {
+				p.print("0")
+			}
+			p.print(", ")
+			if r.End != nil {
+				p.print("(")
+				p.expr(r.End)
+				p.print(")")
+				if r.Inclusive {
+					p.print("+1")
+				}
+			} else {
+				p.print("-1")
+			}
+			p.print(", false")
+		}
+		p.print(").(")
+		p.tipe(p.c.Type(e))
+		p.print(")")
+	case *expr.IfExpr:
+		// Go has no if expression, so call a func literal.
+		p.print("func() ")
+		p.tipe(p.c.Type(e))
+		p.print(" {")
+		p.indent++
+		p.newline()
+		p.print("if ")
+		p.expr(e.Cond)
+		p.print(" {")
+		p.indent++
+		p.newline()
+		p.print("return ")
+		p.expr(e.Then)
+		p.indent--
+		p.newline()
+		p.print("}")
+		p.newline()
+		p.print("return ")
+		p.expr(e.Else)
+		p.indent--
+		p.newline()
+		p.print("}()")
 	case *expr.Type:
 		p.tipe(e.Type)
 	case *expr.TypeAssert:
@@ -613,9 +969,10 @@ func (p *printer) expr(e expr.Expr) {
 	case *expr.Unary:
 		p.print(e.Op.String())
 		p.expr(e.Expr)
-		if e.Op == token.LeftParen {
-			p.print(")")
-		}
+	case *expr.Paren:
+		p.print("(")
+		p.expr(e.Expr)
+		p.print(")")
 	}
 }
 
@@ -760,6 +1117,9 @@ func (p *printer) stmt(s stmt.Stmt) {
 		p.expr(s.Value)
 	case *stmt.TypeDecl:
 		p.printf("type %s ", s.Name)
+		if s.Alias {
+			p.print("= ")
+		}
 		p.tipe(s.Type.Type)
 	case *stmt.TypeDeclSet:
 		p.print("type (")
@@ -767,6 +1127,9 @@ func (p *printer) stmt(s stmt.Stmt) {
 		for _, t := range s.TypeDecls {
 			p.newline()
 			p.printf("%s ", t.Name)
+			if t.Alias {
+				p.print("= ")
+			}
 			p.tipe(t.Type.Type)
 		}
 		p.indent--
@@ -775,10 +1138,9 @@ func (p *printer) stmt(s stmt.Stmt) {
 	case *stmt.MethodikDecl:
 		// lifted to top-level earlier
 	case *stmt.Labeled:
-		p.indent--
-		p.newline()
+		// The label is printed at the statement's indentation,
+		// gofmt moves it out to the enclosing block's.
 		p.printf("%s:", s.Label)
-		p.indent++
 		p.newline()
 		p.stmt(s.Stmt)
 	case *stmt.Branch:
@@ -880,7 +1242,7 @@ func (p *printer) stmt(s stmt.Stmt) {
 
 func (p *printer) isPure(e expr.Expr) bool {
 	switch e := e.(type) {
-	case *expr.Binary, *expr.Unary, *expr.Selector, *expr.Slice, *expr.CompLiteral, *expr.MapLiteral, *expr.ArrayLiteral, *expr.SliceLiteral, *expr.TableLiteral, *expr.Ident:
+	case *expr.Binary, *expr.Unary, *expr.Paren, *expr.Selector, *expr.Slice, *expr.CompLiteral, *expr.MapLiteral, *expr.ArrayLiteral, *expr.SliceLiteral, *expr.TableLiteral, *expr.Ident:
 		return true
 	case *expr.FuncLiteral:
 		return e.Name == ""
@@ -919,6 +1281,24 @@ func (p *printer) stmtConst(s *stmt.Const) {
 	}
 }
 
+// globalVarInit prints the initialization of a package-level var
+// inside init. The variable itself is declared, zero-valued, at the
+// top level, so only the assignment of its values remains.
+func (p *printer) globalVarInit(s *stmt.Var) {
+	if len(s.Values) == 0 {
+		return
+	}
+	p.newline()
+	p.print(strings.Join(s.NameList, ", "))
+	p.print(" = ")
+	for i, e := range s.Values {
+		if i != 0 {
+			p.print(", ")
+		}
+		p.expr(e)
+	}
+}
+
 func (p *printer) stmtVar(s *stmt.Var) {
 	for i, n := range s.NameList {
 		if i != 0 {
@@ -957,17 +1337,17 @@ func (p *printer) tipe(t tipe.Type) {
 		p.indent++
 		maxlen := 0
 		for _, sf := range t.Fields {
-			if len(sf.Name) > maxlen {
+			if !sf.Embedded && len(sf.Name) > maxlen {
 				maxlen = len(sf.Name)
 			}
 		}
 		for _, sf := range t.Fields {
 			p.newline()
-			name := sf.Name
-			if name == "" {
-				name = "*ERROR*No*Name*"
-			}
 			if !sf.Embedded {
+				name := sf.Name
+				if name == "" {
+					name = "*ERROR*No*Name*"
+				}
 				p.print(name)
 				for i := len(name); i <= maxlen; i++ {
 					p.print(" ")
@@ -975,7 +1355,8 @@ func (p *printer) tipe(t tipe.Type) {
 			}
 			p.tipe(sf.Type)
 			if sf.Tag != "" {
-				p.printf(" %q", sf.Tag)
+				p.print(" ")
+				p.print(format.StructTag(sf.Tag))
 			}
 		}
 		p.indent--
@@ -1003,6 +1384,28 @@ func (p *printer) tipe(t tipe.Type) {
 		} else {
 			p.print(t.Name)
 		}
+		if len(t.TypeArgs) > 0 {
+			p.print("[")
+			for i, arg := range t.TypeArgs {
+				if i > 0 {
+					p.print(", ")
+				}
+				p.tipe(arg)
+			}
+			p.print("]")
+		}
+	case *tipe.TypeParam:
+		p.print(t.Name)
+	case *tipe.Union:
+		for i, term := range t.Terms {
+			if i > 0 {
+				p.print(" | ")
+			}
+			if term.Tilde {
+				p.print("~")
+			}
+			p.tipe(term.Type)
+		}
 	case *tipe.Pointer:
 		p.print("*")
 		p.tipe(t.Elem)
@@ -1021,13 +1424,29 @@ func (p *printer) tipe(t tipe.Type) {
 	case *tipe.Slice:
 		p.print("[]")
 		p.tipe(t.Elem)
+	case *tipe.Table:
+		// The same layout as the evaluator's tables.
+		p.print("struct {")
+		p.indent++
+		p.newline()
+		p.print("Cols []string")
+		p.newline()
+		p.print("Rows [][]")
+		p.tipe(t.Type)
+		p.indent--
+		p.newline()
+		p.print("}")
 	case *tipe.Interface:
-		if len(t.Methods) == 0 {
+		if len(t.Methods) == 0 && len(t.Embeds) == 0 {
 			p.print("interface{}")
 			return
 		}
 		p.print("interface {")
 		p.indent++
+		for _, e := range t.Embeds {
+			p.newline()
+			p.tipe(e)
+		}
 		names := make([]string, 0, len(t.Methods))
 		for name := range t.Methods {
 			names = append(names, name)
@@ -1055,7 +1474,13 @@ func (p *printer) tipe(t tipe.Type) {
 			p.print("<-")
 		}
 		p.print(" ")
-		p.tipe(t.Elem)
+		if format.ChanElemParens(t) {
+			p.print("(")
+			p.tipe(t.Elem)
+			p.print(")")
+		} else {
+			p.tipe(t.Elem)
+		}
 	case *tipe.Func:
 		p.print("func")
 		p.tipeFuncSig(t)
@@ -1078,13 +1503,34 @@ func (p *printer) tipe(t tipe.Type) {
 	}
 }
 
+// typeParams prints a type parameter list, [K, V any].
+func (p *printer) typeParams(params []*tipe.TypeParam) {
+	if len(params) == 0 {
+		return
+	}
+	p.print("[")
+	for i, tp := range params {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.print(tp.Name)
+		p.print(" ")
+		p.tipe(tp.Constraint)
+	}
+	p.print("]")
+}
+
 func (p *printer) tipeFuncSig(t *tipe.Func) {
 	p.print("(")
 	if t.Params != nil {
-		for i, elem := range t.Params.Elems {
+		for i := range t.Params.Elems {
 			if i > 0 {
 				p.print(", ")
 			}
+			elem, variadic := format.ParamType(t, i)
+			if variadic {
+				p.print("...")
+			}
 			p.tipe(elem)
 		}
 	}
@@ -1106,13 +1552,19 @@ func (p *printer) tipeFuncSig(t *tipe.Func) {
 	}
 }
 
-func (p *printer) funcLiteral(e *expr.FuncLiteral, recvTypeName string) {
+// funcLiteral prints the function e. If recvTypeName is set, e is
+// printed as a method of that type, on a pointer if ptrRecv is set.
+func (p *printer) funcLiteral(e *expr.FuncLiteral, recvTypeName string, ptrRecv bool) {
 	if recvTypeName != "" {
 		ptr := ""
-		if e.PointerReceiver {
+		if ptrRecv {
 			ptr = "*"
 		}
 		p.printf("func (%s %s%s) %s(", e.ReceiverName, ptr, recvTypeName, e.Name)
+	} else if p.topFuncs[e] {
+		p.printf("func %s", e.Name)
+		p.typeParams(e.Type.TypeParams)
+		p.print("(")
 	} else {
 		p.print("func(")
 	}
@@ -1144,14 +1596,43 @@ func (p *printer) funcLiteral(e *expr.FuncLiteral, recvTypeName string) {
 }
 
 func (p *printer) elider(t tipe.Type) string {
-	name := p.eliders[t]
-	if name == "" {
-		name = fmt.Sprintf("gengo_elider%d", len(p.eliders))
-		p.eliders[t] = name
+	// Distinct calls can have equal but distinct result types,
+	// so look for an existing elider by structural equality.
+	for et, name := range p.eliders {
+		if tipe.Equal(et, t) {
+			return name
+		}
 	}
+	name := fmt.Sprintf("gengo_elider%d", len(p.eliders))
+	p.eliders[t] = name
 	return name
 }
 
+// isTestFunc reports whether fn has the form of a Go test function:
+// it is named TestXxx and takes a single *testing.T.
+func isTestFunc(fn *expr.FuncLiteral) bool {
+	if !strings.HasPrefix(fn.Name, "Test") {
+		return false
+	}
+	if rest := fn.Name[len("Test"):]; rest != "" {
+		if ch, _ := utf8.DecodeRuneInString(rest); unicode.IsLower(ch) {
+			return false
+		}
+	}
+	if fn.Type.Variadic || fn.Type.Params == nil || len(fn.Type.Params.Elems) != 1 {
+		return false
+	}
+	if fn.Type.Results != nil && len(fn.Type.Results.Elems) != 0 {
+		return false
+	}
+	ptr, ok := fn.Type.Params.Elems[0].(*tipe.Pointer)
+	if !ok {
+		return false
+	}
+	t, ok := ptr.Elem.(*tipe.Named)
+	return ok && t.PkgPath == "testing" && t.Name == "T"
+}
+
 func isExported(name string) bool {
 	ch, _ := utf8.DecodeRuneInString(name)
 	return unicode.IsUpper(ch)
@@ -1181,7 +1662,6 @@ func GenNamedType(t *tipe.Named, methods []*expr.FuncLiteral, pkgPath string, ty
 
 		m := new(expr.FuncLiteral)
 		*m = *mOrig
-		m.PointerReceiver = true
 		for i := range m.ParamNames {
 			if m.ParamNames[i] == "" {
 				m.ParamNames[i] = fmt.Sprintf("gengo_param_%d", i)
@@ -1193,7 +1673,7 @@ func GenNamedType(t *tipe.Named, methods []*expr.FuncLiteral, pkgPath string, ty
 			}
 		}
 		m.Body = nil
-		p.funcLiteral(m, t.Name)
+		p.funcLiteral(m, t.Name, true)
 		p.printf(" {")
 		p.indent++
 		p.newline()
@@ -1211,7 +1691,12 @@ func GenNamedType(t *tipe.Named, methods []*expr.FuncLiteral, pkgPath string, ty
 			p.newline()
 			p.printf("gengo_out := ")
 		}
-		p.printf("Type_Method_%s.Call(gengo_in)", m.Name)
+		call := "Call"
+		if m.Type.Variadic {
+			// The final parameter is already a slice.
+			call = "CallSlice"
+		}
+		p.printf("Type_Method_%s.%s(gengo_in)", m.Name, call)
 		for i, name := range m.ResultNames {
 			p.newline()
 			p.printf("res = gengo_out[%d].Interface()", i)
@@ -7,6 +7,7 @@ package gengo_test
 import (
 	"bytes"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -24,10 +25,16 @@ func TestGeneratedPrograms(t *testing.T) {
 	if len(files) == 0 {
 		t.Fatal("cannot find testdata")
 	}
+	// Programs in testdata use features the evaluator lacks.
+	gengoFiles, err := filepath.Glob("testdata/*.ng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, gengoFiles...)
 
 	for _, file := range files {
 		file := file
-		test := file[len("../eval/testdata/") : len(file)-3]
+		test := strings.TrimSuffix(filepath.Base(file), ".ng")
 		exclude := []string{ // TODO remove this list
 			"import3",
 			"error6",
@@ -108,3 +115,194 @@ func TestGeneratedPrograms(t *testing.T) {
 		})
 	}
 }
+
+var generatedSourceTests = []struct {
+	file    string
+	want    []string
+	notWant []string
+}{
+	{
+		// Each elided call shares the one helper for (int, error).
+		file:    "../eval/testdata/elide1.ng",
+		want:    []string{"func gengo_elider0("},
+		notWant: []string{"func gengo_elider1("},
+	},
+	{
+		file:    "../eval/testdata/func12.ng",
+		want:    []string{"func fact(n int) int {"},
+		notWant: []string{"var fact"},
+	},
+	{
+		// Functions that are assigned to stay variables.
+		file: "../eval/testdata/func13.ng",
+		want: []string{"var fact func(int) int", "var count func(int) int"},
+	},
+	{
+		// The address of a composite literal is taken directly.
+		file: "../eval/testdata/ptr2.ng",
+		want: []string{"p = &Point{", "r = &Point{4, 5}"},
+	},
+	{
+		// Arithmetic literals are printed as big ints.
+		file: "../eval/testdata/shell14.ng",
+		want: []string{`"math/big"`, "big.NewInt(2)", "big.NewInt(3)"},
+	},
+	{
+		file: "../eval/testdata/shell27_panic.ng",
+		want: []string{`new(big.Int).SetString("99999999999999999999", 10)`, "big.NewInt(1)"},
+	},
+	{
+		file:    "../eval/testdata/generic1.ng",
+		want:    []string{"func F[T any](x T) T {", "type Pair[K any, V any] struct {"},
+		notWant: []string{"var F"},
+	},
+}
+
+func TestGeneratedSource(t *testing.T) {
+	for _, test := range generatedSourceTests {
+		res, err := gengo.GenGo(test.file, "main")
+		if err != nil {
+			t.Errorf("%s: %v", test.file, err)
+			continue
+		}
+		for _, want := range test.want {
+			if !bytes.Contains(res, []byte(want)) {
+				t.Errorf("%s: generated code missing %q:\n%s", test.file, want, res)
+			}
+		}
+		for _, notWant := range test.notWant {
+			if bytes.Contains(res, []byte(notWant)) {
+				t.Errorf("%s: generated code contains %q:\n%s", test.file, notWant, res)
+			}
+		}
+	}
+}
+
+// goRun writes files to a new Go module in a temporary directory and
+// runs the go command with args there, returning its output.
+func goRun(t *testing.T, files map[string][]byte, args ...string) []byte {
+	dir, err := ioutil.TempDir("", "gengo-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files["go.mod"] = []byte("module gengotest\n")
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), src, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return out
+}
+
+func TestGenGoTest(t *testing.T) {
+	res, err := gengo.GenGoTest("testdata/gotest/double.ng", "double")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := goRun(t, map[string][]byte{"double_test.go": res}, "test", "-v", ".")
+	if !bytes.Contains(out, []byte("--- PASS: TestDouble")) {
+		t.Errorf("TestDouble did not run:\n%s", out)
+	}
+}
+
+func TestGenGoPackage(t *testing.T) {
+	files, err := gengo.GenGoPackage("testdata/pkg1.ng", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFiles := map[string][]string{
+		"main.go":            {"type T struct"},
+		"init.go":            {"func init() {"},
+		"func_upper_gen.go":  {`"strings"`, "func upper(s string) string {"},
+		"func_double_gen.go": {"func double(x int) int {"},
+		"support.go":         {`"fmt"`, "func printf("},
+	}
+	for name, wants := range wantFiles {
+		res, ok := files[name]
+		if !ok {
+			t.Errorf("missing file %s", name)
+			continue
+		}
+		for _, want := range wants {
+			if !bytes.Contains(res, []byte(want)) {
+				t.Errorf("%s missing %q:\n%s", name, want, res)
+			}
+		}
+	}
+	if len(files) != len(wantFiles) {
+		t.Errorf("got %d files, want %d", len(files), len(wantFiles))
+	}
+
+	if out := goRun(t, files, "run", "."); string(out) != "OK\n" {
+		t.Errorf("output %q, want %q", out, "OK\n")
+	}
+}
+
+func TestGoValue(t *testing.T) {
+	two := 2
+	on := true
+	s := "log"
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{&two, "&[]int{int(2)}[0]"},
+		{&on, "&[]bool{bool(true)}[0]"},
+		{&s, `&[]string{"log"}[0]`},
+		{big.NewInt(3), "big.NewInt(3)"},
+	}
+	for _, test := range tests {
+		if got := gengo.GoValue(test.v); got != test.want {
+			t.Errorf("GoValue(%T)=%q, want %q", test.v, got, test.want)
+		}
+	}
+}
+
+func TestFormatSourceError(t *testing.T) {
+	src := []byte("package main\n\nfunc main() {\n\tx := \n}\n")
+	res, err := gengo.FormatSource(src)
+	if !bytes.Equal(res, src) {
+		t.Errorf("FormatSource=%q, want the unformatted source %q", res, src)
+	}
+	ferr, ok := err.(*gengo.FormatError)
+	if !ok {
+		t.Fatalf("error is %T, want *gengo.FormatError: %v", err, err)
+	}
+	if !bytes.Equal(ferr.Source, src) {
+		t.Errorf("FormatError.Source=%q, want %q", ferr.Source, src)
+	}
+	if want := "  4: \tx := "; !strings.Contains(ferr.Error(), want) {
+		t.Errorf("error %q does not contain numbered line %q", ferr.Error(), want)
+	}
+
+	res, err = gengo.FormatSource([]byte("package main\nfunc main() {  }\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package main\n\nfunc main() {}\n"; string(res) != want {
+		t.Errorf("FormatSource=%q, want %q", res, want)
+	}
+}
+
+func TestGenGoFormatError(t *testing.T) {
+	// An invalid package name makes the generated source invalid.
+	res, err := gengo.GenGo("../eval/testdata/func12.ng", "bad-name")
+	ferr, ok := err.(*gengo.FormatError)
+	if !ok {
+		t.Fatalf("error is %T, want *gengo.FormatError: %v", err, err)
+	}
+	if !bytes.Equal(res, ferr.Source) {
+		t.Errorf("GenGo result is not the unformatted source:\n%s", res)
+	}
+	if want := "  3: package bad-name\n"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain numbered line %q", err, want)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gengo
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// goValue returns a Go expression that evaluates to x.
+//
+// It is used to write the syntax trees of shell expressions into
+// generated programs, which run them with the shell package. The
+// types of x must be exported from packages the program imports.
+func goValue(x interface{}) string {
+	p := valuePrinter{buf: new(bytes.Buffer)}
+	p.printv(reflect.ValueOf(x))
+	return p.buf.String()
+}
+
+var bigIntType = reflect.TypeOf((*big.Int)(nil))
+
+type valuePrinter struct {
+	buf    *bytes.Buffer
+	indent int
+}
+
+func (p *valuePrinter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(p.buf, format, args...)
+}
+
+func (p *valuePrinter) newline() {
+	p.buf.WriteByte('\n')
+	for i := 0; i < p.indent; i++ {
+		p.buf.WriteByte('\t')
+	}
+}
+
+func (p *valuePrinter) printv(v reflect.Value) {
+	if !v.IsValid() {
+		p.printf("nil")
+		return
+	}
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		if v.IsNil() {
+			p.printf("nil")
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.Type() == bigIntType {
+			x := v.Interface().(*big.Int)
+			if x.IsInt64() {
+				p.printf("big.NewInt(%d)", x.Int64())
+			} else {
+				p.printf("func() *big.Int { x, _ := new(big.Int).SetString(%q, 10); return x }()", x.String())
+			}
+			return
+		}
+		if isBasicKind(v.Elem().Kind()) {
+			// Go cannot take the address of a conversion,
+			// so index a slice literal, as in &[]int{int(2)}[0].
+			p.printf("&[]%s{", v.Elem().Type())
+			p.printv(v.Elem())
+			p.printf("}[0]")
+			return
+		}
+		p.printf("&")
+		p.printv(v.Elem())
+	case reflect.Interface:
+		p.printv(v.Elem())
+	case reflect.Map:
+		p.printf("%s{", v.Type())
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		if len(keys) > 0 {
+			p.indent++
+			for _, key := range keys {
+				p.newline()
+				p.printv(key)
+				p.printf(": ")
+				p.printv(v.MapIndex(key))
+				p.buf.WriteByte(',')
+			}
+			p.indent--
+			p.newline()
+		}
+		p.buf.WriteByte('}')
+	case reflect.Slice, reflect.Array:
+		p.printf("%s{", v.Type())
+		if v.Len() > 0 {
+			p.indent++
+			for i := 0; i < v.Len(); i++ {
+				p.newline()
+				p.printv(v.Index(i))
+				p.buf.WriteByte(',')
+			}
+			p.indent--
+			p.newline()
+		}
+		p.buf.WriteByte('}')
+	case reflect.Struct:
+		t := v.Type()
+		p.printf("%s{", t)
+		if v.NumField() > 0 {
+			p.indent++
+			for i := 0; i < v.NumField(); i++ {
+				if isZero(v.Field(i)) {
+					continue
+				}
+				p.newline()
+				p.printf("%s: ", t.Field(i).Name)
+				p.printv(v.Field(i))
+				p.buf.WriteByte(',')
+			}
+			p.indent--
+			p.newline()
+		}
+		p.buf.WriteByte('}')
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		p.printf("%s(%#v)", v.Type(), v)
+	case reflect.String:
+		if v.Type().Name() == "string" && v.Type().PkgPath() == "" {
+			p.printf("%q", v.String())
+		} else {
+			p.printf("%s(%q)", v.Type(), v.String())
+		}
+	default:
+		panic(fmt.Sprintf("gengo: cannot write %s value as Go", v.Type()))
+	}
+}
+
+func isBasicKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return v.IsNil()
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isZero(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !isZero(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Complex64, reflect.Complex128:
+		return v.Complex() == 0
+	case reflect.String:
+		return v.String() == ""
+	}
+	return false
+}
@@ -57,6 +57,7 @@ func main() {
 	flagHelp := flag.Bool("h", false, "display help message and exit")
 	flagE := flag.String("e", "", "program passed as a string")
 	flagO := flag.String("o", "", "compile the program to the named file")
+	flagGroupDigits := flag.Bool("groupdigits", false, "group the digits of integer results with underscores")
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "usage: %s\n", usageLine)
 		os.Exit(1)
@@ -127,7 +128,7 @@ func main() {
 		exitf("-o specified but no program file provided")
 	}
 
-	loop(context.Background(), os.Args[0] == "ngsh" || os.Args[0] == "-ngsh" || *flagShell)
+	loop(context.Background(), os.Args[0] == "ngsh" || os.Args[0] == "-ngsh" || *flagShell, *flagGroupDigits)
 }
 
 func setWindowSize(env map[interface{}]interface{}) {
@@ -203,7 +204,7 @@ func initSession(ng *ngcore.Session) {
 	}()
 }
 
-func loop(ctx context.Context, startInShell bool) {
+func loop(ctx context.Context, startInShell, groupDigits bool) {
 	path := filepath.Join(cwd, "ng-interactive")
 	ng, err := ng.NewSession(ctx, path, os.Environ())
 	if err != nil {
@@ -212,6 +213,7 @@ func loop(ctx context.Context, startInShell bool) {
 	defer ng.Close()
 
 	initSession(ng)
+	ng.GroupDigits = groupDigits
 
 	err = ng.Run(ctx, startInShell, sigint)
 	if err != nil {
@@ -0,0 +1,61 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ngcore
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"neugram.io/ng/typecheck"
+)
+
+// goDoc returns the Go documentation for sym in the package importPath.
+// It is a variable so tests can avoid invoking the go tool.
+var goDoc = func(importPath, sym string) (string, error) {
+	out, err := exec.Command("go", "doc", importPath+"."+sym).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("go doc %s.%s: %v: %s", importPath, sym, err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// doc implements the :doc meta-command. The argument has the form
+// pkg.Sym, where pkg is either the name of a package imported in
+// the session or an import path.
+func (s *Session) doc(w io.Writer, arg string) error {
+	i := strings.LastIndexByte(arg, '.')
+	if i <= 0 || i == len(arg)-1 {
+		return fmt.Errorf("usage: :doc pkg.Sym")
+	}
+	pkgName, sym := arg[:i], arg[i+1:]
+
+	path := pkgName
+	if obj := s.Program.Types.Lookup(pkgName); obj != nil && obj.Kind == typecheck.ObjPkg {
+		path = obj.Decl.(*typecheck.Package).Path
+	}
+	objs, err := s.Program.Types.Exported(path)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, obj := range objs {
+		if obj.Name == sym {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s has no exported symbol %s", path, sym)
+	}
+
+	doc, err := goDoc(path, sym)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, doc)
+	return err
+}
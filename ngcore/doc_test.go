@@ -0,0 +1,58 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ngcore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDoc(t *testing.T) {
+	oldGoDoc := goDoc
+	defer func() { goDoc = oldGoDoc }()
+	goDoc = func(importPath, sym string) (string, error) {
+		if importPath != "strings" || sym != "ToUpper" {
+			t.Errorf("goDoc(%q, %q), want (\"strings\", \"ToUpper\")", importPath, sym)
+		}
+		return "func ToUpper(s string) string\n    ToUpper returns s with all Unicode letters mapped to their upper case.\n", nil
+	}
+
+	f, err := ioutil.TempFile("", "ngdoctest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	ng := New()
+	defer ng.Close()
+	session, err := ng.NewSession(context.Background(), "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	session.Stdout = f
+
+	if _, err := session.Exec([]byte(`import "strings"`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Exec([]byte(":doc strings.ToUpper")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Exec([]byte(":doc strings.NoSuchFunc")); err == nil {
+		t.Error(":doc strings.NoSuchFunc: want error")
+	}
+
+	out, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ToUpper returns s with all Unicode letters"; !strings.Contains(string(out), want) {
+		t.Errorf(":doc strings.ToUpper printed %q, want it to contain %q", out, want)
+	}
+}
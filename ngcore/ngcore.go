@@ -16,6 +16,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -75,6 +76,10 @@ type Session struct {
 	Stderr *os.File
 
 	ExecCount int // number of statements executed
+
+	// GroupDigits, if set, makes Display separate the digits of
+	// integer results into groups of three with underscores.
+	GroupDigits bool
 	// TODO: record execution statement history here
 
 	Liner   *liner.State
@@ -84,6 +89,7 @@ type Session struct {
 	}
 	name    string
 	neugram *Neugram
+	sigint  <-chan os.Signal // interrupts evaluation, set by Run
 }
 
 func (n *Neugram) NewSession(ctx context.Context, name string, env []string) (*Session, error) {
@@ -188,7 +194,7 @@ func (s *Session) Exec(src []byte) ([]reflect.Value, error) {
 	}
 	stderr := s.Stderr
 	if stderr == nil {
-		stdout, err = os.Create(os.DevNull)
+		stderr, err = os.Create(os.DevNull)
 		if err != nil {
 			return nil, err
 		}
@@ -196,6 +202,15 @@ func (s *Session) Exec(src []byte) ([]reflect.Value, error) {
 
 	s.ExecCount++
 
+	if s.ParserState == parser.StateUnknown || s.ParserState == parser.StateStmt {
+		if line := strings.TrimSpace(string(src)); strings.HasPrefix(line, ":doc ") {
+			if err := s.doc(stdout, strings.TrimSpace(line[len(":doc "):])); err != nil {
+				return nil, Error{Phase: "doc", List: []error{err}}
+			}
+			return nil, nil
+		}
+	}
+
 	res := s.Parser.ParseLine(src)
 	s.ParserState = res.State
 
@@ -208,7 +223,7 @@ func (s *Session) Exec(src []byte) ([]reflect.Value, error) {
 	}
 	var out []reflect.Value
 	for _, stmt := range res.Stmts {
-		v, err := s.Program.Eval(stmt, nil)
+		v, err := s.Program.Eval(stmt, s.sigint)
 		if err != nil {
 			str := err.Error()
 			if strings.HasPrefix(str, "typecheck: ") { // TODO: gross
@@ -231,13 +246,15 @@ func (s *Session) Exec(src []byte) ([]reflect.Value, error) {
 			Stdin:  s.Stdin,
 			Stdout: stdout,
 			Stderr: stderr,
+
+			Interrupt: s.sigint,
 		}
 		if err := j.Start(); err != nil {
 			fmt.Fprintln(stdout, err)
 			continue
 		}
 		done, err := j.Wait()
-		if err != nil {
+		if err != nil && j.ErrExit() {
 			return nil, Error{Phase: "shell", List: []error{err}}
 		}
 		if !done {
@@ -260,6 +277,12 @@ func (s *Session) Display(w io.Writer, vals []reflect.Value) {
 			fmt.Fprint(w, "<nil>")
 			continue
 		}
+		if s.GroupDigits {
+			if x := intValue(val); x != nil {
+				fmt.Fprint(w, groupDigits(x))
+				continue
+			}
+		}
 		switch v := val.Interface().(type) {
 		case eval.UntypedInt:
 			fmt.Fprint(w, v.String())
@@ -284,6 +307,44 @@ func (s *Session) Display(w io.Writer, vals []reflect.Value) {
 	}
 }
 
+// intValue returns the value of an integer result, or nil.
+// An integer type with a String method, such as time.Duration,
+// is not treated as an integer.
+func intValue(val reflect.Value) *big.Int {
+	switch v := val.Interface().(type) {
+	case eval.UntypedInt:
+		return v.Int
+	case fmt.Stringer:
+		return nil
+	}
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Int).SetUint64(val.Uint())
+	}
+	return nil
+}
+
+// groupDigits formats x in base 10 with an underscore
+// between each group of three digits, as in 1_000_000.
+func groupDigits(x *big.Int) string {
+	digits := x.String()
+	sign := ""
+	if x.Sign() < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var buf bytes.Buffer
+	buf.WriteString(sign)
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			buf.WriteByte('_')
+		}
+		buf.WriteRune(d)
+	}
+	return buf.String()
+}
+
 func (s *Session) Run(ctx context.Context, startInShell bool, sigint chan os.Signal) error {
 	state := parser.StateStmt
 	if startInShell {
@@ -304,6 +365,8 @@ func (s *Session) Run(ctx context.Context, startInShell bool, sigint chan os.Sig
 		}
 	}
 
+	s.sigint = sigint
+
 	s.Liner.SetTabCompletionStyle(liner.TabPrints)
 	s.Liner.SetWordCompleter(s.Completer)
 	s.Liner.SetCtrlCAborts(true)
@@ -0,0 +1,102 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ngcore
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInterruptShell(t *testing.T) {
+	ng := New()
+	defer ng.Close()
+	session, err := ng.NewSession(context.Background(), "interrupt", os.Environ())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	sigint := make(chan os.Signal)
+	session.sigint = sigint
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := session.Exec([]byte("$$ sleep 100 $$"))
+		done <- err
+	}()
+
+	// The signal may arrive before the process starts,
+	// so keep interrupting until the command exits.
+	timeout := time.After(10 * time.Second)
+loop:
+	for {
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("interrupted command did not report an error")
+			}
+			break loop
+		case sigint <- os.Interrupt:
+			time.Sleep(10 * time.Millisecond)
+		case <-timeout:
+			t.Fatal("command was not interrupted")
+		}
+	}
+
+	// The session survives the interrupt.
+	if _, err := session.Exec([]byte("x := 40 + 2")); err != nil {
+		t.Fatal(err)
+	}
+	res, err := session.Exec([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Interface() != 42 {
+		t.Errorf("after interrupt x=%v, want 42", res)
+	}
+}
+
+func TestDisplayGroupDigits(t *testing.T) {
+	ng := New()
+	defer ng.Close()
+	session, err := ng.NewSession(context.Background(), "groupdigits", os.Environ())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	display := func(src string) string {
+		res, err := session.Exec([]byte(src))
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		buf := new(bytes.Buffer)
+		session.Display(buf, res)
+		return buf.String()
+	}
+
+	if got, want := display("1000000"), "1000000\n"; got != want {
+		t.Errorf("default display: got %q, want %q", got, want)
+	}
+
+	session.GroupDigits = true
+	tests := []struct {
+		src, want string
+	}{
+		{"1000000", "1_000_000\n"},
+		{"int64(-1234567)", "-1_234_567\n"},
+		{"uint8(255)", "255\n"},
+		{"100000", "100_000\n"},
+		{`"1000000"`, "1000000\n"},
+		{`import "time"; time.Duration(1500000)`, "time.Duration(1500000 /* 1.5ms */)\n"},
+	}
+	for _, test := range tests {
+		if got := display(test.src); got != test.want {
+			t.Errorf("display(%s) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
@@ -39,6 +39,15 @@ func EqualExpr(x, y expr.Expr) bool {
 			return x == nil && y == nil
 		}
 		return x.Op == y.Op && EqualExpr(x.Expr, y.Expr)
+	case *expr.Paren:
+		y, ok := y.(*expr.Paren)
+		if !ok {
+			return false
+		}
+		if x == nil || y == nil {
+			return x == nil && y == nil
+		}
+		return EqualExpr(x.Expr, y.Expr)
 	case *expr.Bad:
 		y, ok := y.(*expr.Bad)
 		if !ok {
@@ -257,6 +266,44 @@ func EqualExpr(x, y expr.Expr) bool {
 			return false
 		}
 		return equalExprs(x.Indicies, y.Indicies)
+	case *expr.Range:
+		y, ok := y.(*expr.Range)
+		if !ok {
+			return false
+		}
+		if x == nil || y == nil {
+			return x == nil && y == nil
+		}
+		if x.Inclusive != y.Inclusive {
+			return false
+		}
+		if !EqualExpr(x.Start, y.Start) {
+			return false
+		}
+		if !EqualExpr(x.End, y.End) {
+			return false
+		}
+		return EqualExpr(x.Exact, y.Exact)
+	case *expr.TableIndex:
+		y, ok := y.(*expr.TableIndex)
+		if !ok {
+			return false
+		}
+		if x == nil || y == nil {
+			return x == nil && y == nil
+		}
+		if !EqualExpr(x.Expr, y.Expr) {
+			return false
+		}
+		if len(x.ColNames) != len(y.ColNames) {
+			return false
+		}
+		for i, name := range x.ColNames {
+			if name != y.ColNames[i] {
+				return false
+			}
+		}
+		return EqualExpr(&x.Rows, &y.Rows)
 	case *expr.TypeAssert:
 		y, ok := y.(*expr.TypeAssert)
 		if !ok {
@@ -269,6 +316,21 @@ func EqualExpr(x, y expr.Expr) bool {
 			return false
 		}
 		return tipe.EqualUnresolved(x.Type, y.Type)
+	case *expr.IfExpr:
+		y, ok := y.(*expr.IfExpr)
+		if !ok {
+			return false
+		}
+		if x == nil || y == nil {
+			return x == nil && y == nil
+		}
+		if !EqualExpr(x.Cond, y.Cond) {
+			return false
+		}
+		if !EqualExpr(x.Then, y.Then) {
+			return false
+		}
+		return EqualExpr(x.Else, y.Else)
 	case *expr.ShellList:
 		y, ok := y.(*expr.ShellList)
 		if !ok {
@@ -342,12 +404,33 @@ func EqualExpr(x, y expr.Expr) bool {
 		if x == nil || y == nil {
 			return x == nil && y == nil
 		}
+		if x.PipeStderr != y.PipeStderr {
+			return false
+		}
 		if !EqualExpr(x.SimpleCmd, y.SimpleCmd) {
 			return false
 		}
 		if !EqualExpr(x.Subshell, y.Subshell) {
 			return false
 		}
+		if !EqualExpr(x.FuncDef, y.FuncDef) {
+			return false
+		}
+		return true
+	case *expr.ShellFuncDef:
+		y, ok := y.(*expr.ShellFuncDef)
+		if !ok {
+			return false
+		}
+		if x == nil || y == nil {
+			return x == nil && y == nil
+		}
+		if x.Name != y.Name {
+			return false
+		}
+		if !EqualExpr(x.Body, y.Body) {
+			return false
+		}
 		return true
 	case *expr.ShellSimpleCmd:
 		y, ok := y.(*expr.ShellSimpleCmd)
@@ -381,6 +464,52 @@ func EqualExpr(x, y expr.Expr) bool {
 				return false
 			}
 		}
+		if len(x.CmdSubst) != len(y.CmdSubst) {
+			return false
+		}
+		for i, e := range x.CmdSubst {
+			if !EqualExpr(e, y.CmdSubst[i]) {
+				return false
+			}
+		}
+		if len(x.Arith) != len(y.Arith) {
+			return false
+		}
+		for i, e := range x.Arith {
+			if !EqualExpr(e, y.Arith[i]) {
+				return false
+			}
+		}
+		return true
+	case *expr.ShellCmdSubst:
+		y, ok := y.(*expr.ShellCmdSubst)
+		if !ok {
+			return false
+		}
+		if x == nil || y == nil {
+			return x == nil && y == nil
+		}
+		if x.Src != y.Src {
+			return false
+		}
+		if !EqualExpr(x.Cmd, y.Cmd) {
+			return false
+		}
+		return true
+	case *expr.ShellArith:
+		y, ok := y.(*expr.ShellArith)
+		if !ok {
+			return false
+		}
+		if x == nil || y == nil {
+			return x == nil && y == nil
+		}
+		if x.Src != y.Src {
+			return false
+		}
+		if !EqualExpr(x.Expr, y.Expr) {
+			return false
+		}
 		return true
 	case *expr.ShellRedirect:
 		y, ok := y.(*expr.ShellRedirect)
@@ -405,6 +534,9 @@ func EqualExpr(x, y expr.Expr) bool {
 		if x.Filename != y.Filename {
 			return false
 		}
+		if x.Heredoc != y.Heredoc {
+			return false
+		}
 		return true
 	case *expr.ShellAssign:
 		y, ok := y.(*expr.ShellAssign)
@@ -656,6 +788,9 @@ func EqualStmt(x, y stmt.Stmt) bool {
 		if x.Name != y.Name {
 			return false
 		}
+		if x.Alias != y.Alias {
+			return false
+		}
 		if !tipe.EqualUnresolved(x.Type, y.Type) {
 			return false
 		}
@@ -14,6 +14,7 @@ import (
 	"strconv"
 
 	"neugram.io/ng/format"
+	"neugram.io/ng/internal/bigcplx"
 	"neugram.io/ng/syntax"
 	"neugram.io/ng/syntax/expr"
 	"neugram.io/ng/syntax/src"
@@ -50,7 +51,7 @@ func (p *Parser) Parse(source []byte) (*syntax.File, error) {
 	scanner := bufio.NewScanner(bytes.NewReader(source))
 	for i := 0; scanner.Scan(); i++ {
 		b := scanner.Bytes()
-		if i == 0 && len(b) > 2 && b[0] == '#' && b[1] == '!' { // shebang
+		if i == 0 && bytes.HasPrefix(b, []byte("#!")) { // shebang
 			p.s.Line++
 			continue
 		}
@@ -83,6 +84,12 @@ func (p *Parser) Parse(source []byte) (*syntax.File, error) {
 			}
 			f.Stmts = append(f.Stmts, s)
 		}
+		for n, c := range res.Comments {
+			if f.Comments == nil {
+				f.Comments = make(map[syntax.Node][]string)
+			}
+			f.Comments[n] = c
+		}
 		if len(res.Errs) > 0 {
 			errs = append(errs, res.Errs...)
 		}
@@ -118,7 +125,9 @@ type Parser struct {
 	res Result
 
 	interactive bool
-	noCompLit   bool // to resolve composite literal parsing
+	noCompLit   bool     // to resolve composite literal parsing
+	comments    []string // comments not yet attached to a statement
+	shellFuncs  int      // depth of shell function bodies being parsed
 	s           *Scanner
 }
 
@@ -129,6 +138,12 @@ type Result struct {
 	Stmts []stmt.Stmt
 	Cmds  []*expr.ShellList
 	Errs  []Error
+
+	// Comments maps statements to the comments attached to them.
+	// A statement holds the comments that precede it and any
+	// comments scanned while parsing it that do not belong to
+	// a nested statement, in source order.
+	Comments map[syntax.Node][]string
 }
 
 func (p *Parser) Close() {
@@ -157,10 +172,17 @@ func (p *Parser) work() {
 			if p.s.r == -1 {
 				break // no more work
 			}
-			p.errorf("unknown token: '%s'", p.s.Literal)
+			if p.s.err != nil {
+				p.scanError()
+			} else {
+				p.errorf("unknown token: '%s'", p.s.Literal)
+			}
 			p.s.drain()
 			continue
 		}
+		if p.res.State != StateCmd && p.s.Token == token.Semicolon {
+			continue // empty statement
+		}
 
 		// We parse top-level $$ expression-statements here.
 		//
@@ -174,6 +196,7 @@ func (p *Parser) work() {
 			p.interactive = true
 			cmd := p.parseShellList()
 			p.interactive = false
+			p.comments = nil
 			if cmd != nil {
 				p.res.Cmds = append(p.res.Cmds, cmd)
 			}
@@ -185,8 +208,14 @@ func (p *Parser) work() {
 			}
 		} else {
 			p.res.State = StateStmtPartial
+			nerrs := len(p.res.Errs)
 			p.res.Stmts = append(p.res.Stmts, p.parseStmt())
 			p.res.State = StateStmt
+			if len(p.res.Errs) > nerrs && p.s.Token != token.Semicolon {
+				// The statement ended early on an error,
+				// skip the rest of the line.
+				p.s.drain()
+			}
 		}
 	}
 }
@@ -210,6 +239,7 @@ func ParseStmt(src []byte) (stmt stmt.Stmt, err error) {
 func (p *Parser) next() {
 	p.s.Next()
 	if p.s.Token == token.Comment {
+		p.comments = append(p.comments, p.s.Literal.(string))
 		p.next()
 	}
 }
@@ -264,17 +294,21 @@ func (p *Parser) parseBinaryExpr(minPrec int) expr.Expr {
 func (p *Parser) parseUnaryExpr() expr.Expr {
 	pos := p.pos()
 	switch p.s.Token {
-	case token.Add, token.Sub, token.Not, token.Ref:
+	case token.Add, token.Sub, token.Not, token.Xor, token.Ref:
 		op := p.s.Token
 		p.next()
 		if p.s.err != nil {
 			bad := &expr.Bad{
 				Position: pos,
-				Error:    p.s.err,
+				Error:    p.scanError(),
 			}
 			return bad
 		}
 		x := p.parseUnaryExpr()
+		if lit := foldSign(op, x); lit != nil {
+			lit.Position = pos
+			return lit
+		}
 		// TODO: distinguish expr from types, when we have types
 		unary := &expr.Unary{Position: pos, Op: op, Expr: x}
 		return unary
@@ -309,6 +343,49 @@ func (p *Parser) parseUnaryExpr() expr.Expr {
 	}
 }
 
+// foldSign folds a unary + or - into a numeric literal operand,
+// so -3 is parsed as the literal -3. It returns nil if x is not
+// a numeric literal.
+func foldSign(op token.Token, x expr.Expr) *expr.BasicLiteral {
+	if op != token.Add && op != token.Sub {
+		return nil
+	}
+	lit, ok := x.(*expr.BasicLiteral)
+	if !ok {
+		return nil
+	}
+	neg := op == token.Sub
+	switch v := lit.Value.(type) {
+	case *big.Int:
+		if neg {
+			v = new(big.Int).Neg(v)
+		}
+		return &expr.BasicLiteral{Value: v}
+	case *big.Float:
+		if neg {
+			v = negFloat(v)
+		}
+		return &expr.BasicLiteral{Value: v}
+	case *bigcplx.Complex:
+		if neg {
+			v = &bigcplx.Complex{
+				Real: negFloat(v.Real),
+				Imag: negFloat(v.Imag),
+			}
+		}
+		return &expr.BasicLiteral{Value: v}
+	}
+	return nil
+}
+
+// negFloat returns -f, leaving zero unsigned as Go constants are.
+func negFloat(f *big.Float) *big.Float {
+	if f.Sign() == 0 {
+		return f
+	}
+	return new(big.Float).Neg(f)
+}
+
 func (p *Parser) expectCommaOr(otherwise token.Token, msg string) bool {
 	switch {
 	case p.s.Token == token.Comma:
@@ -417,6 +494,9 @@ func (p *Parser) parsePrimaryExpr() expr.Expr {
 			} else if t := maybePackageType(x); t != nil {
 				t := &expr.Type{Position: pos, Type: t}
 				x = t
+			} else if t := maybeInstanceType(x); t != nil {
+				t := &expr.Type{Position: pos, Type: t}
+				x = t
 			}
 		default:
 			return x
@@ -441,6 +521,43 @@ func maybePackageType(x expr.Expr) *tipe.Unresolved {
 	}
 }
 
+// maybeInstanceType reports whether x, seen before a composite
+// literal, is an instantiated generic type such as List[string].
+func maybeInstanceType(x expr.Expr) *tipe.Unresolved {
+	index, isIndex := x.(*expr.Index)
+	if !isIndex {
+		return nil
+	}
+	var t *tipe.Unresolved
+	switch left := index.Left.(type) {
+	case *expr.Ident:
+		t = &tipe.Unresolved{Name: left.Name}
+	case *expr.Selector:
+		if t = maybePackageType(left); t == nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	for _, ind := range index.Indicies {
+		switch ind := ind.(type) {
+		case *expr.Ident:
+			t.TypeArgs = append(t.TypeArgs, &tipe.Unresolved{Name: ind.Name})
+		case *expr.Type:
+			t.TypeArgs = append(t.TypeArgs, ind.Type)
+		case *expr.Selector:
+			arg := maybePackageType(ind)
+			if arg == nil {
+				return nil
+			}
+			t.TypeArgs = append(t.TypeArgs, arg)
+		default:
+			return nil
+		}
+	}
+	return t
+}
+
 func (p *Parser) parseTypeAssert(lhs expr.Expr) expr.Expr {
 	pos := p.pos()
 	p.expect(token.LeftParen)
@@ -483,66 +600,140 @@ func (p *Parser) parseIndex(lhs expr.Expr) expr.Expr {
 			p.next()
 		}
 
-		if p.s.Token == token.Colon {
-			slice := &expr.Slice{Position: p.pos()}
-			// [:expr]
-			p.next()
-			if p.s.Token == token.RightBracket || p.s.Token == token.Comma {
-				res.Indicies = append(res.Indicies, slice)
-				continue
-			}
-			slice.High = p.parseExpr()
-			res.Indicies = append(res.Indicies, slice)
+		if p.s.Token == token.TwoPeriod || p.s.Token == token.TwoPeriodEq {
+			// [..high]
+			res.Indicies = append(res.Indicies, p.parseDotRange(nil))
 			continue
 		}
 
-		e := p.parseExpr()
-		if p.s.Token == token.RightBracket || p.s.Token == token.Comma {
-			// [expr]
-			res.Indicies = append(res.Indicies, e)
-			continue
+		var low expr.Expr
+		if p.s.Token != token.Colon {
+			e := p.parseExpr()
+			if p.s.Token == token.TwoPeriod || p.s.Token == token.TwoPeriodEq {
+				// [low..high] or [low..=high]
+				res.Indicies = append(res.Indicies, p.parseDotRange(e))
+				continue
+			}
+			if len(res.Indicies) == 0 {
+				// x["C1"|"C2"] or x["C1", rows]
+				_, isPipe := e.(*expr.Binary)
+				if names := tableColNames(e); names != nil && (isPipe || p.s.Token == token.Comma) {
+					return p.parseTableIndex(res, names)
+				}
+			}
+			if p.s.Token == token.RightBracket || p.s.Token == token.Comma {
+				// [expr]
+				res.Indicies = append(res.Indicies, e)
+				continue
+			}
+			low = e
 		}
 		p.expect(token.Colon)
-		colonPos := p.pos()
+		slice := &expr.Slice{Position: p.pos(), Low: low}
+		res.Indicies = append(res.Indicies, slice)
 		p.next()
 		if p.s.Token == token.RightBracket || p.s.Token == token.Comma {
-			// [expr:]
-			res.Indicies = append(res.Indicies, &expr.Slice{Position: colonPos, Low: e})
+			// [low:]
 			continue
 		}
-		high := p.parseExpr()
-		if p.s.Token == token.RightBracket || p.s.Token == token.Comma {
-			// [expr:high]
-			res.Indicies = append(res.Indicies, &expr.Slice{
-				Position: colonPos,
-				Low:      e,
-				High:     high,
-			})
-			continue
+		if p.s.Token != token.Colon {
+			// [low:high]
+			slice.High = p.parseExpr()
+		}
+		if p.s.Token == token.Colon {
+			// [low:high:max]
+			if slice.High == nil {
+				p.error("middle index required in 3-index slice")
+			}
+			p.next()
+			if p.s.Token == token.RightBracket || p.s.Token == token.Comma {
+				p.error("final index required in 3-index slice")
+				continue
+			}
+			slice.Max = p.parseExpr()
 		}
-		p.expect(token.Colon)
-		p.next()
-		max := p.parseExpr()
-		// [expr:high:max]
-		res.Indicies = append(res.Indicies, &expr.Slice{
-			Position: colonPos,
-			Low:      e,
-			High:     high,
-			Max:      max,
-		})
-
 	}
 	p.expect(token.RightBracket)
 	p.next()
 	return res
 }
 
+// parseTableIndex parses the remainder of a table index expression,
+// after the column names. It consumes the closing bracket.
+func (p *Parser) parseTableIndex(index *expr.Index, names []string) *expr.TableIndex {
+	x := &expr.TableIndex{
+		Position: index.Position,
+		Expr:     index.Left,
+		ColNames: names,
+	}
+	if p.s.Token == token.Comma {
+		p.next()
+		x.Rows = p.parseRange()
+	}
+	p.expect(token.RightBracket)
+	p.next()
+	return x
+}
+
+// tableColNames returns the column names of a table index, a list of
+// string literals separated by '|'. It returns nil if e is not a list
+// of column names.
+func tableColNames(e expr.Expr) []string {
+	switch e := e.(type) {
+	case *expr.BasicLiteral:
+		if s, ok := e.Value.(string); ok {
+			return []string{s}
+		}
+	case *expr.Binary:
+		if e.Op != token.Pipe {
+			return nil
+		}
+		left := tableColNames(e.Left)
+		right := tableColNames(e.Right)
+		if left == nil || right == nil {
+			return nil
+		}
+		return append(left, right...)
+	}
+	return nil
+}
+
+// parseDotRange parses the remainder of the range start..end or
+// start..=end. The end may be omitted from an exclusive range.
+func (p *Parser) parseDotRange(start expr.Expr) *expr.Range {
+	r := &expr.Range{
+		Position:  p.pos(),
+		Start:     start,
+		Inclusive: p.s.Token == token.TwoPeriodEq,
+	}
+	if start != nil {
+		r.Position = start.Pos()
+	}
+	p.next()
+	if p.s.Token == token.Comma || p.s.Token == token.RightBracket {
+		if r.Inclusive {
+			p.error("inclusive range requires an end")
+		}
+		return r
+	}
+	r.End = p.parseExpr()
+	return r
+}
+
 func (p *Parser) parseRange() (r expr.Range) {
 	var x expr.Expr
+	if p.s.Token == token.TwoPeriod || p.s.Token == token.TwoPeriodEq {
+		// case ..1 or ..=1
+		return *p.parseDotRange(nil)
+	}
 	if p.s.Token != token.Colon {
 		// case 0, 0: or 0:1
 		x = p.parseExpr()
 	}
+	if p.s.Token == token.TwoPeriod || p.s.Token == token.TwoPeriodEq {
+		// case 0..1 or 0..=1
+		return *p.parseDotRange(x)
+	}
 	if p.s.Token == token.Comma || p.s.Token == token.RightBracket {
 		// case 0
 		r.Exact = x
@@ -636,6 +827,12 @@ func (p *Parser) parseParamTuple() (names []string, params *tipe.Tuple) {
 			}
 		}
 	}
+	for i, t := range params.Elems {
+		if _, isEllipsis := t.(*tipe.Ellipsis); isEllipsis && i != len(params.Elems)-1 {
+			p.error("can only use ... with final parameter in list")
+			return nil, &tipe.Tuple{}
+		}
+	}
 	return names, params
 }
 
@@ -674,6 +871,16 @@ func (p *Parser) parseMethodik(name string) *stmt.MethodikDecl {
 }
 
 func (p *Parser) parseType() tipe.Type {
+	if p.s.Token == token.LeftParen {
+		// Parenthesized type, e.g. chan (<-chan int).
+		// Not handled by maybeParseType, where a '('
+		// may begin an expression.
+		p.next()
+		t := p.parseType()
+		p.expect(token.RightParen)
+		p.next()
+		return t
+	}
 	t := p.maybeParseType()
 	if t == nil {
 		p.errorf("expected type , got %s", p.s.Token)
@@ -701,34 +908,7 @@ func (p *Parser) maybeParseType() tipe.Type {
 		return &tipe.Unresolved{Name: ident.Name}
 	case token.LeftBracket:
 		p.next()
-		table := false
-		if p.s.Token == token.Pipe {
-			table = true
-			p.next()
-		}
-		switch p.s.Token {
-		case token.RightBracket:
-			p.next()
-			if table {
-				return &tipe.Table{Type: p.parseType()}
-			} else {
-				return &tipe.Slice{Elem: p.parseType()}
-			}
-		case token.Int:
-			sz := p.s.Literal.(*big.Int).Int64()
-			p.next()
-			p.expect(token.RightBracket)
-			p.next()
-			return &tipe.Array{Len: sz, Elem: p.parseType()}
-		case token.Ellipsis:
-			p.next()
-			p.expect(token.RightBracket)
-			p.next()
-			return &tipe.Array{Elem: p.parseType(), Ellipsis: true}
-		default:
-			p.errorf("invalid token=%v in type declaration", p.s.Token)
-			return nil
-		}
+		return p.parseBracketType()
 	case token.Mul:
 		p.next()
 		return &tipe.Pointer{Elem: p.parseType()}
@@ -748,15 +928,30 @@ func (p *Parser) maybeParseType() tipe.Type {
 				t = p.parseType()
 			default:
 				n = p.parseIdent().Name
+				if p.s.Token == token.Period {
+					// embedded qualified type, pkg.Name
+					p.next()
+					t = &tipe.Unresolved{Package: n, Name: p.parseIdent().Name}
+					n = ""
+				}
 			}
-			if p.s.Token != token.Comma {
+			if p.s.Token != token.Comma || t != nil {
 				switch p.s.Token {
-				case token.RightBrace, token.Semicolon:
+				case token.RightBrace, token.Semicolon, token.String:
 					// embedded type field
 					if n != "" {
 						t = &tipe.Unresolved{Name: n}
 					} else {
-						n = t.(*tipe.Pointer).Elem.(*tipe.Unresolved).Name
+						et := t
+						if ptr, ok := et.(*tipe.Pointer); ok {
+							et = ptr.Elem
+						}
+						u, ok := et.(*tipe.Unresolved)
+						if !ok {
+							p.errorf("invalid embedded field type %s", format.Type(t))
+							return s
+						}
+						n = u.Name
 					}
 					embed = true
 				default:
@@ -795,11 +990,13 @@ func (p *Parser) maybeParseType() tipe.Type {
 		// a nil field type is one from e.g.:
 		//  type T struct { x, y int }
 		// x has no type (but we want it to have 'int'.)
+		// It shares the tag of y, too.
 		for i := len(s.Fields) - 1; i > 0; i-- {
 			sf := &s.Fields[i]
 			sfn := &s.Fields[i-1]
 			if sfn.Type == nil {
 				sfn.Type = sf.Type
+				sfn.Tag = sf.Tag
 			}
 		}
 		return s
@@ -809,13 +1006,47 @@ func (p *Parser) maybeParseType() tipe.Type {
 		p.next()
 		iface := &tipe.Interface{Methods: make(map[string]*tipe.Func)}
 		for p.s.Token > 0 && p.s.Token != token.RightBrace {
-			f := p.parseFuncType(false)
-			// TODO: we are throwing away a lot of information
-			// not technically part of the type but that we want
-			// in the AST for pretty printing. Recover it.
-			iface.Methods[f.Name] = f.Type
+			if p.s.Token != token.Ident {
+				// type-constraint union, ~int | string
+				iface.Embeds = append(iface.Embeds, p.parseUnion(nil))
+				if p.s.Token == token.Semicolon {
+					p.next()
+				} else if p.s.Token != token.RightBrace {
+					p.expect(token.Semicolon) // produce error
+					break
+				}
+				continue
+			}
+			name := p.parseIdent().Name
+			switch p.s.Token {
+			case token.LeftParen:
+				f := p.parseFuncType(false)
+				// TODO: we are throwing away a lot of information
+				// not technically part of the type but that we want
+				// in the AST for pretty printing. Recover it.
+				if iface.Methods[name] != nil {
+					p.errorf("duplicate method %s", name)
+				}
+				iface.Methods[name] = f.Type
+			case token.Period:
+				// embedded qualified interface, pkg.Name
+				p.next()
+				sel := p.parseIdent()
+				iface.Embeds = append(iface.Embeds, &tipe.Unresolved{Package: name, Name: sel.Name})
+			default:
+				iface.Embeds = append(iface.Embeds, &tipe.Unresolved{Name: name})
+			}
+			if p.s.Token == token.Pipe {
+				// the embed is the first term of a union
+				last := len(iface.Embeds) - 1
+				first := &tipe.Term{Type: iface.Embeds[last]}
+				iface.Embeds[last] = p.parseUnion(first)
+			}
 			if p.s.Token == token.Semicolon {
 				p.next()
+			} else if p.s.Token != token.RightBrace {
+				p.expect(token.Semicolon) // produce error
+				break
 			}
 		}
 		p.expect(token.RightBrace)
@@ -866,6 +1097,89 @@ func (p *Parser) maybeParseType() tipe.Type {
 	return nil
 }
 
+// parseBracketType parses a slice, table or array type
+// after its opening '['.
+func (p *Parser) parseBracketType() tipe.Type {
+	table := false
+	if p.s.Token == token.Pipe {
+		table = true
+		p.next()
+	}
+	switch p.s.Token {
+	case token.RightBracket:
+		p.next()
+		if table {
+			return &tipe.Table{Type: p.parseType()}
+		} else {
+			return &tipe.Slice{Elem: p.parseType()}
+		}
+	case token.Ellipsis:
+		p.next()
+		p.expect(token.RightBracket)
+		p.next()
+		return &tipe.Array{Elem: p.parseType(), Ellipsis: true}
+	default:
+		return p.parseArrayType(p.parseExpr())
+	}
+}
+
+// parseArrayType parses the rest of an array type [x]T,
+// after its length x.
+func (p *Parser) parseArrayType(x expr.Expr) tipe.Type {
+	// The length is any constant expression. Integer
+	// literals are the common case, everything else is
+	// evaluated by the type checker.
+	t := &tipe.Array{}
+	if lit, ok := x.(*expr.BasicLiteral); ok {
+		sz, ok := lit.Value.(*big.Int)
+		if !ok || sz.Sign() < 0 {
+			p.errorf("array length must be a non-negative integer constant")
+			return nil
+		}
+		t.Len = sz.Int64()
+	} else {
+		t.LenExpr = x
+	}
+	p.expect(token.RightBracket)
+	p.next()
+	t.Elem = p.parseType()
+	return t
+}
+
+// parseTypeParams parses a type parameter list, [K, V any],
+// after its opening '[' and first parameter name.
+func (p *Parser) parseTypeParams(first string) (params []*tipe.TypeParam) {
+	names := []string{first}
+	for p.s.Token > 0 && p.s.Token != token.RightBracket {
+		if p.s.Token == token.Comma {
+			p.next()
+			names = append(names, p.parseIdent().Name)
+			continue
+		}
+		var constraint tipe.Type
+		if u := p.parseUnion(nil); len(u.Terms) == 1 && !u.Terms[0].Tilde {
+			constraint = u.Terms[0].Type
+		} else {
+			constraint = u
+		}
+		for _, name := range names {
+			params = append(params, &tipe.TypeParam{Name: name, Constraint: constraint})
+		}
+		names = nil
+		if p.s.Token != token.Comma {
+			break
+		}
+		p.next()
+		names = append(names, p.parseIdent().Name)
+	}
+	if len(names) > 0 {
+		p.errorf("missing type constraint for %s", names[len(names)-1])
+	}
+	p.expect(token.RightBracket)
+	p.next()
+	return params
+}
+
 func (p *Parser) parseExprs() []expr.Expr {
 	exprs := []expr.Expr{p.parseExpr()}
 	for p.s.Token == token.Comma {
@@ -887,8 +1201,18 @@ func arithAssignOp(t token.Token) token.Token {
 		return token.Div
 	case token.RemAssign:
 		return token.Rem
-	case token.PowAssign:
-		return token.Pow
+	case token.XorAssign:
+		return token.Xor
+	case token.ShiftLeftAssign:
+		return token.TwoLess
+	case token.ShiftRightAssign:
+		return token.TwoGreater
+	case token.AndAssign:
+		return token.Ref
+	case token.OrAssign:
+		return token.Pipe
+	case token.AndNotAssign:
+		return token.RefPow
 	default:
 		return token.Unknown
 	}
@@ -899,7 +1223,9 @@ func (p *Parser) parseSimpleStmt() stmt.Stmt {
 
 	switch p.s.Token {
 	case token.Define, token.Assign, token.AddAssign, token.SubAssign,
-		token.MulAssign, token.DivAssign, token.RemAssign, token.PowAssign:
+		token.MulAssign, token.DivAssign, token.RemAssign, token.XorAssign,
+		token.ShiftLeftAssign, token.ShiftRightAssign,
+		token.AndAssign, token.OrAssign, token.AndNotAssign:
 		tok := p.s.Token
 		tokPos := p.pos()
 
@@ -1000,12 +1326,20 @@ func (p *Parser) parseSimpleStmt() stmt.Stmt {
 			return &stmt.Simple{Expr: e}
 		}
 		p.next()
-		// TODO: we can be stricter here, sometimes it is invalid to declare a label.
 		if lhs, isIdent := exprs[0].(*expr.Ident); isIdent {
+			s := p.parseStmt()
+			switch s.(type) {
+			case *stmt.Import, *stmt.ImportSet, *stmt.TypeDecl, *stmt.TypeDeclSet,
+				*stmt.MethodikDecl, *stmt.Const, *stmt.ConstSet, *stmt.Var, *stmt.VarSet:
+				return &stmt.Bad{
+					Position: exprs[0].Pos(),
+					Error:    p.errorf("cannot label declaration with %s", lhs.Name),
+				}
+			}
 			return &stmt.Labeled{
 				Position: exprs[0].Pos(),
 				Label:    lhs.Name,
-				Stmt:     p.parseStmt(),
+				Stmt:     s,
 			}
 		}
 		return &stmt.Bad{
@@ -1051,7 +1385,21 @@ func extractRange(s stmt.Stmt) (res *stmt.Range) {
 	return &stmt.Range{Position: r.Pos(), Decl: a.Decl, Key: key, Val: val, Expr: r.Expr}
 }
 
-func (p *Parser) parseStmt() stmt.Stmt {
+func (p *Parser) parseStmt() (res stmt.Stmt) {
+	leading := p.comments
+	p.comments = nil
+	defer func() {
+		comments := append(leading, p.comments...)
+		p.comments = nil
+		if res == nil || len(comments) == 0 {
+			return
+		}
+		if p.res.Comments == nil {
+			p.res.Comments = make(map[syntax.Node][]string)
+		}
+		p.res.Comments[res] = comments
+	}()
+
 	switch p.s.Token {
 	// TODO: many many kinds of statements
 	case token.If:
@@ -1083,7 +1431,7 @@ func (p *Parser) parseStmt() stmt.Stmt {
 		}
 		return s
 	case token.Ident, token.Int, token.Float,
-		token.Add, token.Sub, token.Mul, token.ChanOp, token.Not, token.Map,
+		token.Add, token.Sub, token.Mul, token.ChanOp, token.Not, token.Xor, token.Map,
 		token.Func, token.LeftBracket, token.LeftParen, token.String, token.Rune, token.Shell:
 		// A "simple" statement, no control flow.
 		s := p.parseSimpleStmt()
@@ -1133,7 +1481,18 @@ func (p *Parser) parseStmt() stmt.Stmt {
 			p.next()
 			s := &stmt.ConstSet{Position: pos}
 			for p.s.Token > 0 && p.s.Token != token.RightParen {
-				s.Consts = append(s.Consts, p.parseConst())
+				c := p.parseConst()
+				if n := len(s.Consts); n > 0 && len(c.Values) == 0 && c.Type == nil {
+					// An omitted expression list repeats the
+					// previous one, evaluated with the next iota.
+					prev := s.Consts[n-1]
+					c.Type = prev.Type
+					c.Values = prev.Values
+					if len(c.NameList) != len(c.Values) {
+						p.errorf("wrong number of names in const declaration, want %d", len(c.Values))
+					}
+				}
+				s.Consts = append(s.Consts, c)
 				if p.s.Token == token.Semicolon {
 					p.next()
 				}
@@ -1262,6 +1621,9 @@ func (p *Parser) parseBranch() *stmt.Branch {
 		s.Label = p.s.Literal.(string)
 		p.next()
 	}
+	if s.Type == token.Goto && s.Label == "" {
+		p.error("missing label in goto")
+	}
 	return s
 }
 
@@ -1337,11 +1699,14 @@ func (p *Parser) parseVar() *stmt.Var {
 	}
 items:
 	for {
-		p.expect(token.Ident)
+		if !p.expect(token.Ident) {
+			break items
+		}
 		s.NameList = append(s.NameList, p.s.Literal.(string))
 		p.next()
 		switch p.s.Token {
-		case token.Chan, token.Ident, token.LeftBracket, token.Map, token.Struct:
+		case token.Chan, token.ChanOp, token.Ident, token.LeftBracket, token.Map, token.Struct,
+			token.Mul, token.Func, token.Interface, token.LeftParen:
 			s.Type = p.parseType()
 			if p.s.Token == token.Assign {
 				p.next()
@@ -1368,17 +1733,58 @@ items:
 	return s
 }
 
+// parseUnion parses a type-constraint union, ~T1 | T2 | ...
+// If first is non-nil, it is the already parsed first term.
+func (p *Parser) parseUnion(first *tipe.Term) *tipe.Union {
+	if first == nil {
+		first = p.parseTerm()
+	}
+	u := &tipe.Union{Terms: []*tipe.Term{first}}
+	for p.s.Token == token.Pipe {
+		p.next()
+		u.Terms = append(u.Terms, p.parseTerm())
+	}
+	return u
+}
+
+func (p *Parser) parseTerm() *tipe.Term {
+	t := &tipe.Term{}
+	if p.s.Token == token.Tilde {
+		t.Tilde = true
+		p.next()
+	}
+	t.Type = p.parseType()
+	return t
+}
+
 func (p *Parser) parseTypeDecl() *stmt.TypeDecl {
 	pos := p.pos()
-	t := &tipe.Named{
-		Name: p.parseIdent().Name,
-		Type: p.parseType(),
-	}
+	t := &tipe.Named{Name: p.parseIdent().Name}
 	s := &stmt.TypeDecl{
 		Position: pos,
 		Name:     t.Name,
 		Type:     t,
 	}
+	if p.s.Token == token.Assign {
+		p.next()
+		s.Alias = true
+	} else if p.s.Token == token.LeftBracket {
+		// Either type parameters, type List[T any] ...,
+		// or an array or slice type, type A [N]int.
+		p.next()
+		if p.s.Token != token.Ident {
+			t.Type = p.parseBracketType()
+			return s
+		}
+		x := p.parseExpr()
+		ident, ok := x.(*expr.Ident)
+		if !ok || p.s.Token == token.RightBracket {
+			t.Type = p.parseArrayType(x)
+			return s
+		}
+		t.TypeParams = p.parseTypeParams(ident.Name)
+	}
+	t.Type = p.parseType()
 	return s
 }
 
@@ -1689,6 +2095,10 @@ func (p *Parser) parseStmts() (stmts []stmt.Stmt) {
 	// TODO there are other kinds of blocks to exit from
 	for p.s.Token > 0 && p.s.Token != token.RightBrace &&
 		p.s.Token != token.Case && p.s.Token != token.Default {
+		if p.s.Token == token.Semicolon {
+			p.next() // empty statement
+			continue
+		}
 		stmts = append(stmts, p.parseStmt())
 		if p.s.Token == token.Semicolon {
 			p.next()
@@ -1719,6 +2129,10 @@ func (p *Parser) parseFuncType(method bool) *expr.FuncLiteral {
 
 	if p.s.Token == token.Ident {
 		f.Name = p.parseIdent().Name
+		if p.s.Token == token.LeftBracket {
+			p.next()
+			f.Type.TypeParams = p.parseTypeParams(p.parseIdent().Name)
+		}
 	} else if method {
 		p.errorf("class method missing name")
 	}
@@ -1808,12 +2222,14 @@ func (p *Parser) parseOperand() expr.Expr {
 		p.expect(token.RightParen)
 		p.next()
 		p.noCompLit = origNoCompLit
-		return &expr.Unary{
+		return &expr.Paren{
 			Position: pos,
-			Op:       token.LeftParen, Expr: ex,
+			Expr:     ex,
 		}
 	case token.Func:
 		return p.parseFunc(false)
+	case token.If:
+		return p.parseIfExpr()
 	case token.Shell:
 		x := &expr.Shell{
 			Position: p.pos(),
@@ -1833,6 +2249,15 @@ func (p *Parser) parseOperand() expr.Expr {
 	}
 
 	pos := p.pos()
+	if p.s.Token == token.Unknown && p.s.err != nil {
+		// Bad literal, for example: 0b12
+		res := &expr.Bad{
+			Position: pos,
+			Error:    p.scanError(),
+		}
+		p.next()
+		return res
+	}
 	if t := p.maybeParseType(); t != nil {
 		return &expr.Type{
 			Position: pos,
@@ -1848,15 +2273,63 @@ func (p *Parser) parseOperand() expr.Expr {
 	return res
 }
 
+// parseIfExpr parses an if expression, which unlike an if
+// statement must have an else, and has a single expression
+// in each of its branches.
+func (p *Parser) parseIfExpr() *expr.IfExpr {
+	x := &expr.IfExpr{Position: p.pos()}
+	p.next()
+	origNoCompLit := p.noCompLit
+	p.noCompLit = true
+	x.Cond = p.parseExpr()
+	p.noCompLit = false
+	x.Then = p.parseIfExprBranch()
+	if p.expect(token.Else) {
+		p.next()
+		if p.s.Token == token.If {
+			x.Else = p.parseIfExpr()
+		} else {
+			x.Else = p.parseIfExprBranch()
+		}
+	}
+	p.noCompLit = origNoCompLit
+	return x
+}
+
+func (p *Parser) parseIfExprBranch() expr.Expr {
+	p.expect(token.LeftBrace)
+	p.next()
+	e := p.parseExpr()
+	if p.s.Token == token.Semicolon {
+		p.next()
+	}
+	p.expect(token.RightBrace)
+	p.next()
+	return e
+}
+
 func (p *Parser) parseArrayLiteral(t tipe.Type) *expr.ArrayLiteral {
 	x := &expr.ArrayLiteral{Position: p.pos(), Type: t.(*tipe.Array)}
 	x.Keys, x.Values = p.parseKeyedLiteral()
+	if x.Type.Ellipsis && len(x.Values) == 0 {
+		p.errorf("array literal with [...] length must have at least one element")
+		return x
+	}
 	if x.Type.Ellipsis || len(x.Keys) > 0 {
 		n := int64(len(x.Values))
 		if len(x.Keys) > 0 {
 			for _, k := range x.Keys {
-				i := k.(*expr.BasicLiteral).Value.(*big.Int).Int64()
-				if i+1 > n {
+				lit, ok := k.(*expr.BasicLiteral)
+				if !ok {
+					p.errorf("array index %s must be an integer constant", format.Expr(k))
+					return x
+				}
+				v, ok := lit.Value.(*big.Int)
+				if !ok {
+					p.errorf("array index %s must be an integer constant", format.Expr(k))
+					return x
+				}
+				if i := v.Int64(); i+1 > n {
 					n = i + 1
 				}
 			}
@@ -1883,7 +2356,7 @@ func (p *Parser) parseTableLiteral(t tipe.Type) *expr.TableLiteral {
 			if len(x.ColNames) != 0 || len(x.Rows) != 0 {
 				p.errorf("column names can only appear at beginning of table literal")
 			}
-			for p.s.Token > 0 && p.s.Token != token.Pipe {
+			for p.s.Token > 0 && p.s.Token != token.RightBraceTable {
 				x.ColNames = append(x.ColNames, p.parseExpr())
 				if p.s.Token != token.Comma {
 					break
@@ -1974,9 +2447,14 @@ type Error struct {
 	Pos    src.Pos
 	Offset int
 	Msg    string
+
+	scanner bool // reported by the scanner
 }
 
 func (e Error) Error() string {
+	if e.scanner {
+		return fmt.Sprintf("neugram: scanner: %s (line %d, col %d)", e.Msg, e.Pos.Line, e.Pos.Column)
+	}
 	return fmt.Sprintf("neugram: parser: %s (off %d)", e.Msg, e.Offset)
 }
 
@@ -1994,6 +2472,15 @@ func (p *Parser) error(msg string) error {
 	return err
 }
 
+// scanError records and clears the pending scanner error.
+func (p *Parser) scanError() error {
+	err := p.s.err.(Error)
+	err.Pos.Filename = p.filename
+	p.s.err = nil
+	p.res.Errs = append(p.res.Errs, err)
+	return err
+}
+
 func (p *Parser) expect(t token.Token) bool {
 	met := t == p.s.Token
 	if !met {
@@ -34,13 +34,19 @@ var parserTests = []parserTest{
 			Right: &expr.BasicLiteral{Value: big.NewInt(9)},
 		},
 	},
+	{"(x)", &expr.Paren{Expr: &expr.Ident{Name: "x"}}},
+	{".5", basic(0.5)},
+	{"x + .5", &expr.Binary{
+		Op:    token.Add,
+		Left:  &expr.Ident{Name: "x"},
+		Right: basic(0.5),
+	}},
 	{
 		"x + (y + 7)",
 		&expr.Binary{
 			Op:   token.Add,
 			Left: &expr.Ident{Name: "x"},
-			Right: &expr.Unary{
-				Op: token.LeftParen,
+			Right: &expr.Paren{
 				Expr: &expr.Binary{
 					Op:    token.Add,
 					Left:  &expr.Ident{Name: "y"},
@@ -68,11 +74,24 @@ var parserTests = []parserTest{
 	{
 		"x ^ y",
 		&expr.Binary{
-			Op:    token.Pow,
+			Op:    token.Xor,
 			Left:  &expr.Ident{Name: "x"},
 			Right: &expr.Ident{Name: "y"},
 		},
 	},
+	{"^x", &expr.Unary{Op: token.Xor, Expr: &expr.Ident{Name: "x"}}},
+	{"^0", &expr.Unary{Op: token.Xor, Expr: basic(0)}},
+	{"x ^ ^y", &expr.Binary{
+		Op:    token.Xor,
+		Left:  &expr.Ident{Name: "x"},
+		Right: &expr.Unary{Op: token.Xor, Expr: &expr.Ident{Name: "y"}},
+	}},
+	{"-3", basic(-3)},
+	{"+3", basic(3)},
+	{"- -3", basic(3)},
+	{"-x", &expr.Unary{Op: token.Sub, Expr: &expr.Ident{Name: "x"}}},
+	{"-(3)", &expr.Unary{Op: token.Sub, Expr: &expr.Paren{Expr: basic(3)}}},
+	{"2 * -1.5", &expr.Binary{Op: token.Mul, Left: basic(2), Right: basic(-1.5)}},
 	{
 		"x & y",
 		&expr.Binary{
@@ -169,6 +188,128 @@ var parserTests = []parserTest{
 			}},
 		},
 	},
+	{
+		"func(a, b int) int { return a }",
+		&expr.FuncLiteral{
+			Type: &tipe.Func{
+				Params: &tipe.Tuple{Elems: []tipe.Type{
+					&tipe.Unresolved{Name: "int"},
+					&tipe.Unresolved{Name: "int"},
+				}},
+				Results: &tipe.Tuple{Elems: []tipe.Type{
+					&tipe.Unresolved{Name: "int"},
+				}},
+			},
+			ParamNames:  []string{"a", "b"},
+			ResultNames: []string{""},
+			Body: &stmt.Block{Stmts: []stmt.Stmt{
+				&stmt.Return{Exprs: []expr.Expr{&expr.Ident{Name: "a"}}},
+			}},
+		},
+	},
+	{
+		"func F[T any](x T) T { return x }",
+		&expr.FuncLiteral{
+			Name: "F",
+			Type: &tipe.Func{
+				TypeParams: []*tipe.TypeParam{
+					{Name: "T", Constraint: &tipe.Unresolved{Name: "any"}},
+				},
+				Params:  &tipe.Tuple{Elems: []tipe.Type{&tipe.Unresolved{Name: "T"}}},
+				Results: &tipe.Tuple{Elems: []tipe.Type{&tipe.Unresolved{Name: "T"}}},
+			},
+			ParamNames:  []string{"x"},
+			ResultNames: []string{""},
+			Body: &stmt.Block{Stmts: []stmt.Stmt{
+				&stmt.Return{Exprs: []expr.Expr{&expr.Ident{Name: "x"}}},
+			}},
+		},
+	},
+	{
+		"func Keys[K, V any, N ~int | string](m map[K]V, n N) {}",
+		&expr.FuncLiteral{
+			Name: "Keys",
+			Type: &tipe.Func{
+				TypeParams: []*tipe.TypeParam{
+					{Name: "K", Constraint: &tipe.Unresolved{Name: "any"}},
+					{Name: "V", Constraint: &tipe.Unresolved{Name: "any"}},
+					{Name: "N", Constraint: &tipe.Union{Terms: []*tipe.Term{
+						{Tilde: true, Type: &tipe.Unresolved{Name: "int"}},
+						{Type: &tipe.Unresolved{Name: "string"}},
+					}}},
+				},
+				Params: &tipe.Tuple{Elems: []tipe.Type{
+					&tipe.Map{Key: &tipe.Unresolved{Name: "K"}, Value: &tipe.Unresolved{Name: "V"}},
+					&tipe.Unresolved{Name: "N"},
+				}},
+			},
+			ParamNames: []string{"m", "n"},
+			Body:       &stmt.Block{},
+		},
+	},
+	{
+		"func(a, b int, s string) (x, y int, err error) {}",
+		&expr.FuncLiteral{
+			Type: &tipe.Func{
+				Params: &tipe.Tuple{Elems: []tipe.Type{
+					&tipe.Unresolved{Name: "int"},
+					&tipe.Unresolved{Name: "int"},
+					&tipe.Unresolved{Name: "string"},
+				}},
+				Results: &tipe.Tuple{Elems: []tipe.Type{
+					&tipe.Unresolved{Name: "int"},
+					&tipe.Unresolved{Name: "int"},
+					&tipe.Unresolved{Name: "error"},
+				}},
+			},
+			ParamNames:  []string{"a", "b", "s"},
+			ResultNames: []string{"x", "y", "err"},
+			Body:        &stmt.Block{},
+		},
+	},
+	{
+		"func(ch <-chan int, out chan<- int, done chan int) {}",
+		&expr.FuncLiteral{
+			Type: &tipe.Func{
+				Params: &tipe.Tuple{Elems: []tipe.Type{
+					&tipe.Chan{Direction: tipe.ChanRecv, Elem: &tipe.Unresolved{Name: "int"}},
+					&tipe.Chan{Direction: tipe.ChanSend, Elem: &tipe.Unresolved{Name: "int"}},
+					&tipe.Chan{Direction: tipe.ChanBoth, Elem: &tipe.Unresolved{Name: "int"}},
+				}},
+			},
+			ParamNames: []string{"ch", "out", "done"},
+			Body:       &stmt.Block{},
+		},
+	},
+	{
+		"func() <-chan int { return nil }",
+		&expr.FuncLiteral{
+			Type: &tipe.Func{
+				Params: &tipe.Tuple{},
+				Results: &tipe.Tuple{Elems: []tipe.Type{
+					&tipe.Chan{Direction: tipe.ChanRecv, Elem: &tipe.Unresolved{Name: "int"}},
+				}},
+			},
+			ResultNames: []string{""},
+			Body: &stmt.Block{Stmts: []stmt.Stmt{
+				&stmt.Return{Exprs: []expr.Expr{&expr.Ident{Name: "nil"}}},
+			}},
+		},
+	},
+	{
+		"func(fmt string, args ...interface{}) {}",
+		&expr.FuncLiteral{
+			Type: &tipe.Func{
+				Params: &tipe.Tuple{Elems: []tipe.Type{
+					&tipe.Unresolved{Name: "string"},
+					&tipe.Ellipsis{Elem: &tipe.Interface{}},
+				}},
+				Variadic: true,
+			},
+			ParamNames: []string{"fmt", "args"},
+			Body:       &stmt.Block{},
+		},
+	},
 	{
 		`func() int64 {
 			x := 7
@@ -270,7 +411,30 @@ var parserTests = []parserTest{
 	{"y * z//comment", &expr.Binary{Op: token.Mul, Left: &expr.Ident{Name: "y"}, Right: &expr.Ident{Name: "z"}}},
 	{`"hello"`, &expr.BasicLiteral{Value: "hello"}},
 	{`"hello \"neugram\""`, &expr.BasicLiteral{Value: `hello "neugram"`}},
-	//TODO{`"\""`, &expr.BasicLiteral{Value:`"\""`}}
+	{`"\""`, &expr.BasicLiteral{Value: `"`}},
+	{`"\\"`, &expr.BasicLiteral{Value: `\`}},
+	{`"a\"b"`, &expr.BasicLiteral{Value: `a"b`}},
+	{"f(if x { 1 } else { 2 })", &expr.Call{
+		Func: &expr.Ident{Name: "f"},
+		Args: []expr.Expr{&expr.IfExpr{
+			Cond: &expr.Ident{Name: "x"},
+			Then: basic(1),
+			Else: basic(2),
+		}},
+	}},
+	{"1 + if x {\n\ty\n} else if z { T{} } else { 3 }", &expr.Binary{
+		Op:   token.Add,
+		Left: basic(1),
+		Right: &expr.IfExpr{
+			Cond: &expr.Ident{Name: "x"},
+			Then: &expr.Ident{Name: "y"},
+			Else: &expr.IfExpr{
+				Cond: &expr.Ident{Name: "z"},
+				Then: &expr.CompLiteral{Type: &tipe.Unresolved{Name: "T"}},
+				Else: basic(3),
+			},
+		},
+	}},
 	{"x[4]", &expr.Index{Left: &expr.Ident{Name: "x"}, Indicies: []expr.Expr{basic(4)}}},
 	{"x[1+2]", &expr.Index{
 		Left: &expr.Ident{Name: "x"},
@@ -289,26 +453,66 @@ var parserTests = []parserTest{
 	{"x[:,:]", &expr.Index{Left: &expr.Ident{Name: "x"}, Indicies: []expr.Expr{&expr.Slice{}, &expr.Slice{}}}},
 	{"x[1:,:3]", &expr.Index{Left: &expr.Ident{Name: "x"}, Indicies: []expr.Expr{&expr.Slice{Low: basic(1)}, &expr.Slice{High: basic(3)}}}},
 	{"x[1:3,5:7]", &expr.Index{Left: &expr.Ident{Name: "x"}, Indicies: []expr.Expr{&expr.Slice{Low: basic(1), High: basic(3)}, &expr.Slice{Low: basic(5), High: basic(7)}}}},
-	/* TODO
+	{"x[1:3:5]", &expr.Index{Left: &expr.Ident{Name: "x"}, Indicies: []expr.Expr{&expr.Slice{Low: basic(1), High: basic(3), Max: basic(5)}}}},
+	{"x[:3:5]", &expr.Index{Left: &expr.Ident{Name: "x"}, Indicies: []expr.Expr{&expr.Slice{High: basic(3), Max: basic(5)}}}},
 	{`x["C1"|"C2"]`, &expr.TableIndex{Expr: &expr.Ident{Name: "x"}, ColNames: []string{"C1", "C2"}}},
 	{`x["C1",1:]`, &expr.TableIndex{
 		Expr:     &expr.Ident{Name: "x"},
 		ColNames: []string{"C1"},
-		Rows:     expr.Range{Start: &expr.BasicLiteral{Value:big.NewInt(1)}},
+		Rows:     expr.Range{Start: basic(1)},
+	}},
+	{`x["C1"|"C2"|"C3", :2]`, &expr.TableIndex{
+		Expr:     &expr.Ident{Name: "x"},
+		ColNames: []string{"C1", "C2", "C3"},
+		Rows:     expr.Range{End: basic(2)},
+	}},
+	{`x["C2", 3]`, &expr.TableIndex{
+		Expr:     &expr.Ident{Name: "x"},
+		ColNames: []string{"C2"},
+		Rows:     expr.Range{Exact: basic(3)},
+	}},
+	{"x[1..3]", &expr.Index{
+		Left:     &expr.Ident{Name: "x"},
+		Indicies: []expr.Expr{&expr.Range{Start: basic(1), End: basic(3)}},
+	}},
+	{"x[1..=3]", &expr.Index{
+		Left:     &expr.Ident{Name: "x"},
+		Indicies: []expr.Expr{&expr.Range{Start: basic(1), End: basic(3), Inclusive: true}},
+	}},
+	{"x[i..]", &expr.Index{
+		Left:     &expr.Ident{Name: "x"},
+		Indicies: []expr.Expr{&expr.Range{Start: &expr.Ident{Name: "i"}}},
+	}},
+	{"x[..=n-1]", &expr.Index{
+		Left: &expr.Ident{Name: "x"},
+		Indicies: []expr.Expr{&expr.Range{
+			End:       &expr.Binary{Op: token.Sub, Left: &expr.Ident{Name: "n"}, Right: basic(1)},
+			Inclusive: true,
+		}},
+	}},
+	{"x[1.5]", &expr.Index{Left: &expr.Ident{Name: "x"}, Indicies: []expr.Expr{basic(1.5)}}},
+	{`x["C1", 1..=2]`, &expr.TableIndex{
+		Expr:     &expr.Ident{Name: "x"},
+		ColNames: []string{"C1"},
+		Rows:     expr.Range{Start: basic(1), End: basic(2), Inclusive: true},
 	}},
-	/*{"[|]num{}", &expr.TableLiteral{Type: &tipe.Table{tipe.Num}}},
+	{`x["key"]`, &expr.Index{Left: &expr.Ident{Name: "x"}, Indicies: []expr.Expr{basic("key")}}},
+	{"[|]num{}", &expr.TableLiteral{Type: &tipe.Table{Type: tipe.Num}}},
 	{"[|]num{{0, 1, 2}}", &expr.TableLiteral{
-		Type: &tipe.Table{tipe.Num},
+		Type: &tipe.Table{Type: tipe.Num},
 		Rows: [][]expr.Expr{{basic(0), basic(1), basic(2)}},
 	}},
 	{`[|]num{{|"Col1"|}, {1}, {2}}`, &expr.TableLiteral{
-		Type:     &tipe.Table{tipe.Num},
+		Type:     &tipe.Table{Type: tipe.Num},
 		ColNames: []expr.Expr{basic("Col1")},
 		Rows:     [][]expr.Expr{{basic(1)}, {basic(2)}},
 	}},
-	*/
-	{"($$ls$$)", &expr.Unary{ // for Issue #50
-		Op: token.LeftParen,
+	{`[|]int64{{|"x", "y"|}, {1, 2}, {3, 4}}`, &expr.TableLiteral{
+		Type:     &tipe.Table{Type: tint64},
+		ColNames: []expr.Expr{basic("x"), basic("y")},
+		Rows:     [][]expr.Expr{{basic(1), basic(2)}, {basic(3), basic(4)}},
+	}},
+	{"($$ls$$)", &expr.Paren{ // for Issue #50
 		Expr: &expr.Shell{
 			Cmds: []*expr.ShellList{{AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
 				Cmd: []*expr.ShellCmd{{
@@ -357,7 +561,42 @@ type parserErrTest struct {
 }
 
 var parserErrTests = []parserErrTest{
+	{"x := if c { 1 }", `expected "else"`},
 	{`\`, `unknown token: '\'`},
+	{"0b1210", "bad int literal"},
+	{"x := 0b12", "bad int literal"},
+	{"x := 0b1.1", "invalid radix point in binary literal"},
+	{"x := 0b1e1", "invalid number literal: 0b1e1"},
+	{"x := 0o7.5", "invalid radix point in octal literal"},
+	{"0o8", "bad int literal"},
+	{"0x_1", "bad numeric literal"},
+	{"1__2", "bad numeric literal"},
+	{"1_", "bad numeric literal"},
+	{"1_.5", "bad numeric literal"},
+	{"1_i", "bad numeric literal"},
+	{"0x1.8", "hexadecimal mantissa requires a 'p' exponent"},
+	{"x.5", `expected ";", found "float"`},
+	{"defer x", "expression in defer must be function call"},
+	{"L: var x int", "cannot label declaration"},
+	{"x[1..=]", "inclusive range requires an end"},
+	{"L: const c = 1", "cannot label declaration"},
+	{"{ goto }", "missing label in goto"},
+	{"const (\n\tx, y = iota, iota\n\tz\n)", "wrong number of names in const declaration"},
+	{"func(a ...int, b int) {}", "can only use ... with final parameter in list"},
+	{"func(a, b ...int) {}", "can only use ... with final parameter in list"},
+	{"func(...int, string) {}", "can only use ... with final parameter in list"},
+	{"func(a, b int, string) {}", "function signature mixes named and unnamed arguments"},
+	{"x[::5]", "middle index required in 3-index slice"},
+	{"x[1::5]", "middle index required in 3-index slice"},
+	{"x[1:3:]", "final index required in 3-index slice"},
+	{"type I interface { M(); M() int }", "duplicate method M"},
+	{`[|]num{{1}, {|"Col1"|}}`, "column names can only appear at beginning of table literal"},
+	{"x := [...]int{}", "array literal with [...] length must have at least one element"},
+	{"x := [-1]int{}", "array length must be a non-negative integer constant"},
+	{"x := [1.5]int{1}", "array length must be a non-negative integer constant"},
+	{"x := [...]int{i: 1}", "array index i must be an integer constant"},
+	{"x := $$ echo $((1 +)) $$", "arithmetic expansion $((1 +))"},
+	{"x := $$ echo $((x := 1)) $$", `"x := 1" is not an expression`},
 }
 
 func TestParseError(t *testing.T) {
@@ -374,6 +613,71 @@ func TestParseError(t *testing.T) {
 	}
 }
 
+func TestScanErrorPos(t *testing.T) {
+	src := "x := 1\ny := 2\nz := \xff\n"
+	_, err := parser.New("scanerr.ng").Parse([]byte(src))
+	if err == nil {
+		t.Fatal("missing expected error")
+	}
+	want := "neugram: scanner: bad UTF-8 (line 3, col 6)"
+	if got := err.Error(); !strings.Contains(got, want) {
+		t.Errorf("error %q does not contain %q", got, want)
+	}
+}
+
+func TestShebang(t *testing.T) {
+	src := "#!/usr/bin/env ng\nx := 1\nprint(x)\n"
+	f, err := parser.New("shebang.ng").Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Stmts) != 2 {
+		t.Fatalf("got %d statements, want 2", len(f.Stmts))
+	}
+	if line := f.Stmts[0].Pos().Line; line != 2 {
+		t.Errorf("first statement on line %d, want 2", line)
+	}
+
+	// Only the first line may be a shebang.
+	src = "x := 1\n#!/usr/bin/env ng\n"
+	if _, err := parser.New("shebang.ng").Parse([]byte(src)); err == nil {
+		t.Error("missing expected error for #! after the first line")
+	}
+}
+
+func TestComments(t *testing.T) {
+	src := `// x is the answer.
+x := 42 // trailing
+y := x
+func f() {
+	/* in f */
+	z := 1
+}
+`
+	f, err := parser.New("comments.ng").Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Stmts) != 3 {
+		t.Fatalf("got %d statements, want 3", len(f.Stmts))
+	}
+	if _, isAssign := f.Stmts[0].(*stmt.Assign); !isAssign {
+		t.Fatalf("first statement is %T, want *stmt.Assign", f.Stmts[0])
+	}
+	want := []string{"// x is the answer.", "// trailing"}
+	if got := f.Comments[f.Stmts[0]]; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("x comments: %q, want %q", got, want)
+	}
+	if got := f.Comments[f.Stmts[1]]; len(got) != 0 {
+		t.Errorf("y comments: %q, want none", got)
+	}
+	body := f.Stmts[2].(*stmt.Simple).Expr.(*expr.FuncLiteral).Body.(*stmt.Block)
+	want = []string{"/* in f */"}
+	if got := f.Comments[body.Stmts[0]]; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("z comments: %q, want %q", got, want)
+	}
+}
+
 var shellTests = []parserTest{
 	{``, &expr.Shell{}},
 	{`ls -l`, simplesh("ls", "-l")},
@@ -390,6 +694,68 @@ var shellTests = []parserTest{
 			}},
 		}},
 	}},
+	{"greet() {\n\techo hello $1\n\techo bye\n}; greet ng", &expr.Shell{Cmds: []*expr.ShellList{{
+		AndOr: []*expr.ShellAndOr{
+			{Pipeline: []*expr.ShellPipeline{{
+				Cmd: []*expr.ShellCmd{{FuncDef: &expr.ShellFuncDef{
+					Name: "greet",
+					Body: &expr.ShellList{AndOr: []*expr.ShellAndOr{
+						{Pipeline: []*expr.ShellPipeline{{
+							Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+								Args: []string{"echo", "hello", "$1"},
+							}}},
+						}}},
+						{Pipeline: []*expr.ShellPipeline{{
+							Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+								Args: []string{"echo", "bye"},
+							}}},
+						}}},
+					}},
+				}}},
+			}}},
+			{Pipeline: []*expr.ShellPipeline{{
+				Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+					Args: []string{"greet", "ng"},
+				}}},
+			}}},
+		},
+	}}}},
+	{"f() { echo }; }", &expr.Shell{Cmds: []*expr.ShellList{{
+		AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+			Cmd: []*expr.ShellCmd{{FuncDef: &expr.ShellFuncDef{
+				Name: "f",
+				Body: &expr.ShellList{AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+					Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+						Args: []string{"echo", "}"},
+					}}},
+				}}}}},
+			}}},
+		}}}},
+	}}}},
+	{`make |& grep err | wc || true`, &expr.Shell{
+		Cmds: []*expr.ShellList{{
+			AndOr: []*expr.ShellAndOr{{
+				Pipeline: []*expr.ShellPipeline{
+					{
+						Cmd: []*expr.ShellCmd{
+							{
+								SimpleCmd:  &expr.ShellSimpleCmd{Args: []string{"make"}},
+								PipeStderr: true,
+							},
+							{SimpleCmd: &expr.ShellSimpleCmd{Args: []string{"grep", "err"}}},
+							{SimpleCmd: &expr.ShellSimpleCmd{Args: []string{"wc"}}},
+						},
+					},
+					{
+						Cmd: []*expr.ShellCmd{
+							{SimpleCmd: &expr.ShellSimpleCmd{Args: []string{"true"}}},
+						},
+					},
+				},
+				Sep: []token.Token{token.LogicalOr},
+			}},
+		}},
+	}},
 	{`ls > flist`, &expr.Shell{
 		Cmds: []*expr.ShellList{{
 			AndOr: []*expr.ShellAndOr{{
@@ -405,6 +771,20 @@ var shellTests = []parserTest{
 			}},
 		}},
 	}},
+	{`make &>> build.log`, &expr.Shell{
+		Cmds: []*expr.ShellList{{
+			AndOr: []*expr.ShellAndOr{{
+				Pipeline: []*expr.ShellPipeline{{
+					Cmd: []*expr.ShellCmd{{
+						SimpleCmd: &expr.ShellSimpleCmd{
+							Redirect: []*expr.ShellRedirect{{Token: token.AndTwoGreater, Filename: "build.log"}},
+							Args:     []string{"make"},
+						},
+					}},
+				}},
+			}},
+		}},
+	}},
 	{`echo hi | cat && true || false`, &expr.Shell{
 		Cmds: []*expr.ShellList{{
 			AndOr: []*expr.ShellAndOr{{
@@ -600,12 +980,22 @@ var shellTests = []parserTest{
 			}}},
 		}}}},
 	}}}},
+	{`echo a=b`, simplesh("echo", "a=b")},
+	{`A=b echo c=d`, &expr.Shell{Cmds: []*expr.ShellList{{
+		AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+			Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+				Assign: []expr.ShellAssign{{Key: "A", Value: "b"}},
+				Args:   []string{"echo", "c=d"},
+			}}},
+		}}}},
+	}}}},
 	{`grep -R "fun*foo" .`, simplesh("grep", "-R", `"fun*foo"`, ".")},
 	{`echo -n not_a_file_*`, simplesh("echo", "-n", "not_a_file_*")},
 	{`echo -n "\""`, simplesh("echo", "-n", `"\""`)},
 	{`echo "a b \"" 'c \' \d "e f'g"`, simplesh(
 		"echo", `"a b \""`, `'c \'`, `\d`, `"e f'g"`,
 	)},
+	{`ls "a"b?.c 'c'"d"`, simplesh("ls", `"a"b?.c`, `'c'"d"`)},
 	{`go build "-ldflags=-v -extldflags=-v" pkg`, simplesh("go", "build", `"-ldflags=-v -extldflags=-v"`, "pkg")},
 	{`find . -name \*.c -exec grep -H {} \;
 	ls`, &expr.Shell{Cmds: []*expr.ShellList{
@@ -625,8 +1015,118 @@ var shellTests = []parserTest{
 		},
 	}}},
 	{`echo -n a${VAL}c `, simplesh("echo", "-n", "a${VAL}c")},
-	// TODO {`ls \
-	//-l`, simplesh(`ls`, `-l`)},
+	{`ls \
+-l`, simplesh(`ls`, `-l`)},
+	{`echo a \
+	b`, simplesh(`echo`, `a`, `b`)},
+	{`echo a\
+b`, simplesh(`echo`, `ab`)},
+	{`echo $(echo hi)`, &expr.Shell{Cmds: []*expr.ShellList{{
+		AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+			Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+				Args: []string{"echo", "$(echo hi)"},
+				CmdSubst: []*expr.ShellCmdSubst{{
+					Src: "$(echo hi)",
+					Cmd: simplesh("echo", "hi").Cmds[0],
+				}},
+			}}},
+		}}}},
+	}}}},
+	{`echo a$(ls | wc -l)"$(echo b)"`, &expr.Shell{Cmds: []*expr.ShellList{{
+		AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+			Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+				Args: []string{"echo", `a$(ls | wc -l)"$(echo b)"`},
+				CmdSubst: []*expr.ShellCmdSubst{
+					{
+						Src: "$(ls | wc -l)",
+						Cmd: &expr.ShellList{AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+							Cmd: []*expr.ShellCmd{
+								{SimpleCmd: &expr.ShellSimpleCmd{Args: []string{"ls"}}},
+								{SimpleCmd: &expr.ShellSimpleCmd{Args: []string{"wc", "-l"}}},
+							},
+						}}}}},
+					},
+					{
+						Src: "$(echo b)",
+						Cmd: simplesh("echo", "b").Cmds[0],
+					},
+				},
+			}}},
+		}}}},
+	}}}},
+	{`echo $((1 + 2*(3-x)))`, &expr.Shell{Cmds: []*expr.ShellList{{
+		AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+			Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+				Args: []string{"echo", "$((1 + 2*(3-x)))"},
+				Arith: []*expr.ShellArith{{
+					Src: "$((1 + 2*(3-x)))",
+					Expr: &expr.Binary{
+						Op:   token.Add,
+						Left: basic(1),
+						Right: &expr.Binary{
+							Op:   token.Mul,
+							Left: basic(2),
+							Right: &expr.Paren{Expr: &expr.Binary{
+								Op:    token.Sub,
+								Left:  basic(3),
+								Right: &expr.Ident{Name: "x"},
+							}},
+						},
+					},
+				}},
+			}}},
+		}}}},
+	}}}},
+	{`echo x=$(($x+${y}))`, &expr.Shell{Cmds: []*expr.ShellList{{
+		AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+			Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+				Args: []string{"echo", "x=$(($x+${y}))"},
+				Arith: []*expr.ShellArith{{
+					Src: "$(($x+${y}))",
+					Expr: &expr.Binary{
+						Op:    token.Add,
+						Left:  &expr.Ident{Name: "x"},
+						Right: &expr.Ident{Name: "y"},
+					},
+				}},
+			}}},
+		}}}},
+	}}}},
+	{"cat <<EOF\nhello $x\nEOF\n", &expr.Shell{Cmds: []*expr.ShellList{{
+		AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+			Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+				Redirect: []*expr.ShellRedirect{{
+					Token:    token.TwoLess,
+					Filename: "EOF",
+					Heredoc:  "hello $x\n",
+				}},
+				Args: []string{"cat"},
+			}}},
+		}}}},
+	}}}},
+	{"cat <<-'END' | wc -l\n\ta\n\t\tb\n\tEND\n", &expr.Shell{Cmds: []*expr.ShellList{{
+		AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+			Cmd: []*expr.ShellCmd{
+				{SimpleCmd: &expr.ShellSimpleCmd{
+					Redirect: []*expr.ShellRedirect{{
+						Token:    token.TwoLess,
+						Filename: "'END'",
+						Heredoc:  "a\nb\n",
+					}},
+					Args: []string{"cat"},
+				}},
+				{SimpleCmd: &expr.ShellSimpleCmd{Args: []string{"wc", "-l"}}},
+			},
+		}}}},
+	}}}},
+	{`grep foo <<< "$line"`, &expr.Shell{Cmds: []*expr.ShellList{{
+		AndOr: []*expr.ShellAndOr{{Pipeline: []*expr.ShellPipeline{{
+			Cmd: []*expr.ShellCmd{{SimpleCmd: &expr.ShellSimpleCmd{
+				Redirect: []*expr.ShellRedirect{{Token: token.ThreeLess, Filename: `"$line"`}},
+				Args:     []string{"grep", "foo"},
+			}}},
+		}}}},
+	}}}},
 	// TODO: test unbalanced paren errors
 }
 
@@ -652,7 +1152,7 @@ func TestParseShell(t *testing.T) {
 			t.Errorf("ParseExpr(%q): nil stmt", test.input)
 			continue
 		}
-		got := s.(*stmt.Simple).Expr.(*expr.Unary).Expr.(*expr.Shell)
+		got := s.(*stmt.Simple).Expr.(*expr.Paren).Expr.(*expr.Shell)
 		if !parser.EqualExpr(got, test.want) {
 			t.Errorf("ParseExpr(%q) = %v\ndiff: %s", test.input, format.Debug(got), format.Diff(test.want, got))
 		}
@@ -667,6 +1167,17 @@ type stmtTest struct {
 var stmtTests = []stmtTest{
 	{"for {}", &stmt.For{Body: &stmt.Block{}}},
 	{"for ;; {}", &stmt.For{Body: &stmt.Block{}}},
+	{"{ ;; x := 1; }", &stmt.Block{Stmts: []stmt.Stmt{
+		&stmt.Assign{
+			Decl:  true,
+			Left:  []expr.Expr{&expr.Ident{Name: "x"}},
+			Right: []expr.Expr{basic(1)},
+		},
+	}}},
+	{"{;}", &stmt.Block{}},
+	{"for { ; break; ; }", &stmt.For{Body: &stmt.Block{Stmts: []stmt.Stmt{
+		&stmt.Branch{Type: token.Break},
+	}}}},
 	{"for true {}", &stmt.For{Cond: &expr.Ident{Name: "true"}, Body: &stmt.Block{}}},
 	{"for ; true; {}", &stmt.For{Cond: &expr.Ident{Name: "true"}, Body: &stmt.Block{}}},
 	{"for range x {}", &stmt.Range{Expr: &expr.Ident{Name: "x"}, Body: &stmt.Block{}}},
@@ -681,6 +1192,16 @@ var stmtTests = []stmtTest{
 		Expr: &expr.Ident{Name: "x"},
 		Body: &stmt.Block{},
 	}},
+	{"for i := range 3 { print(i) }", &stmt.Range{
+		Key:  &expr.Ident{Name: "i"},
+		Expr: basic(3),
+		Body: &stmt.Block{Stmts: []stmt.Stmt{
+			&stmt.Simple{Expr: &expr.Call{
+				Func: &expr.Ident{Name: "print"},
+				Args: []expr.Expr{&expr.Ident{Name: "i"}},
+			}},
+		}},
+	}},
 	{
 		"for i := 0; i < 10; i++ { x = i }",
 		&stmt.For{
@@ -710,6 +1231,46 @@ var stmtTests = []stmtTest{
 			}}},
 		},
 	},
+	{"x <<= 2", &stmt.Assign{
+		Left: []expr.Expr{&expr.Ident{Name: "x"}},
+		Right: []expr.Expr{&expr.Binary{
+			Op:    token.TwoLess,
+			Left:  &expr.Ident{Name: "x"},
+			Right: basic(2),
+		}},
+	}},
+	{"x >>= y", &stmt.Assign{
+		Left: []expr.Expr{&expr.Ident{Name: "x"}},
+		Right: []expr.Expr{&expr.Binary{
+			Op:    token.TwoGreater,
+			Left:  &expr.Ident{Name: "x"},
+			Right: &expr.Ident{Name: "y"},
+		}},
+	}},
+	{"x &= y", &stmt.Assign{
+		Left: []expr.Expr{&expr.Ident{Name: "x"}},
+		Right: []expr.Expr{&expr.Binary{
+			Op:    token.Ref,
+			Left:  &expr.Ident{Name: "x"},
+			Right: &expr.Ident{Name: "y"},
+		}},
+	}},
+	{"x |= y", &stmt.Assign{
+		Left: []expr.Expr{&expr.Ident{Name: "x"}},
+		Right: []expr.Expr{&expr.Binary{
+			Op:    token.Pipe,
+			Left:  &expr.Ident{Name: "x"},
+			Right: &expr.Ident{Name: "y"},
+		}},
+	}},
+	{"x &^= y", &stmt.Assign{
+		Left: []expr.Expr{&expr.Ident{Name: "x"}},
+		Right: []expr.Expr{&expr.Binary{
+			Op:    token.RefPow,
+			Left:  &expr.Ident{Name: "x"},
+			Right: &expr.Ident{Name: "y"},
+		}},
+	}},
 	{
 		"const x = 4",
 		&stmt.Const{NameList: []string{"x"}, Values: []expr.Expr{basic(4)}},
@@ -804,11 +1365,58 @@ var stmtTests = []stmtTest{
 			},
 		},
 	},
+	{
+		`const (
+			A = iota
+			B
+			C
+		)`,
+		&stmt.ConstSet{
+			Consts: []*stmt.Const{
+				{NameList: []string{"A"}, Values: []expr.Expr{&expr.Ident{Name: "iota"}}},
+				{NameList: []string{"B"}, Values: []expr.Expr{&expr.Ident{Name: "iota"}}},
+				{NameList: []string{"C"}, Values: []expr.Expr{&expr.Ident{Name: "iota"}}},
+			},
+		},
+	},
 	{"x.y", &stmt.Simple{Expr: &expr.Selector{Left: &expr.Ident{Name: "x"}, Right: &expr.Ident{Name: "y"}}}},
+	{"m[k].Field = v", &stmt.Assign{
+		Left: []expr.Expr{&expr.Selector{
+			Left:  &expr.Index{Left: &expr.Ident{Name: "m"}, Indicies: []expr.Expr{&expr.Ident{Name: "k"}}},
+			Right: &expr.Ident{Name: "Field"},
+		}},
+		Right: []expr.Expr{&expr.Ident{Name: "v"}},
+	}},
+	{"*p = 3", &stmt.Assign{
+		Left:  []expr.Expr{&expr.Unary{Op: token.Mul, Expr: &expr.Ident{Name: "p"}}},
+		Right: []expr.Expr{basic(3)},
+	}},
+	{"a[i][j] = 1", &stmt.Assign{
+		Left: []expr.Expr{&expr.Index{
+			Left:     &expr.Index{Left: &expr.Ident{Name: "a"}, Indicies: []expr.Expr{&expr.Ident{Name: "i"}}},
+			Indicies: []expr.Expr{&expr.Ident{Name: "j"}},
+		}},
+		Right: []expr.Expr{basic(1)},
+	}},
+	{"(*p).x = 1", &stmt.Assign{
+		Left: []expr.Expr{&expr.Selector{
+			Left:  &expr.Paren{Expr: &expr.Unary{Op: token.Mul, Expr: &expr.Ident{Name: "p"}}},
+			Right: &expr.Ident{Name: "x"},
+		}},
+		Right: []expr.Expr{basic(1)},
+	}},
 	{
 		`type A integer`,
 		&stmt.TypeDecl{Name: "A", Type: &tipe.Named{Name: "A", Type: tinteger}},
 	},
+	{
+		`type Byte = uint8`,
+		&stmt.TypeDecl{
+			Name:  "Byte",
+			Type:  &tipe.Named{Name: "Byte", Type: &tipe.Unresolved{Name: "uint8"}},
+			Alias: true,
+		},
+	},
 	{
 		"type Array [2]int",
 		&stmt.TypeDecl{
@@ -860,6 +1468,120 @@ var stmtTests = []stmtTest{
 			Name: "T",
 		},
 	}},
+	{`type Reader interface {
+		Read(p []byte) (n int, err error)
+	}`, &stmt.TypeDecl{
+		Name: "Reader",
+		Type: &tipe.Named{
+			Type: &tipe.Interface{Methods: map[string]*tipe.Func{
+				"Read": {
+					Params: &tipe.Tuple{Elems: []tipe.Type{
+						&tipe.Slice{Elem: &tipe.Unresolved{Name: "byte"}},
+					}},
+					Results: &tipe.Tuple{Elems: []tipe.Type{
+						&tipe.Unresolved{Name: "int"},
+						&tipe.Unresolved{Name: "error"},
+					}},
+				},
+			}},
+			Name: "Reader",
+		},
+	}},
+	{"type ReadCloser interface { Reader; io.Closer; Flush() }", &stmt.TypeDecl{
+		Name: "ReadCloser",
+		Type: &tipe.Named{
+			Type: &tipe.Interface{
+				Methods: map[string]*tipe.Func{
+					"Flush": {Params: &tipe.Tuple{}},
+				},
+				Embeds: []tipe.Type{
+					&tipe.Unresolved{Name: "Reader"},
+					&tipe.Unresolved{Package: "io", Name: "Closer"},
+				},
+			},
+			Name: "ReadCloser",
+		},
+	}},
+	{"type Number interface { ~int | string }", &stmt.TypeDecl{
+		Name: "Number",
+		Type: &tipe.Named{
+			Type: &tipe.Interface{
+				Methods: map[string]*tipe.Func{},
+				Embeds: []tipe.Type{
+					&tipe.Union{Terms: []*tipe.Term{
+						{Tilde: true, Type: &tipe.Unresolved{Name: "int"}},
+						{Type: &tipe.Unresolved{Name: "string"}},
+					}},
+				},
+			},
+			Name: "Number",
+		},
+	}},
+	{"type C interface { fmt.Stringer | []byte; ~float64; M() }", &stmt.TypeDecl{
+		Name: "C",
+		Type: &tipe.Named{
+			Type: &tipe.Interface{
+				Methods: map[string]*tipe.Func{
+					"M": {Params: &tipe.Tuple{}},
+				},
+				Embeds: []tipe.Type{
+					&tipe.Union{Terms: []*tipe.Term{
+						{Type: &tipe.Unresolved{Package: "fmt", Name: "Stringer"}},
+						{Type: &tipe.Slice{Elem: &tipe.Unresolved{Name: "byte"}}},
+					}},
+					&tipe.Union{Terms: []*tipe.Term{
+						{Tilde: true, Type: &tipe.Unresolved{Name: "float64"}},
+					}},
+				},
+			},
+			Name: "C",
+		},
+	}},
+	{"type Pair[K comparable, V any] struct { Key K; Val V }", &stmt.TypeDecl{
+		Name: "Pair",
+		Type: &tipe.Named{
+			TypeParams: []*tipe.TypeParam{
+				{Name: "K", Constraint: &tipe.Unresolved{Name: "comparable"}},
+				{Name: "V", Constraint: &tipe.Unresolved{Name: "any"}},
+			},
+			Type: &tipe.Struct{Fields: []tipe.StructField{
+				{Name: "Key", Type: &tipe.Unresolved{Name: "K"}},
+				{Name: "Val", Type: &tipe.Unresolved{Name: "V"}},
+			}},
+			Name: "Pair",
+		},
+	}},
+	{"type A [N]int", &stmt.TypeDecl{
+		Name: "A",
+		Type: &tipe.Named{
+			Type: &tipe.Array{LenExpr: &expr.Ident{Name: "N"}, Elem: &tipe.Unresolved{Name: "int"}},
+			Name: "A",
+		},
+	}},
+	{"type S []int", &stmt.TypeDecl{
+		Name: "S",
+		Type: &tipe.Named{
+			Type: &tipe.Slice{Elem: &tipe.Unresolved{Name: "int"}},
+			Name: "S",
+		},
+	}},
+	{"type T struct { io.Reader; X int }", &stmt.TypeDecl{
+		Name: "T",
+		Type: &tipe.Named{
+			Type: &tipe.Struct{Fields: []tipe.StructField{
+				{Name: "Reader", Type: &tipe.Unresolved{Package: "io", Name: "Reader"}, Embedded: true},
+				{Name: "X", Type: &tipe.Unresolved{Name: "int"}},
+			}},
+			Name: "T",
+		},
+	}},
+	{"type T struct { *io.Reader }", &stmt.TypeDecl{
+		Name: "T",
+		Type: &tipe.Named{
+			Type: &tipe.Struct{Fields: []tipe.StructField{{Name: "Reader", Type: &tipe.Pointer{Elem: &tipe.Unresolved{Package: "io", Name: "Reader"}}, Embedded: true}}},
+			Name: "T",
+		},
+	}},
 	{"type T struct { A string `json` }", &stmt.TypeDecl{
 		Name: "T",
 		Type: &tipe.Named{
@@ -890,6 +1612,16 @@ var stmtTests = []stmtTest{
 			}}},
 		},
 	}},
+	{"type T struct { A, B string `json:\",omitempty\"`; C int }", &stmt.TypeDecl{
+		Name: "T",
+		Type: &tipe.Named{
+			Type: &tipe.Struct{Fields: []tipe.StructField{
+				{Name: "A", Type: &tipe.Unresolved{Name: "string"}, Tag: `json:",omitempty"`},
+				{Name: "B", Type: &tipe.Unresolved{Name: "string"}, Tag: `json:",omitempty"`},
+				{Name: "C", Type: &tipe.Unresolved{Name: "int"}},
+			}},
+		},
+	}},
 	{`type T struct {
 		_ [4]byte
 		N string
@@ -1020,6 +1752,52 @@ var stmtTests = []stmtTest{
 			}},
 		},
 	},
+	{
+		`methodik AnInt integer {
+			func (a) sum(xs ...integer) integer { return a }
+		}
+		`,
+		&stmt.MethodikDecl{
+			Name: "AnInt",
+			Type: &tipe.Named{
+				Type:        tinteger,
+				MethodNames: []string{"sum"},
+				Methods: []*tipe.Func{{
+					Params:   &tipe.Tuple{Elems: []tipe.Type{&tipe.Ellipsis{Elem: tinteger}}},
+					Results:  &tipe.Tuple{Elems: []tipe.Type{tinteger}},
+					Variadic: true,
+				}},
+			},
+			Methods: []*expr.FuncLiteral{{
+				Name:         "sum",
+				ReceiverName: "a",
+				Type: &tipe.Func{
+					Params:   &tipe.Tuple{Elems: []tipe.Type{&tipe.Ellipsis{Elem: tinteger}}},
+					Results:  &tipe.Tuple{Elems: []tipe.Type{tinteger}},
+					Variadic: true,
+				},
+				ParamNames: []string{"xs"},
+				Body: &stmt.Block{Stmts: []stmt.Stmt{
+					&stmt.Return{Exprs: []expr.Expr{&expr.Ident{Name: "a"}}},
+				}},
+			}},
+		},
+	},
+	{
+		`{
+			for {
+				goto done
+			}
+		done:
+			return
+		}`,
+		&stmt.Block{Stmts: []stmt.Stmt{
+			&stmt.For{Body: &stmt.Block{Stmts: []stmt.Stmt{
+				&stmt.Branch{Type: token.Goto, Label: "done"},
+			}}},
+			&stmt.Labeled{Label: "done", Stmt: &stmt.Return{}},
+		}},
+	},
 	{"S{ X: 7 }", &stmt.Simple{Expr: &expr.CompLiteral{
 		Type:   &tipe.Unresolved{Name: "S"},
 		Keys:   []expr.Expr{&expr.Ident{Name: "X"}},
@@ -1049,8 +1827,7 @@ var stmtTests = []stmtTest{
 		Body: &stmt.Block{},
 	}},
 	{`if (x == T{}) {}`, &stmt.If{
-		Cond: &expr.Unary{
-			Op: token.LeftParen,
+		Cond: &expr.Paren{
 			Expr: &expr.Binary{
 				Op:    token.Equal,
 				Left:  &expr.Ident{Name: "x"},
@@ -1305,6 +2082,16 @@ var stmtTests = []stmtTest{
 		},
 	},
 	{"select {}", &stmt.Select{}},
+	{"L: for {}", &stmt.Labeled{Label: "L", Stmt: &stmt.For{Body: &stmt.Block{}}}},
+	{"M: switch {}", &stmt.Labeled{Label: "M", Stmt: &stmt.Switch{}}},
+	{"S: select {}", &stmt.Labeled{Label: "S", Stmt: &stmt.Select{}}},
+	{"B: {}", &stmt.Labeled{Label: "B", Stmt: &stmt.Block{}}},
+	{
+		"L: for { break L }",
+		&stmt.Labeled{Label: "L", Stmt: &stmt.For{Body: &stmt.Block{Stmts: []stmt.Stmt{
+			&stmt.Branch{Type: token.Break, Label: "L"},
+		}}}},
+	},
 	{`select {
 	case v := <-ch1:
 		print(v)
@@ -1491,6 +2278,68 @@ var stmtTests = []stmtTest{
 		NameList: []string{"i"},
 		Type:     &tipe.Chan{Elem: &tipe.Unresolved{Name: "int"}},
 	}},
+	{"var i chan<- int", &stmt.Var{
+		NameList: []string{"i"},
+		Type:     &tipe.Chan{Direction: tipe.ChanSend, Elem: &tipe.Unresolved{Name: "int"}},
+	}},
+	{"var i <-chan chan<- int", &stmt.Var{
+		NameList: []string{"i"},
+		Type: &tipe.Chan{
+			Direction: tipe.ChanRecv,
+			Elem:      &tipe.Chan{Direction: tipe.ChanSend, Elem: &tipe.Unresolved{Name: "int"}},
+		},
+	}},
+	{"var b [len(a)]int", &stmt.Var{
+		NameList: []string{"b"},
+		Type: &tipe.Array{
+			Elem: &tipe.Unresolved{Name: "int"},
+			LenExpr: &expr.Call{
+				Func: &expr.Ident{Name: "len"},
+				Args: []expr.Expr{&expr.Ident{Name: "a"}},
+			},
+		},
+	}},
+	{"var x map[string][]chan int", &stmt.Var{
+		NameList: []string{"x"},
+		Type: &tipe.Map{
+			Key:   &tipe.Unresolved{Name: "string"},
+			Value: &tipe.Slice{Elem: &tipe.Chan{Elem: &tipe.Unresolved{Name: "int"}}},
+		},
+	}},
+	{"var f func() (func() int)", &stmt.Var{
+		NameList: []string{"f"},
+		Type: &tipe.Func{
+			Params: &tipe.Tuple{},
+			Results: &tipe.Tuple{Elems: []tipe.Type{&tipe.Func{
+				Params:  &tipe.Tuple{},
+				Results: &tipe.Tuple{Elems: []tipe.Type{&tipe.Unresolved{Name: "int"}}},
+			}}},
+		},
+	}},
+	{"var c chan (<-chan int)", &stmt.Var{
+		NameList: []string{"c"},
+		Type: &tipe.Chan{Elem: &tipe.Chan{
+			Direction: tipe.ChanRecv,
+			Elem:      &tipe.Unresolved{Name: "int"},
+		}},
+	}},
+	{"x := map[string][]func() error{}", &stmt.Assign{
+		Decl: true,
+		Left: []expr.Expr{&expr.Ident{Name: "x"}},
+		Right: []expr.Expr{&expr.MapLiteral{
+			Type: &tipe.Map{
+				Key: &tipe.Unresolved{Name: "string"},
+				Value: &tipe.Slice{Elem: &tipe.Func{
+					Params:  &tipe.Tuple{},
+					Results: &tipe.Tuple{Elems: []tipe.Type{&tipe.Unresolved{Name: "error"}}},
+				}},
+			},
+		}},
+	}},
+	{"var p *int", &stmt.Var{
+		NameList: []string{"p"},
+		Type:     &tipe.Pointer{Elem: &tipe.Unresolved{Name: "int"}},
+	}},
 	{"var i []int", &stmt.Var{
 		NameList: []string{"i"},
 		Type:     &tipe.Slice{Elem: &tipe.Unresolved{Name: "int"}},
@@ -1546,6 +2395,29 @@ var stmtTests = []stmtTest{
 			Values: []expr.Expr{basic(2)},
 		}},
 	}},
+	{"x := [3]int{1, 2, 3}", &stmt.Assign{
+		Decl: true,
+		Left: []expr.Expr{&expr.Ident{Name: "x"}},
+		Right: []expr.Expr{&expr.ArrayLiteral{
+			Type: &tipe.Array{
+				Len:  3,
+				Elem: &tipe.Unresolved{Name: "int"},
+			},
+			Values: []expr.Expr{basic(1), basic(2), basic(3)},
+		}},
+	}},
+	{"x := [...]int{1, 2, 3}", &stmt.Assign{
+		Decl: true,
+		Left: []expr.Expr{&expr.Ident{Name: "x"}},
+		Right: []expr.Expr{&expr.ArrayLiteral{
+			Type: &tipe.Array{
+				Len:      3,
+				Elem:     &tipe.Unresolved{Name: "int"},
+				Ellipsis: true,
+			},
+			Values: []expr.Expr{basic(1), basic(2), basic(3)},
+		}},
+	}},
 	{"var i = []int{1:2}", &stmt.Var{
 		NameList: []string{"i"},
 		Values: []expr.Expr{&expr.SliceLiteral{
@@ -1593,11 +2465,24 @@ var stmtTests = []stmtTest{
 	{"0xdeadbeef", &stmt.Simple{Expr: basic(0xdeadbeef)}},
 	{"0xDEADBEEF", &stmt.Simple{Expr: basic(0xDEADBEEF)}},
 	{"0xdEadb33f", &stmt.Simple{Expr: basic(0xdEadb33f)}},
-	{"0X0", &stmt.Simple{Expr: basic(0X0)}},
-	{"0X1", &stmt.Simple{Expr: basic(0X1)}},
-	{"0Xdeadbeef", &stmt.Simple{Expr: basic(0Xdeadbeef)}},
-	{"0XDEADBEEF", &stmt.Simple{Expr: basic(0XDEADBEEF)}},
-	{"0XdEadb33f", &stmt.Simple{Expr: basic(0XdEadb33f)}},
+	{"0X0", &stmt.Simple{Expr: basic(0x0)}},
+	{"0X1", &stmt.Simple{Expr: basic(0x1)}},
+	{"0Xdeadbeef", &stmt.Simple{Expr: basic(0xdeadbeef)}},
+	{"0XDEADBEEF", &stmt.Simple{Expr: basic(0xDEADBEEF)}},
+	{"0XdEadb33f", &stmt.Simple{Expr: basic(0xdEadb33f)}},
+	{"0b0", &stmt.Simple{Expr: basic(0)}},
+	{"0b1111", &stmt.Simple{Expr: basic(15)}},
+	{"0B101", &stmt.Simple{Expr: basic(5)}},
+	{"0o17", &stmt.Simple{Expr: basic(15)}},
+	{"0O17", &stmt.Simple{Expr: basic(15)}},
+	{"1_000", &stmt.Simple{Expr: basic(1000)}},
+	{"1_000_000", &stmt.Simple{Expr: basic(1000000)}},
+	{"0xff_ff", &stmt.Simple{Expr: basic(0xffff)}},
+	{"0b1_0", &stmt.Simple{Expr: basic(2)}},
+	{"1_0.2_5", &stmt.Simple{Expr: basic(10.25)}},
+	{"0x1p-2", &stmt.Simple{Expr: basic(0.25)}},
+	{"0x1.8p3", &stmt.Simple{Expr: basic(12.0)}},
+	{"0X1P+4", &stmt.Simple{Expr: basic(16.0)}},
 	{"defer f()", &stmt.Defer{Expr: &expr.Call{Func: &expr.Ident{Name: "f"}}}},
 	{"defer f.Close()", &stmt.Defer{Expr: &expr.Call{
 		Func: &expr.Selector{
@@ -5,13 +5,17 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
 	"neugram.io/ng/internal/bigcplx"
+	"neugram.io/ng/syntax/shell"
+	"neugram.io/ng/syntax/src"
 	"neugram.io/ng/syntax/token"
 )
 
@@ -26,6 +30,51 @@ func newScanner() *Scanner {
 	return s
 }
 
+// TokenInfo is a token scanned by Tokenize.
+type TokenInfo struct {
+	Token   token.Token
+	Literal interface{} // string, *big.Int, *big.Float, *bigcplx.Complex, rune
+	Line    int32       // 1-based line of the first byte of the token
+	Column  int16       // 1-based column of the first byte of the token
+	Offset  int         // byte offset of the first byte of the token
+}
+
+// Tokenize scans all of b and returns its tokens, including
+// comments and automatically inserted semicolons.
+//
+// On error, Tokenize returns the tokens scanned so far.
+func Tokenize(b []byte) ([]TokenInfo, error) {
+	s := &Scanner{Line: 1, src: b}
+	s.next()
+	var toks []TokenInfo
+	for {
+		s.skipWhitespace()
+		tok := TokenInfo{
+			Line:   s.Line,
+			Column: s.Column + 1,
+			Offset: s.Offset,
+		}
+		s.Next()
+		if s.err != nil {
+			return toks, s.err
+		}
+		if s.Token == token.Unknown {
+			if s.Literal == nil {
+				return toks, nil // EOF
+			}
+			return toks, Error{
+				Pos:     src.Pos{Line: tok.Line, Column: tok.Column},
+				Offset:  tok.Offset,
+				Msg:     fmt.Sprintf("unknown token: %q", s.Literal),
+				scanner: true,
+			}
+		}
+		tok.Token = s.Token
+		tok.Literal = s.Literal
+		toks = append(toks, tok)
+	}
+}
+
 type Scanner struct {
 	// Current Token
 	Line      int32
@@ -44,27 +93,51 @@ type Scanner struct {
 	inShell      bool
 	exitingShell bool // set mid $$ token when we have read ahead too far
 
+	// Here-document bodies follow the line of their command.
+	// When the scanner reaches the end of that line, at offset
+	// heredocFrom, it skips the bodies to heredocTo.
+	heredocFrom  int
+	heredocTo    int
+	heredocLines int32
+
 	addSrc  chan []byte
 	needSrc chan struct{}
 }
 
 func (s *Scanner) errorf(format string, a ...interface{}) {
-	s.err = fmt.Errorf("neugram: scanner: %s (off %d)", fmt.Sprintf(format, a...), s.Offset)
+	s.err = Error{
+		Pos: src.Pos{
+			Line:   s.Line,
+			Column: s.Column + 1,
+		},
+		Offset:  s.Offset,
+		Msg:     fmt.Sprintf(format, a...),
+		scanner: true,
+	}
 }
 
 func (s *Scanner) drain() {
 	for s.off < len(s.src) {
 		s.next()
 	}
+	s.semi = false
 }
 
 func (s *Scanner) next() {
+	if s.heredocTo > 0 && s.off == s.heredocFrom {
+		s.off = s.heredocTo
+		s.Line += s.heredocLines
+		s.heredocFrom, s.heredocTo, s.heredocLines = 0, 0, 0
+	}
 	if s.off >= len(s.src) {
 		if s.r == -1 {
 			return
 		}
-		s.needSrc <- struct{}{}
-		b := <-s.addSrc
+		var b []byte
+		if s.addSrc != nil {
+			s.needSrc <- struct{}{}
+			b = <-s.addSrc
+		}
 		if b == nil {
 			s.Offset = len(s.src)
 			s.Token = token.Unknown
@@ -83,21 +156,20 @@ func (s *Scanner) next() {
 	}
 	var w int
 	s.r, w = rune(s.src[s.off]), 1
-	switch {
-	case s.r == 0:
-		s.errorf("bad UTF-8: zero byte")
-	case s.r >= 0x80:
+	if s.r >= 0x80 {
 		s.r, w = utf8.DecodeRune(s.src[s.off:])
-		if s.r == utf8.RuneError && w == 1 {
-			s.errorf("bad UTF-8")
-		} else if s.r == bom {
-			s.errorf("bad byte order marker")
-		}
 	}
 	s.Column += s.lastWidth
 	s.lastWidth = int16(w)
 	s.off += w
-	return
+	switch {
+	case s.r == 0:
+		s.errorf("bad UTF-8: zero byte")
+	case s.r == utf8.RuneError && w == 1:
+		s.errorf("bad UTF-8")
+	case s.r == bom:
+		s.errorf("bad byte order marker")
+	}
 }
 
 func (s *Scanner) skipWhitespace() {
@@ -116,10 +188,25 @@ func (s *Scanner) scanIdentifier() string {
 
 func (s *Scanner) scanShellWord() string {
 	off := s.Offset
+	var joins []int // offsets of backslash-newline line continuations
+	word := func(end int) string {
+		if len(joins) == 0 {
+			return string(s.src[off:end])
+		}
+		var buf []byte
+		for _, j := range joins {
+			buf = append(buf, s.src[off:j]...)
+			off = j + 2
+		}
+		return string(append(buf, s.src[off:end]...))
+	}
 	for {
 		switch s.r {
 		case '\\':
 			s.next()
+			if s.r == '\n' {
+				joins = append(joins, s.Offset-1)
+			}
 			s.next()
 		case '$':
 			s.next()
@@ -138,38 +225,187 @@ func (s *Scanner) scanShellWord() string {
 				// remaining "$" as "$$".
 				s.exitingShell = true
 
-				return string(s.src[off : s.Offset-1])
+				return word(s.Offset - 1)
 			case '{':
 				for s.r != '}' {
 					s.next()
 				}
 				s.next()
+			case '(':
+				s.scanCmdSubst()
 			}
+		case '"', '\'':
+			// Quoted section inside a word, for example: x="a b"
+			q := s.r
+			s.next()
+			for s.r != q && s.r > 0 {
+				if q == '"' && s.r == '\\' {
+					s.next()
+				}
+				s.next()
+			}
+			s.next()
 		case ' ', '\t', '\n', '\r', '|', '&', ';', '<', '>', '(', ')':
-			return string(s.src[off:s.Offset])
+			return word(s.Offset)
 		default:
 			s.next()
 		}
 	}
 }
 
+// scanCmdSubst scans the parenthesized command of a command
+// substitution, $(cmd). The scanner is positioned on the '('.
+func (s *Scanner) scanCmdSubst() {
+	depth := 0
+	for s.r > 0 {
+		switch s.r {
+		case '\\':
+			s.next()
+		case '"', '\'':
+			q := s.r
+			s.next()
+			for s.r != q && s.r > 0 {
+				if q == '"' && s.r == '\\' {
+					s.next()
+				}
+				s.next()
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				s.next()
+				return
+			}
+		}
+		s.next()
+	}
+	s.errorf("command substitution missing terminating `)`")
+}
+
+// scanHeredoc scans the body of the here-document introduced by
+// "<<", or by "<<-" if stripTabs is set, which removes the leading
+// tabs of each line. The body is made of the lines following the
+// current one, up to a line holding only the delimiter word.
+//
+// The scanner is left on the delimiter word, the next token,
+// and skips the body when it reaches the end of the current line.
+func (s *Scanner) scanHeredoc(stripTabs bool) string {
+	off, offset, r := s.off, s.Offset, s.r
+	line, col, lastWidth := s.Line, s.Column, s.lastWidth
+	exitingShell := s.exitingShell
+	for s.r == ' ' || s.r == '\t' {
+		s.next()
+	}
+	delim := shell.HeredocDelim(s.scanShellWord())
+	for s.r != '\n' && s.r > 0 {
+		s.next()
+	}
+	eol := s.off
+	s.off, s.Offset, s.r = off, offset, r
+	s.Line, s.Column, s.lastWidth = line, col, lastWidth
+	s.exitingShell = exitingShell
+
+	if delim == "" {
+		s.errorf("here-document missing delimiter")
+		return ""
+	}
+	bodyOff := eol
+	if s.heredocTo > 0 && s.heredocFrom == eol {
+		bodyOff = s.heredocTo // a second here-document on the line
+	} else {
+		s.heredocFrom, s.heredocLines = eol, 0
+	}
+	var body []byte
+	for {
+		l, ok := s.srcLine(bodyOff)
+		if !ok {
+			s.errorf("here-document missing delimiter %s", delim)
+			return ""
+		}
+		bodyOff += len(l)
+		s.heredocLines++
+		l = bytes.TrimSuffix(l, []byte{'\n'})
+		if stripTabs {
+			l = bytes.TrimLeft(l, "\t")
+		}
+		if string(l) == delim {
+			break
+		}
+		body = append(append(body, l...), '\n')
+	}
+	s.heredocTo = bodyOff
+	return string(body)
+}
+
+// srcLine returns the line of source starting at off, including
+// its newline, reading more source if necessary. It reports false
+// if the source ends first.
+func (s *Scanner) srcLine(off int) ([]byte, bool) {
+	for {
+		if i := bytes.IndexByte(s.src[off:], '\n'); i >= 0 {
+			return s.src[off : off+i+1], true
+		}
+		if s.addSrc == nil {
+			return nil, false
+		}
+		s.needSrc <- struct{}{}
+		b := <-s.addSrc
+		if b == nil {
+			s.addSrc = nil // no more source for next either
+			return nil, false
+		}
+		s.src = append(s.src, b...)
+	}
+}
+
 func (s *Scanner) scanMantissa() {
-	for '0' <= s.r && s.r <= '9' {
+	for ('0' <= s.r && s.r <= '9') || s.r == '_' {
 		s.next()
 	}
 }
 
 func (s *Scanner) scanHexa() {
-	for ('0' <= s.r && s.r <= '9') ||
-		('a' <= s.r && s.r <= 'f') ||
-		('A' <= s.r && s.r <= 'F') {
+	for isHexDigit(s.r) || s.r == '_' {
 		s.next()
 	}
 }
 
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+func isHexDigit(r rune) bool {
+	return ('0' <= r && r <= '9') ||
+		('a' <= r && r <= 'f') ||
+		('A' <= r && r <= 'F')
+}
+
+// validUnderscores reports whether every '_' in the numeric
+// literal lit is a digit separator, that is, directly between
+// two digits.
+func validUnderscores(lit string) bool {
+	digit := isDigit
+	if len(lit) > 1 && lit[0] == '0' && (lit[1] == 'x' || lit[1] == 'X') {
+		digit = isHexDigit
+	}
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(lit)-1 || !digit(rune(lit[i-1])) || !digit(rune(lit[i+1])) {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Scanner) scanNumber(seenDot bool) (token.Token, interface{}) {
 	off := s.Offset
 	tok := token.Int
+	hex := false
+	radix := "" // "binary" or "octal" for a prefixed integer literal
 
 	if seenDot {
 		off--
@@ -182,19 +418,44 @@ func (s *Scanner) scanNumber(seenDot bool) (token.Token, interface{}) {
 
 	// hexa
 	if (s.r == 'x' || s.r == 'X') && string(s.src[off:s.Offset]) == "0" {
+		hex = true
 		s.next()
 		s.scanHexa()
 	}
 
-	// fraction
-	if s.r == '.' {
-		tok = token.Float
+	// binary
+	if (s.r == 'b' || s.r == 'B') && string(s.src[off:s.Offset]) == "0" {
+		radix = "binary"
 		s.next()
 		s.scanMantissa()
 	}
 
+	// octal
+	if (s.r == 'o' || s.r == 'O') && string(s.src[off:s.Offset]) == "0" {
+		radix = "octal"
+		s.next()
+		s.scanMantissa()
+	}
+
+	// fraction, but not a range such as 1..3
+	if s.r == '.' && (s.off >= len(s.src) || s.src[s.off] != '.') {
+		if radix != "" {
+			s.errorf("invalid radix point in %s literal", radix)
+			return token.Unknown, nil
+		}
+		tok = token.Float
+		s.next()
+		if hex {
+			s.scanHexa()
+		} else {
+			s.scanMantissa()
+		}
+	}
+
 exponent:
-	if s.r == 'e' || s.r == 'E' {
+	exp := false
+	if (!hex && radix == "" && (s.r == 'e' || s.r == 'E')) || (hex && (s.r == 'p' || s.r == 'P')) {
+		exp = true
 		tok = token.Float
 		s.next()
 		if s.r == '-' || s.r == '+' {
@@ -202,13 +463,30 @@ exponent:
 		}
 		s.scanMantissa()
 	}
+	if hex && tok == token.Float && !exp {
+		s.errorf("hexadecimal mantissa requires a 'p' exponent: %q", string(s.src[off:s.Offset]))
+		return token.Unknown, nil
+	}
 
 	if s.r == 'i' {
 		tok = token.Imaginary
 		s.next()
 	}
 
+	// A letter directly after the literal, as in 3abc, is an error
+	// rather than a number followed by an identifier.
+	if unicode.IsLetter(s.r) {
+		s.scanIdentifier()
+		s.errorf("invalid number literal: %s", s.src[off:s.Offset])
+		return token.Unknown, nil
+	}
+
 	str := string(s.src[off:s.Offset])
+	if !validUnderscores(str) {
+		s.errorf("bad numeric literal: %q", str)
+		return token.Unknown, nil
+	}
+	str = strings.Replace(str, "_", "", -1)
 	var value interface{}
 	switch tok {
 	case token.Int:
@@ -264,6 +542,7 @@ func (s *Scanner) scanSingleQuotedShellWord() string {
 func (s *Scanner) scanRawString() string {
 	off := s.Offset
 
+	hasCR := false
 	for {
 		r := s.r
 		if r <= 0 {
@@ -274,8 +553,15 @@ func (s *Scanner) scanRawString() string {
 		if r == '`' {
 			break
 		}
+		if r == '\r' {
+			hasCR = true
+		}
+	}
+	lit := s.src[off : s.Offset-1]
+	if hasCR {
+		lit = stripCR(lit, false)
 	}
-	return "`" + string(s.src[off:s.Offset-1]) + "`"
+	return "`" + string(lit) + "`"
 }
 
 func (s *Scanner) scanRune() rune {
@@ -317,9 +603,12 @@ func (s *Scanner) scanString(spanNewlines bool) string {
 		}
 		s.next()
 		if r == '\\' {
-			if s.r == '"' {
+			// Skip the escaped character, so neither
+			// \" nor \\ can end the string.
+			if s.r > 0 && (spanNewlines || s.r != '\n') {
 				s.next()
 			}
+			continue
 		}
 		if r == '"' {
 			break
@@ -336,10 +625,14 @@ func (s *Scanner) scanString(spanNewlines bool) string {
 func (s *Scanner) scanComment() string {
 	off := s.Offset - 1 // already ate the first '/'
 
+	numCR := 0
 	if s.r == '/' {
 		// single line "// comment"
 		s.next()
 		for s.r > 0 && s.r != '\n' {
+			if s.r == '\r' {
+				numCR++
+			}
 			s.next()
 		}
 	} else {
@@ -348,6 +641,9 @@ func (s *Scanner) scanComment() string {
 		terminated := false
 		for s.r > 0 {
 			r := s.r
+			if r == '\r' {
+				numCR++
+			}
 			s.next()
 			if r == '*' && s.r == '/' {
 				s.next()
@@ -356,15 +652,39 @@ func (s *Scanner) scanComment() string {
 			}
 		}
 		if !terminated {
-			s.err = fmt.Errorf("multi-line comment not terminated") // TODO offset
+			s.errorf("multi-line comment not terminated")
 		}
 	}
 
 	lit := s.src[off:s.Offset]
-	// TODO remove any \r in comments?
+	// On Windows, a //-comment line may end in "\r\n".
+	if numCR > 0 && len(lit) >= 2 && lit[1] == '/' && lit[len(lit)-1] == '\r' {
+		lit = lit[:len(lit)-1]
+		numCR--
+	}
+	if numCR > 0 {
+		lit = stripCR(lit, lit[1] == '*')
+	}
 	return string(lit)
 }
 
+// stripCR returns b with any '\r' removed, as the Go scanner
+// does for raw strings and comments.
+func stripCR(b []byte, comment bool) []byte {
+	c := make([]byte, len(b))
+	i := 0
+	for j, ch := range b {
+		// In a /*-style comment, don't strip \r from *\r/
+		// since the resulting */ would terminate the comment
+		// too early.
+		if ch != '\r' || comment && i > len("/*") && c[i-1] == '*' && j+1 < len(b) && b[j+1] == '/' {
+			c[i] = ch
+			i++
+		}
+	}
+	return c[:i]
+}
+
 func (s *Scanner) nextInShell() {
 	if s.exitingShell {
 		if s.r != '$' {
@@ -387,19 +707,29 @@ func (s *Scanner) nextInShell() {
 			s.semi = true
 		} else {
 			s.semi = true
-			s.Literal = "$" + s.scanShellWord()
+			off := s.Offset - 1
+			if s.r == '(' {
+				s.scanCmdSubst()
+			}
+			s.Literal = string(s.src[off:s.Offset]) + s.scanShellWord()
 			s.Token = token.ShellWord
 		}
 	case '"':
 		s.next()
 		s.semi = true
 		str := s.scanString(true)
+		if s.r > 0 {
+			str += s.scanShellWord() // partly quoted, as in "a"b
+		}
 		s.Literal = str
 		s.Token = token.ShellWord
 	case '\'':
 		s.next()
 		s.semi = true
 		str := s.scanSingleQuotedShellWord()
+		if s.r > 0 {
+			str += s.scanShellWord()
+		}
 		s.Literal = str
 		s.Token = token.ShellWord
 	case '\n':
@@ -413,6 +743,9 @@ func (s *Scanner) nextInShell() {
 		case '|':
 			s.next()
 			s.Token = token.LogicalOr
+		case '&':
+			s.next()
+			s.Token = token.ShellPipeAnd
 		default:
 			s.Token = token.ShellPipe
 		}
@@ -424,12 +757,32 @@ func (s *Scanner) nextInShell() {
 			s.Token = token.LogicalAnd
 		case '>':
 			s.next()
+			if s.r == '>' {
+				s.next()
+				s.Token = token.AndTwoGreater
+				break
+			}
 			s.Token = token.AndGreater
 		default:
 			s.Token = token.Ref
 		}
 	case '<':
 		s.next()
+		if s.r == '<' {
+			s.next()
+			if s.r == '<' {
+				s.next()
+				s.Token = token.ThreeLess
+				break
+			}
+			stripTabs := s.r == '-'
+			if stripTabs {
+				s.next()
+			}
+			s.Literal = s.scanHeredoc(stripTabs)
+			s.Token = token.TwoLess
+			break
+		}
 		s.Token = token.Less
 	case '>':
 		s.next()
@@ -474,6 +827,14 @@ func (s *Scanner) Next() {
 	switch {
 	case s.inShell:
 		//fmt.Printf("inShell, r=%q\n", string(r))
+		for s.r == '\\' && s.off < len(s.src) && s.src[s.off] == '\n' {
+			// Line continuation, join the two lines.
+			s.next()
+			s.next()
+			for s.r == ' ' || s.r == '\t' || s.r == '\r' {
+				s.next()
+			}
+		}
 		s.nextInShell()
 		return
 	case unicode.IsLetter(r) || r == '_':
@@ -550,10 +911,19 @@ func (s *Scanner) Next() {
 	case '.':
 		if s.r == '.' {
 			s.next()
-			if s.r == '.' {
+			switch s.r {
+			case '.':
 				s.next()
 				s.Token = token.Ellipsis
+			case '=':
+				s.next()
+				s.Token = token.TwoPeriodEq
+			default:
+				s.Token = token.TwoPeriod
 			}
+		} else if '0' <= s.r && s.r <= '9' {
+			s.semi = true
+			s.Token, s.Literal = s.scanNumber(true)
 		} else {
 			s.Token = token.Period
 		}
@@ -631,9 +1001,9 @@ func (s *Scanner) Next() {
 		switch s.r {
 		case '=':
 			s.next()
-			s.Token = token.PowAssign
+			s.Token = token.XorAssign
 		default:
-			s.Token = token.Pow
+			s.Token = token.Xor
 		}
 	case '>':
 		switch s.r {
@@ -642,7 +1012,12 @@ func (s *Scanner) Next() {
 			s.Token = token.GreaterEqual
 		case '>':
 			s.next()
-			s.Token = token.TwoGreater
+			if s.r == '=' {
+				s.next()
+				s.Token = token.ShiftRightAssign
+			} else {
+				s.Token = token.TwoGreater
+			}
 		default:
 			s.Token = token.Greater
 		}
@@ -656,7 +1031,12 @@ func (s *Scanner) Next() {
 			s.Token = token.LessEqual
 		case '<':
 			s.next()
-			s.Token = token.TwoLess
+			if s.r == '=' {
+				s.next()
+				s.Token = token.ShiftLeftAssign
+			} else {
+				s.Token = token.TwoLess
+			}
 		default:
 			s.Token = token.Less
 		}
@@ -667,7 +1047,15 @@ func (s *Scanner) Next() {
 			s.Token = token.LogicalAnd
 		case '^':
 			s.next()
-			s.Token = token.RefPow
+			if s.r == '=' {
+				s.next()
+				s.Token = token.AndNotAssign
+			} else {
+				s.Token = token.RefPow
+			}
+		case '=':
+			s.next()
+			s.Token = token.AndAssign
 		default:
 			s.Token = token.Ref
 		}
@@ -689,6 +1077,9 @@ func (s *Scanner) Next() {
 			s.next()
 			s.semi = true
 			s.Token = token.RightBraceTable
+		case '=':
+			s.next()
+			s.Token = token.OrAssign
 		default:
 			s.Token = token.Pipe
 		}
@@ -700,6 +1091,8 @@ func (s *Scanner) Next() {
 		default:
 			s.Token = token.Not
 		}
+	case '~':
+		s.Token = token.Tilde
 	default:
 		s.Token = token.Unknown
 		s.Literal = string(r)
@@ -6,6 +6,8 @@ package parser
 
 import (
 	"math/big"
+	"strings"
+	"testing"
 
 	"neugram.io/ng/syntax/token"
 )
@@ -92,3 +94,177 @@ func TestScannerJoin(t *testing.T) {
 	}
 }
 */
+
+func TestScannerStripCR(t *testing.T) {
+	tests := []struct {
+		input   string
+		token   token.Token
+		literal string
+	}{
+		{"`a\r\nb`", token.String, "`a\nb`"},
+		{"`\r\r`", token.String, "``"},
+		{"/* a\r\nb */", token.Comment, "/* a\nb */"},
+		{"/* a *\r/ b */", token.Comment, "/* a *\r/ b */"},
+		{"// a\r\n", token.Comment, "// a"},
+	}
+	for _, test := range tests {
+		s := newScanner()
+		s.src = []byte(test.input + "\n")
+		s.needSrc = make(chan struct{}, 1)
+		close(s.addSrc) // no more source
+		s.next()
+		s.Next()
+		if s.err != nil {
+			t.Errorf("%q: %v", test.input, s.err)
+			continue
+		}
+		if s.Token != test.token {
+			t.Errorf("%q: got %s, want %s", test.input, s.Token, test.token)
+			continue
+		}
+		if s.Literal != test.literal {
+			t.Errorf("%q literal: got %q, want %q", test.input, s.Literal, test.literal)
+		}
+	}
+}
+
+func TestScannerAssignOps(t *testing.T) {
+	tests := []struct {
+		input string
+		token token.Token
+	}{
+		{"+=", token.AddAssign},
+		{"^=", token.XorAssign},
+		{"<<=", token.ShiftLeftAssign},
+		{">>=", token.ShiftRightAssign},
+		{"&=", token.AndAssign},
+		{"|=", token.OrAssign},
+		{"&^=", token.AndNotAssign},
+		{"&^", token.RefPow},
+		{"|", token.Pipe},
+		{"..", token.TwoPeriod},
+		{"..=", token.TwoPeriodEq},
+		{"...", token.Ellipsis},
+		{".", token.Period},
+	}
+	for _, test := range tests {
+		s := newScanner()
+		s.src = []byte(test.input + " x\n")
+		s.needSrc = make(chan struct{}, 1)
+		close(s.addSrc) // no more source
+		s.next()
+		s.Next()
+		if s.err != nil {
+			t.Errorf("%q: %v", test.input, s.err)
+			continue
+		}
+		if s.Token != test.token {
+			t.Errorf("%q: got %s, want %s", test.input, s.Token, test.token)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	const src = `x := 42 // answer
+if x > 1 {
+	print("big")
+}
+`
+	want := []TokenInfo{
+		{Token: token.Ident, Literal: "x", Line: 1, Column: 1, Offset: 0},
+		{Token: token.Define, Line: 1, Column: 3, Offset: 2},
+		{Token: token.Int, Literal: big.NewInt(42), Line: 1, Column: 6, Offset: 5},
+		{Token: token.Comment, Literal: "// answer", Line: 1, Column: 9, Offset: 8},
+		{Token: token.Semicolon, Line: 1, Column: 18, Offset: 17},
+		{Token: token.If, Line: 2, Column: 1, Offset: 18},
+		{Token: token.Ident, Literal: "x", Line: 2, Column: 4, Offset: 21},
+		{Token: token.Greater, Line: 2, Column: 6, Offset: 23},
+		{Token: token.Int, Literal: big.NewInt(1), Line: 2, Column: 8, Offset: 25},
+		{Token: token.LeftBrace, Line: 2, Column: 10, Offset: 27},
+		{Token: token.Ident, Literal: "print", Line: 3, Column: 2, Offset: 30},
+		{Token: token.LeftParen, Line: 3, Column: 7, Offset: 35},
+		{Token: token.String, Literal: `"big"`, Line: 3, Column: 8, Offset: 36},
+		{Token: token.RightParen, Line: 3, Column: 13, Offset: 41},
+		{Token: token.Semicolon, Line: 3, Column: 14, Offset: 42},
+		{Token: token.RightBrace, Line: 4, Column: 1, Offset: 43},
+		{Token: token.Semicolon, Line: 4, Column: 2, Offset: 44},
+	}
+	got, err := Tokenize([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d tokens, want %d", len(got), len(want))
+	}
+	for i := 0; i < len(got) && i < len(want); i++ {
+		g, w := got[i], want[i]
+		if g.Token != w.Token || !equalLiteral(g.Literal, w.Literal) ||
+			g.Line != w.Line || g.Column != w.Column || g.Offset != w.Offset {
+			t.Errorf("token %d: got %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestTokenizeError(t *testing.T) {
+	toks, err := Tokenize([]byte("x := 1\ny \\ 2"))
+	if err == nil {
+		t.Fatal("missing error")
+	}
+	if want := `unknown token: "\\"`; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+	if e, ok := err.(Error); !ok || e.Pos.Line != 2 || e.Pos.Column != 3 {
+		t.Errorf("error position: %v", err)
+	}
+	if len(toks) != 5 {
+		t.Errorf("got %d tokens before error, want 5", len(toks))
+	}
+}
+
+func TestTokenizeNumberIdent(t *testing.T) {
+	_, err := Tokenize([]byte("3abc"))
+	if err == nil {
+		t.Fatal("3abc: missing error")
+	}
+	if want := "invalid number literal: 3abc"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+
+	toks, err := Tokenize([]byte("3 abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []token.Token{token.Int, token.Ident, token.Semicolon}
+	if len(toks) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(toks), len(want))
+	}
+	for i, tok := range toks {
+		if tok.Token != want[i] {
+			t.Errorf("token %d: got %v, want %v", i, tok.Token, want[i])
+		}
+	}
+}
+
+func TestTokenizeRange(t *testing.T) {
+	toks, err := Tokenize([]byte("1..3 1..=3 1.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []token.Token{
+		token.Int, token.TwoPeriod, token.Int,
+		token.Int, token.TwoPeriodEq, token.Int,
+		token.Float, token.Semicolon,
+	}
+	var got []token.Token
+	for _, tok := range toks {
+		got = append(got, tok.Token)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("token %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
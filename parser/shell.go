@@ -5,10 +5,16 @@
 package parser
 
 import (
+	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 	"unicode"
 
+	"neugram.io/ng/syntax"
 	"neugram.io/ng/syntax/expr"
+	"neugram.io/ng/syntax/shell"
+	"neugram.io/ng/syntax/stmt"
 	"neugram.io/ng/syntax/token"
 )
 
@@ -25,7 +31,7 @@ func (p *Parser) parseShellList() *expr.ShellList {
 			l.AndOr[len(l.AndOr)-1].Background = true
 		}
 		p.next()
-		if p.s.Token == token.ShellNewline || p.s.Token == token.Shell {
+		if p.s.Token == token.ShellNewline || p.s.Token == token.Shell || p.atShellFuncEnd() {
 			break
 		}
 		l.AndOr = append(l.AndOr, p.parseShellAndOr())
@@ -68,9 +74,13 @@ func (p *Parser) parseShellPipeline() *expr.ShellPipeline {
 		Bang: bang,
 		Cmd:  []*expr.ShellCmd{cmd},
 	}
-	for p.s.Token == token.ShellPipe {
+	for p.s.Token == token.ShellPipe || p.s.Token == token.ShellPipeAnd {
+		if p.s.Token == token.ShellPipeAnd && cmd != nil {
+			cmd.PipeStderr = true
+		}
 		p.next()
-		l.Cmd = append(l.Cmd, p.parseShellCmd())
+		cmd = p.parseShellCmd()
+		l.Cmd = append(l.Cmd, cmd)
 	}
 	return l
 }
@@ -85,7 +95,11 @@ func (p *Parser) parseShellCmd() (l *expr.ShellCmd) {
 		p.next()
 	} else {
 		simplecmd := p.parseShellSimpleCmd()
-		if simplecmd != nil {
+		if simplecmd != nil && p.s.Token == token.LeftParen && isShellFuncName(simplecmd) {
+			l = &expr.ShellCmd{
+				FuncDef: p.parseShellFuncDef(simplecmd.Args[0]),
+			}
+		} else if simplecmd != nil {
 			l = &expr.ShellCmd{
 				SimpleCmd: simplecmd,
 			}
@@ -94,6 +108,65 @@ func (p *Parser) parseShellCmd() (l *expr.ShellCmd) {
 	return l
 }
 
+// isShellFuncName reports whether cmd is a lone word, which
+// names a shell function if it is followed by "()".
+func isShellFuncName(cmd *expr.ShellSimpleCmd) bool {
+	return len(cmd.Args) == 1 && len(cmd.Assign) == 0 && len(cmd.Redirect) == 0
+}
+
+// parseShellFuncDef parses the rest of the shell function
+// definition name() { list; }, from the '('.
+func (p *Parser) parseShellFuncDef(name string) *expr.ShellFuncDef {
+	def := &expr.ShellFuncDef{
+		Name: name,
+		Body: &expr.ShellList{},
+	}
+	p.next()
+	p.expect(token.RightParen)
+	p.next()
+	for p.s.Token == token.ShellNewline {
+		p.next()
+	}
+	if !p.atShellWord("{") {
+		p.errorf("shell function %s: expected '{', found %q", name, p.s.Token)
+		return def
+	}
+	p.next()
+
+	restore := p.interactive
+	p.interactive = false
+	p.shellFuncs++
+	defer func() {
+		p.interactive = restore
+		p.shellFuncs--
+	}()
+	for !p.atShellFuncEnd() {
+		if p.s.Token == token.ShellNewline || p.s.Token == token.Semicolon {
+			p.next()
+			continue
+		}
+		l := p.parseShellList()
+		if l == nil {
+			p.errorf("shell function %s: expected '}', found %q", name, p.s.Token)
+			return def
+		}
+		def.Body.AndOr = append(def.Body.AndOr, l.AndOr...)
+	}
+	p.next()
+	return def
+}
+
+// atShellWord reports whether the current token is the shell word w.
+func (p *Parser) atShellWord(w string) bool {
+	return p.s.Token == token.ShellWord && p.s.Literal.(string) == w
+}
+
+// atShellFuncEnd reports whether the current token is the '}'
+// ending the body of a shell function.
+func (p *Parser) atShellFuncEnd() bool {
+	return p.shellFuncs > 0 && p.atShellWord("}")
+}
+
 func isAssignment(word string) (k, v string) {
 	for i, r := range word {
 		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
@@ -108,6 +181,9 @@ func isAssignment(word string) (k, v string) {
 
 func (p *Parser) parseShellSimpleCmd() (l *expr.ShellSimpleCmd) {
 	for {
+		if l == nil && p.atShellFuncEnd() {
+			return nil
+		}
 		w, r := p.maybeParseShellRedirect()
 		if r == nil {
 			if w == "" {
@@ -124,7 +200,13 @@ func (p *Parser) parseShellSimpleCmd() (l *expr.ShellSimpleCmd) {
 		}
 		if r != nil {
 			l.Redirect = append(l.Redirect, r)
+			if r.Token == token.TwoLess && shell.HeredocExpanded(r.Filename) {
+				for _, exp := range shell.HeredocExpansions(r.Heredoc) {
+					p.parseShellSubsts(l, exp)
+				}
+			}
 		} else {
+			p.parseShellSubsts(l, w)
 			if len(l.Args) == 0 {
 				if k, v := isAssignment(w); k != "" {
 					l.Assign = append(l.Assign, expr.ShellAssign{
@@ -140,6 +222,79 @@ func (p *Parser) parseShellSimpleCmd() (l *expr.ShellSimpleCmd) {
 	return l
 }
 
+// parseShellSubsts parses the command substitutions and arithmetic
+// expansions of the word w into cmd.
+func (p *Parser) parseShellSubsts(cmd *expr.ShellSimpleCmd, w string) {
+	for _, src := range shell.CmdSubsts(w) {
+		cmd.CmdSubst = append(cmd.CmdSubst, p.parseShellCmdSubst(src))
+	}
+	for _, src := range shell.ArithExpansions(w) {
+		cmd.Arith = append(cmd.Arith, p.parseShellArith(src))
+	}
+}
+
+// parseShellCmdSubst parses the command of the command
+// substitution src, which has the form "$(cmd)".
+func (p *Parser) parseShellCmdSubst(src string) *expr.ShellCmdSubst {
+	sub := New(p.filename)
+	defer sub.Close()
+	res := sub.ParseLine([]byte("$$ " + src[2:len(src)-1] + " $$"))
+	for _, err := range res.Errs {
+		p.errorf("command substitution %s: %s", src, err.Msg)
+	}
+	l := &expr.ShellCmdSubst{
+		Src: src,
+		Cmd: &expr.ShellList{},
+	}
+	for _, cmd := range res.Cmds {
+		l.Cmd.AndOr = append(l.Cmd.AndOr, cmd.AndOr...)
+	}
+	return l
+}
+
+// arithParam matches the parameters $name and ${name}, the
+// positional parameters $1 and ${10}, and the special parameters
+// $# and $? in an arithmetic expression.
+var arithParam = regexp.MustCompile(`\$([A-Za-z_]\w*|[0-9#?])|\$\{([A-Za-z_]\w*|[0-9]+|[#?])\}`)
+
+// arithParamPrefix begins the placeholder identifiers of the
+// parameters of an arithmetic expression that are not identifiers.
+const arithParamPrefix = "ng_arith_param_"
+
+// parseShellArith parses the expression of the arithmetic
+// expansion src, which has the form "$((expr))".
+// Parameters are parsed as identifiers, so $x and x are the same.
+// A parameter such as $1 becomes an identifier named "1".
+func (p *Parser) parseShellArith(src string) *expr.ShellArith {
+	l := &expr.ShellArith{Src: src}
+	var params []string
+	text := arithParam.ReplaceAllStringFunc(src[3:len(src)-2], func(param string) string {
+		name := strings.Trim(param, "${}")
+		if c := name[0]; c == '_' || unicode.IsLetter(rune(c)) {
+			return name
+		}
+		params = append(params, name)
+		return arithParamPrefix + strconv.Itoa(len(params)-1)
+	})
+	s, err := ParseStmt([]byte(text))
+	if err == nil {
+		if s, ok := s.(*stmt.Simple); ok {
+			l.Expr = s.Expr
+			syntax.Walk(l.Expr, func(c *syntax.Cursor) bool {
+				if ident, ok := c.Node.(*expr.Ident); ok && strings.HasPrefix(ident.Name, arithParamPrefix) {
+					i, _ := strconv.Atoi(ident.Name[len(arithParamPrefix):])
+					ident.Name = params[i]
+				}
+				return true
+			}, nil)
+			return l
+		}
+		err = fmt.Errorf("%q is not an expression", text)
+	}
+	p.errorf("arithmetic expansion %s: %v", src, err)
+	return l
+}
+
 func (p *Parser) maybeParseShellRedirect() (string, *expr.ShellRedirect) {
 	//fmt.Printf("maybeParseShellRedirect p.s.Token=%s\n", p.s.Token)
 	lit := ""
@@ -154,7 +309,7 @@ func (p *Parser) maybeParseShellRedirect() (string, *expr.ShellRedirect) {
 		number = &i
 	}
 	switch p.s.Token {
-	case token.Less, token.Greater, token.GreaterAnd, token.AndGreater, token.TwoGreater: // TODO: <&
+	case token.Less, token.Greater, token.GreaterAnd, token.AndGreater, token.AndTwoGreater, token.TwoGreater, token.TwoLess, token.ThreeLess: // TODO: <&
 	default:
 		return lit, nil
 	}
@@ -162,6 +317,9 @@ func (p *Parser) maybeParseShellRedirect() (string, *expr.ShellRedirect) {
 		Number: number,
 		Token:  p.s.Token,
 	}
+	if l.Token == token.TwoLess {
+		l.Heredoc = p.s.Literal.(string)
+	}
 	p.next()
 	if p.expect(token.ShellWord) {
 		l.Filename = p.s.Literal.(string)
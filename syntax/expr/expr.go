@@ -18,14 +18,20 @@ type Expr interface {
 
 type Binary struct {
 	Position src.Pos
-	Op       token.Token // Add, Sub, Mul, Div, Rem, Pow, And, Or, Equal, NotEqual, Less, Greater
+	Op       token.Token // Add, Sub, Mul, Div, Rem, Xor, And, Or, Equal, NotEqual, Less, Greater
 	Left     Expr
 	Right    Expr
 }
 
 type Unary struct {
 	Position src.Pos
-	Op       token.Token // Not, Mul (deref), Ref, LeftParen, Range
+	Op       token.Token // Not, Mul (deref), Ref, Range, Xor (complement)
+	Expr     Expr
+}
+
+// Paren is a parenthesized expression, (Expr).
+type Paren struct {
+	Position src.Pos
 	Expr     Expr
 }
 
@@ -53,12 +59,31 @@ type Index struct {
 	Indicies []Expr
 }
 
+// TableIndex selects columns and rows from a table,
+// x["C1"|"C2", Rows].
+type TableIndex struct {
+	Position src.Pos
+	Expr     Expr
+	ColNames []string
+	Rows     Range
+}
+
 type TypeAssert struct {
 	Position src.Pos
 	Left     Expr
 	Type     tipe.Type // asserted type; nil means type switch X.(type)
 }
 
+// IfExpr is an if expression, if Cond { Then } else { Else }.
+// It evaluates to Then if Cond is true and to Else otherwise.
+// Else is an *IfExpr for an else if chain.
+type IfExpr struct {
+	Position src.Pos
+	Cond     Expr
+	Then     Expr
+	Else     Expr
+}
+
 type BasicLiteral struct {
 	Position src.Pos
 	Value    interface{} // string, *big.Int, *big.Float
@@ -131,11 +156,15 @@ type Call struct {
 	ElideError bool
 }
 
+// Range is a range of indices. It is either a single index, Exact,
+// or the indices from Start up to End, written Start:End or Start..End.
+// If Inclusive is set, written Start..=End, the range includes End.
 type Range struct {
-	Position src.Pos
-	Start    Expr
-	End      Expr
-	Exact    Expr
+	Position  src.Pos
+	Start     Expr
+	End       Expr
+	Exact     Expr
+	Inclusive bool
 }
 
 type ShellList struct {
@@ -157,9 +186,11 @@ type ShellPipeline struct {
 }
 
 type ShellCmd struct {
-	Position  src.Pos
-	SimpleCmd *ShellSimpleCmd // or:
-	Subshell  *ShellList
+	Position   src.Pos
+	SimpleCmd  *ShellSimpleCmd // or:
+	Subshell   *ShellList      // or:
+	FuncDef    *ShellFuncDef
+	PipeStderr bool // stderr is piped to the next command with stdout (|&)
 }
 
 type ShellSimpleCmd struct {
@@ -167,13 +198,16 @@ type ShellSimpleCmd struct {
 	Redirect []*ShellRedirect
 	Assign   []ShellAssign
 	Args     []string
+	CmdSubst []*ShellCmdSubst // command substitutions in Args and Assign
+	Arith    []*ShellArith    // arithmetic expansions in Args and Assign
 }
 
 type ShellRedirect struct {
 	Position src.Pos
 	Number   *int
-	Token    token.Token // '<', '<&', '>', '>&', '>>'
-	Filename string
+	Token    token.Token // '<', '<&', '>', '>&', '>>', '<<', '<<<'
+	Filename string      // for '<<', the here-document delimiter; for '<<<', the word
+	Heredoc  string      // for '<<', the here-document body
 }
 
 type ShellAssign struct {
@@ -182,6 +216,27 @@ type ShellAssign struct {
 	Value    string
 }
 
+// ShellFuncDef is a shell function definition, name() { body; }.
+type ShellFuncDef struct {
+	Position src.Pos
+	Name     string
+	Body     *ShellList
+}
+
+// ShellCmdSubst is a command substitution, $(cmd), in a shell word.
+type ShellCmdSubst struct {
+	Position src.Pos
+	Src      string // text of the substitution, "$(cmd)"
+	Cmd      *ShellList
+}
+
+// ShellArith is an arithmetic expansion, $((expr)), in a shell word.
+type ShellArith struct {
+	Position src.Pos
+	Src      string // text of the expansion, "$((expr))"
+	Expr     Expr
+}
+
 type Shell struct {
 	Position   src.Pos
 	Cmds       []*ShellList
@@ -201,6 +256,7 @@ type Shell struct {
 
 func (e *Binary) expr()         {}
 func (e *Unary) expr()          {}
+func (e *Paren) expr()          {}
 func (e *Bad) expr()            {}
 func (e *Selector) expr()       {}
 func (e *Slice) expr()          {}
@@ -215,7 +271,10 @@ func (e *Type) expr()           {}
 func (e *Ident) expr()          {}
 func (e *Call) expr()           {}
 func (e *Index) expr()          {}
+func (e *Range) expr()          {}
+func (e *TableIndex) expr()     {}
 func (e *TypeAssert) expr()     {}
+func (e *IfExpr) expr()         {}
 func (e *ShellList) expr()      {}
 func (e *ShellAndOr) expr()     {}
 func (e *ShellPipeline) expr()  {}
@@ -223,10 +282,14 @@ func (e *ShellSimpleCmd) expr() {}
 func (e *ShellRedirect) expr()  {}
 func (e *ShellAssign) expr()    {}
 func (e *ShellCmd) expr()       {}
+func (e *ShellFuncDef) expr()   {}
+func (e *ShellCmdSubst) expr()  {}
+func (e *ShellArith) expr()     {}
 func (e *Shell) expr()          {}
 
 func (e *Binary) Pos() src.Pos         { return e.Position }
 func (e *Unary) Pos() src.Pos          { return e.Position }
+func (e *Paren) Pos() src.Pos          { return e.Position }
 func (e *Bad) Pos() src.Pos            { return e.Position }
 func (e *Selector) Pos() src.Pos       { return e.Position }
 func (e *Slice) Pos() src.Pos          { return e.Position }
@@ -242,7 +305,9 @@ func (e *Ident) Pos() src.Pos          { return e.Position }
 func (e *Call) Pos() src.Pos           { return e.Position }
 func (e *Range) Pos() src.Pos          { return e.Position }
 func (e *Index) Pos() src.Pos          { return e.Position }
+func (e *TableIndex) Pos() src.Pos     { return e.Position }
 func (e *TypeAssert) Pos() src.Pos     { return e.Position }
+func (e *IfExpr) Pos() src.Pos         { return e.Position }
 func (e *ShellList) Pos() src.Pos      { return e.Position }
 func (e *ShellAndOr) Pos() src.Pos     { return e.Position }
 func (e *ShellPipeline) Pos() src.Pos  { return e.Position }
@@ -250,4 +315,7 @@ func (e *ShellSimpleCmd) Pos() src.Pos { return e.Position }
 func (e *ShellRedirect) Pos() src.Pos  { return e.Position }
 func (e ShellAssign) Pos() src.Pos     { return e.Position }
 func (e *ShellCmd) Pos() src.Pos       { return e.Position }
+func (e *ShellFuncDef) Pos() src.Pos   { return e.Position }
+func (e *ShellCmdSubst) Pos() src.Pos  { return e.Position }
+func (e *ShellArith) Pos() src.Pos     { return e.Position }
 func (e *Shell) Pos() src.Pos          { return e.Position }
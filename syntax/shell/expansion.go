@@ -5,10 +5,13 @@
 package shell
 
 import (
+	"bytes"
 	"fmt"
+	"io/fs"
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -18,6 +21,111 @@ type Params interface {
 	Get(name string) string
 }
 
+// A Lookuper is a Params that can tell an unset parameter
+// from a parameter set to the empty string.
+type Lookuper interface {
+	Params
+	Lookup(name string) (value string, ok bool)
+}
+
+// A Setter is a Params that can assign parameters,
+// as required by ${parameter:=word}.
+type Setter interface {
+	Params
+	Set(name, value string)
+}
+
+// A CmdSubster is a Params that can run the command of a
+// command substitution, $(cmd), and return its output.
+type CmdSubster interface {
+	Params
+	CmdSubst(src string) (string, error)
+}
+
+// An ArithExpander is a Params that can evaluate the expression
+// of an arithmetic expansion, $((expr)), and return its value.
+type ArithExpander interface {
+	Params
+	ArithExpand(src string) (string, error)
+}
+
+// NoUnset returns Params under which the expansion of an unset
+// parameter is an error, as with the shell option set -u.
+func NoUnset(params Lookuper) Params {
+	return nounset{params}
+}
+
+type nounset struct {
+	Lookuper
+}
+
+// getParam returns the value of the named parameter.
+func getParam(params Params, name string) (string, error) {
+	p, ok := params.(nounset)
+	if !ok {
+		return params.Get(name), nil
+	}
+	val, ok := p.Lookup(name)
+	if !ok && !isSpecialParam(name) {
+		return "", fmt.Errorf("unbound variable: %s", name)
+	}
+	return val, nil
+}
+
+// setParam assigns val to the named parameter.
+func setParam(params Params, name, val string) error {
+	if p, ok := params.(nounset); ok {
+		params = p.Lookuper
+	}
+	s, ok := params.(Setter)
+	if !ok || isSpecialParam(name) {
+		return fmt.Errorf("%s: cannot assign in this way", name)
+	}
+	s.Set(name, val)
+	return nil
+}
+
+// cmdSubst returns the output of the command substitution src.
+// Params that cannot run commands substitute nothing.
+func cmdSubst(params Params, src string) (string, error) {
+	if p, ok := params.(nounset); ok {
+		params = p.Lookuper
+	}
+	s, ok := params.(CmdSubster)
+	if !ok {
+		return "", nil
+	}
+	return s.CmdSubst(src)
+}
+
+// arithExpand returns the value of the arithmetic expansion src.
+// Params that cannot evaluate expressions substitute nothing.
+func arithExpand(params Params, src string) (string, error) {
+	if p, ok := params.(nounset); ok {
+		params = p.Lookuper
+	}
+	a, ok := params.(ArithExpander)
+	if !ok {
+		return "", nil
+	}
+	return a.ArithExpand(src)
+}
+
+// isSpecialParam reports whether name is a positional parameter,
+// such as $0 or $1, their number $#, or the exit status $?, which
+// set -u does not apply to.
+func isSpecialParam(name string) bool {
+	if name == "?" || name == "#" {
+		return true
+	}
+	for _, r := range name {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
 type paramCollector map[string]bool
 
 func (p paramCollector) Get(name string) string {
@@ -50,7 +158,7 @@ func expansion(argv1 []string, params Params, expanders []expander) ([]string, e
 		for _, arg := range argv1 {
 			if len(arg) == 0 {
 				continue
-			} else if arg[0] == '\'' || arg[0] == '"' {
+			} else if isQuoted(arg) {
 				argv2 = append(argv2, arg)
 				continue
 			}
@@ -64,30 +172,111 @@ func expansion(argv1 []string, params Params, expanders []expander) ([]string, e
 	}
 
 	for i, arg := range argv1 {
-		if len(arg) == 0 {
-			continue
-		}
-		s, e := arg[0], arg[len(arg)-1]
-		if s == '\'' && e == '\'' {
-			argv1[i] = arg[1 : len(arg)-1]
-		} else if s == '"' && e == '"' {
-			v, err := ExpandParams(arg, params)
-			if err != nil {
-				return nil, err
-			}
-			v = v[1 : len(v)-1]
-			v = quoteUnescaper.Replace(v)
-			argv1[i] = v
-		} else {
-			argv1[i] = unquoteUnescape.ReplaceAllString(arg, "$1")
+		argv1[i], err = unquote(arg, params)
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	return argv1, nil
 }
 
+// ExpandAssign expands the value of a shell assignment, VAR=value.
+//
+// Following bash, the value is not brace expanded, word split,
+// or path expanded, so x=$y assigns all of $y to x.
+func ExpandAssign(value string, params Params) (string, error) {
+	if len(value) == 0 {
+		return "", nil
+	}
+	if value[0] != '\'' && value[0] != '"' {
+		var err error
+		value, err = ExpandTilde(value)
+		if err != nil {
+			return "", err
+		}
+		value, err = ExpandParams(value, params)
+		if err != nil {
+			return "", err
+		}
+	}
+	return unquote(value, params)
+}
+
+// unquote removes the quoting from an argument.
+// Parameters inside double quotes are expanded.
+func unquote(arg string, params Params) (string, error) {
+	switch {
+	case !isQuoted(arg):
+		buf := new(bytes.Buffer)
+		walkQuoted(arg, func(c byte, quoted bool) {
+			buf.WriteByte(c)
+		})
+		return buf.String(), nil
+	case arg[0] == '\'':
+		return arg[1 : len(arg)-1], nil
+	}
+	v, err := ExpandParams(arg, params)
+	if err != nil {
+		return "", err
+	}
+	v = v[1 : len(v)-1]
+	return quoteUnescaper.Replace(v), nil
+}
+
+// isQuoted reports whether all of arg is enclosed in a single
+// pair of quotes, as in "a b" but not "a"b.
+func isQuoted(arg string) bool {
+	if len(arg) < 2 || (arg[0] != '\'' && arg[0] != '"') {
+		return false
+	}
+	q := arg[0]
+	for i := 1; i < len(arg); i++ {
+		switch arg[i] {
+		case '\\':
+			if q == '"' {
+				i++
+			}
+		case q:
+			return i == len(arg)-1
+		}
+	}
+	return false
+}
+
 var quoteUnescaper = strings.NewReplacer(`\"`, `"`, "\\`", "`")
-var unquoteUnescape = regexp.MustCompile(`\\(.)`)
+
+// walkQuoted calls f for each byte of the word arg, less its
+// quoting. The quoted argument reports whether the byte is
+// enclosed in '' or "", or escaped by a '\'.
+func walkQuoted(arg string, f func(c byte, quoted bool)) {
+	inBlock := byte(0)
+	for i := 0; i < len(arg); i++ {
+		c := arg[i]
+		switch {
+		case inBlock != 0:
+			if c == inBlock {
+				inBlock = 0
+				continue
+			}
+			if inBlock == '"' && c == '\\' && i+1 < len(arg) && (arg[i+1] == '"' || arg[i+1] == '`') {
+				i++
+				c = arg[i]
+			}
+		case c == '\'' || c == '"':
+			inBlock = c
+			continue
+		case c == '\\' && i+1 < len(arg):
+			i++
+			f(arg[i], true)
+			continue
+		default:
+			f(c, false)
+			continue
+		}
+		f(c, true)
+	}
+}
 
 var expanders = []expander{
 	braceExpand,
@@ -187,27 +376,242 @@ func tildeExpand(src []string, arg string, params Params) (res []string, err err
 
 // expandBraceParam expands the ${braced param} at the beginning of arg.
 func expandBraceParam(arg string, params Params) (string, error) {
-	var r rune
-	var i2 int
-	for i2, r = range arg[1:] {
-		if r == '}' {
-			i2--
-			break
+	// Find the matching '}', the word of ${parameter:-word}
+	// may itself contain braced parameters.
+	end := -1
+	depth := 0
+	for i, r := range arg[1:] {
+		if r == '{' {
+			depth++
+		} else if r == '}' {
+			depth--
+			if depth == 0 {
+				end = 1 + i
+				break
+			}
 		}
 	}
-	if i2 == -1 {
+	if end == -1 {
 		return "", fmt.Errorf("invalid braced parameter expansion: %q", arg)
 	}
-	// TODO: ${parameter:-word}
-	// TODO: ${parameter/pattern/string}
 	// TODO: ${parameter[index]}
 	// TODO: ${parameter[offset:length]}
-	end := 1 + i2 + 1
 	name := arg[2:end]
-	val := params.Get(name)
+	if strings.HasPrefix(name, "#") {
+		// ${#parameter}, the length of parameter in runes (not bytes).
+		// An unset parameter, like ${#} itself, has length 0.
+		n := 0
+		if name != "#" {
+			n = utf8.RuneCountInString(params.Get(name[1:]))
+		}
+		return strconv.Itoa(n) + arg[end+1:], nil
+	}
+	if i := strings.IndexAny(name, ":/#%"); i >= 0 && name[i] != ':' {
+		var val string
+		var err error
+		if name[i] == '/' {
+			val, err = expandSubst(name[:i], name[i+1:], params)
+		} else {
+			val, err = expandTrim(name[:i], name[i], name[i+1:], params)
+		}
+		if err != nil {
+			return "", err
+		}
+		return val + arg[end+1:], nil
+	}
+	if i := strings.IndexByte(name, ':'); i >= 0 && i+1 < len(name) {
+		name, op, word := name[:i], name[i+1], name[i+2:]
+		val := params.Get(name) // an unset parameter is expected
+		var err error
+		if _, collecting := params.(paramCollector); collecting {
+			// Collect the parameters of word, whatever the value.
+			_, err = ExpandParams(word, params)
+			return arg[end+1:], err
+		}
+		switch op {
+		case '-':
+			// ${parameter:-word}, word if parameter is unset or null.
+			if val == "" {
+				val, err = ExpandParams(word, params)
+			}
+		case '=':
+			// ${parameter:=word}, as :- and assign word to parameter.
+			if val == "" {
+				if val, err = ExpandParams(word, params); err == nil {
+					err = setParam(params, name, val)
+				}
+			}
+		case '?':
+			// ${parameter:?word}, error with word if parameter is unset or null.
+			if val == "" {
+				if word, err = ExpandParams(word, params); err == nil {
+					if word == "" {
+						word = "parameter null or not set"
+					}
+					err = fmt.Errorf("%s: %s", name, word)
+				}
+			}
+		case '+':
+			// ${parameter:+word}, word unless parameter is unset or null.
+			if val != "" {
+				val, err = ExpandParams(word, params)
+			}
+		default:
+			return "", fmt.Errorf("invalid braced parameter expansion: %q", arg[:end+1])
+		}
+		if err != nil {
+			return "", err
+		}
+		return val + arg[end+1:], nil
+	}
+	val, err := getParam(params, name)
+	if err != nil {
+		return "", err
+	}
 	return val + arg[end+1:], nil
 }
 
+// expandSubst expands ${name/subst}, where subst is one of
+//
+//	pattern/string   replace the first match of pattern with string
+//	/pattern/string  replace all matches
+//	#pattern/string  replace a match at the beginning of the value
+//	%pattern/string  replace a match at the end of the value
+//
+// The pattern is a glob, as in path expansion, and matches as much
+// of the value as it can. A '/' in the pattern is escaped as \/.
+// If /string is omitted, matches are deleted.
+func expandSubst(name, subst string, params Params) (string, error) {
+	op := byte(0)
+	if len(subst) > 0 && (subst[0] == '/' || subst[0] == '#' || subst[0] == '%') {
+		op, subst = subst[0], subst[1:]
+	}
+	pattern, repl := subst, ""
+	for i := 0; i < len(subst); i++ {
+		if subst[i] == '\\' {
+			i++
+		} else if subst[i] == '/' {
+			pattern, repl = subst[:i], subst[i+1:]
+			break
+		}
+	}
+	pattern = strings.Replace(pattern, `\/`, "/", -1)
+
+	val, err := getParam(params, name)
+	if err != nil {
+		return "", err
+	}
+	if pattern, err = ExpandParams(pattern, params); err != nil {
+		return "", err
+	}
+	if repl, err = ExpandParams(repl, params); err != nil {
+		return "", err
+	}
+	if pattern == "" {
+		return val, nil
+	}
+
+	expr := globRegexp(pattern)
+	switch op {
+	case '#':
+		expr = "^(?:" + expr + ")"
+	case '%':
+		expr = "(?:" + expr + ")$"
+	}
+	re, err := regexp.Compile("(?s)" + expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern in ${%s/%s}: %q", name, subst, pattern)
+	}
+	if op == '/' {
+		return re.ReplaceAllLiteralString(val, repl), nil
+	}
+	loc := re.FindStringIndex(val)
+	if loc == nil {
+		return val, nil
+	}
+	return val[:loc[0]] + repl + val[loc[1]:], nil
+}
+
+// expandTrim implements ${parameter#word} and ${parameter%word},
+// removing the shortest prefix (#) or suffix (%) of the parameter
+// value matching the glob pattern word. The doubled forms ## and %%
+// remove the longest match.
+func expandTrim(name string, op byte, pattern string, params Params) (string, error) {
+	longest := false
+	if len(pattern) > 0 && pattern[0] == op {
+		longest, pattern = true, pattern[1:]
+	}
+	val, err := getParam(params, name)
+	if err != nil {
+		return "", err
+	}
+	if pattern, err = ExpandParams(pattern, params); err != nil {
+		return "", err
+	}
+	if pattern == "" {
+		return val, nil
+	}
+	re, err := regexp.Compile("(?s)^(?:" + globRegexp(pattern) + ")$")
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern in ${%s%c%s}: %q", name, op, pattern, pattern)
+	}
+
+	// Candidate cut points, on rune boundaries, shortest match first.
+	var cuts []int
+	for i := range val {
+		cuts = append(cuts, i)
+	}
+	cuts = append(cuts, len(val))
+	if (op == '%') != longest {
+		for i, j := 0, len(cuts)-1; i < j; i, j = i+1, j-1 {
+			cuts[i], cuts[j] = cuts[j], cuts[i]
+		}
+	}
+	for _, cut := range cuts {
+		if op == '#' && re.MatchString(val[:cut]) {
+			return val[cut:], nil
+		}
+		if op == '%' && re.MatchString(val[cut:]) {
+			return val[:cut], nil
+		}
+	}
+	return val, nil
+}
+
+// globRegexp returns a regular expression matching the same
+// strings as the glob pattern.
+func globRegexp(pattern string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			j := strings.IndexByte(pattern[i+1:], ']')
+			if j == -1 {
+				buf.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += 1 + j
+		default:
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	return buf.String()
+}
+
 // ExpandParams expands $ variables.
 func ExpandParams(arg string, params Params) (string, error) {
 	skip := 0
@@ -222,13 +626,39 @@ func ExpandParams(arg string, params Params) (string, error) {
 			break
 		}
 		var name string
-		if arg[i1+1] == '{' {
+		if arg[i1+1] == '(' {
+			n := cmdSubstLen(arg[i1:])
+			if n == -1 {
+				return "", fmt.Errorf("command substitution missing terminating ')': %q", arg[i1:])
+			}
+			expand := cmdSubst
+			if isArith(arg[i1 : i1+n]) {
+				expand = arithExpand
+			}
+			out, err := expand(params, arg[i1:i1+n])
+			if err != nil {
+				return "", err
+			}
+			arg = arg[:i1] + out + arg[i1+n:]
+			skip = i1 + len(out)
+			continue
+		} else if arg[i1+1] == '{' {
 			res, err := expandBraceParam(arg[i1:], params)
 			if err != nil {
 				return "", err
 			}
 			arg = arg[:i1] + res
 			continue
+		} else if arg[i1+1] == '?' || arg[i1+1] == '#' {
+			// $?, the exit status of the most recent pipeline,
+			// or $#, the number of positional parameters.
+			val, err := getParam(params, arg[i1+1:i1+2])
+			if err != nil {
+				return "", err
+			}
+			arg = arg[:i1] + val + arg[i1+2:]
+			skip = i1 + len(val)
+			continue
 		} else if r, _ := utf8.DecodeRuneInString(arg[i1+1:]); !unicode.IsLetter(r) && !unicode.IsDigit(r) {
 			skip = i1 + 1
 			continue
@@ -245,48 +675,382 @@ func ExpandParams(arg string, params Params) (string, error) {
 		}
 		end := i1 + 1 + i2 + 1
 		name = arg[i1+1 : end]
-		val := params.Get(name)
+		val, err := getParam(params, name)
+		if err != nil {
+			return "", err
+		}
 		arg = arg[:i1] + val + arg[end:]
 	}
 	return arg, nil
 }
 
+// CmdSubsts returns the text of each command substitution,
+// $(cmd), in word.
+func CmdSubsts(word string) []string {
+	return substs(word, false)
+}
+
+// ArithExpansions returns the text of each arithmetic expansion,
+// $((expr)), in word.
+func ArithExpansions(word string) []string {
+	return substs(word, true)
+}
+
+// substs returns the text of each command substitution in word,
+// or if arith is set, each arithmetic expansion.
+func substs(word string, arith bool) (substs []string) {
+	skip := 0
+	for {
+		i := indexParam(word[skip:])
+		if i == -1 {
+			return substs
+		}
+		i += skip
+		n := cmdSubstLen(word[i:])
+		if n == -1 {
+			skip = i + 1
+			continue
+		}
+		if isArith(word[i:i+n]) == arith {
+			substs = append(substs, word[i:i+n])
+		}
+		skip = i + n
+	}
+}
+
+// isArith reports whether the substitution src, "$(...)",
+// is an arithmetic expansion, "$((expr))".
+func isArith(src string) bool {
+	return strings.HasPrefix(src, "$((") && cmdSubstLen("$"+src[2:]) == len(src)-2
+}
+
+// cmdSubstLen returns the length of the command substitution
+// at the start of s, or -1 if there is none.
+func cmdSubstLen(s string) int {
+	if !strings.HasPrefix(s, "$(") {
+		return -1
+	}
+	depth := 0
+	prevSlash := false
+	inBlock := rune(-1)
+	for i, v := range s[1:] {
+		if inBlock != -1 {
+			if v == inBlock && !(inBlock == '"' && prevSlash) {
+				inBlock = -1
+			}
+			prevSlash = v == '\\' && !prevSlash
+			continue
+		}
+		if !prevSlash {
+			switch v {
+			case '\'', '"':
+				inBlock = v
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					return 1 + i + 1
+				}
+			}
+		}
+		prevSlash = v == '\\' && !prevSlash
+	}
+	return -1
+}
+
+// HeredocExpanded reports whether the body of a here-document
+// with the delimiter word delim is expanded. Following bash, it is
+// unless some part of delim is quoted, as in <<'EOF'.
+func HeredocExpanded(delim string) bool {
+	return !strings.ContainsAny(delim, `"'\`)
+}
+
+// HeredocExpansions returns the text of each parameter expansion,
+// command substitution and arithmetic expansion in the body of a
+// here-document.
+func HeredocExpansions(body string) []string {
+	var exps []string
+	walkHeredoc(body, func(c byte) {}, func(exp string) error {
+		exps = append(exps, exp)
+		return nil
+	})
+	return exps
+}
+
+// ExpandHeredoc expands the body of a here-document.
+//
+// Unlike a word, the body is not word split or path expanded and
+// quotes are not special. A backslash only quotes \, $ and `, or
+// removes a newline.
+func ExpandHeredoc(body string, params Params) (string, error) {
+	buf := new(bytes.Buffer)
+	err := walkHeredoc(body, func(c byte) { buf.WriteByte(c) }, func(exp string) error {
+		val, err := ExpandParams(exp, params)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(val)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// HeredocDelim returns the delimiter word of a here-document, as
+// written after << or <<-, with its quotes removed.
+func HeredocDelim(word string) string {
+	return heredocUnquoter.Replace(word)
+}
+
+var heredocUnquoter = strings.NewReplacer(`"`, "", `'`, "", `\`, "")
+
+// walkHeredoc calls lit for each literal byte of the body of a
+// here-document, less any quoting backslash, and exp with the text
+// of each expansion.
+func walkHeredoc(body string, lit func(c byte), exp func(exp string) error) error {
+	for i := 0; i < len(body); {
+		switch c := body[i]; {
+		case c == '\\' && i+1 < len(body) && strings.IndexByte("\\$`\n", body[i+1]) >= 0:
+			if body[i+1] != '\n' {
+				lit(body[i+1])
+			}
+			i += 2
+			continue
+		case c == '$':
+			if n := expansionLen(body[i:]); n > 0 {
+				if err := exp(body[i : i+n]); err != nil {
+					return err
+				}
+				i += n
+				continue
+			}
+		}
+		lit(body[i])
+		i++
+	}
+	return nil
+}
+
+// expansionLen returns the length of the parameter expansion,
+// command substitution or arithmetic expansion at the start of s,
+// or 0 if there is none.
+func expansionLen(s string) int {
+	if len(s) < 2 || s[0] != '$' {
+		return 0
+	}
+	switch s[1] {
+	case '(':
+		if n := cmdSubstLen(s); n > 0 {
+			return n
+		}
+		return 0
+	case '{':
+		return strings.IndexByte(s, '}') + 1
+	case '?':
+		return 2
+	}
+	n := 1
+	for _, r := range s[1:] {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		n += utf8.RuneLen(r)
+	}
+	if n == 1 {
+		return 0
+	}
+	return n
+}
+
 // param expansion ($x, $PATH, ${x}, long tail of questionable sh features)
+//
+// The result of expanding an unquoted argument is split into fields,
+// so with x="a b", $x is two arguments.
 func paramExpand(src []string, arg string, params Params) ([]string, error) {
 	expanded, err := ExpandParams(arg, params)
 	if err != nil {
 		return nil, err
 	}
-	return append(src, expanded), nil
+	if expanded == arg {
+		return append(src, expanded), nil
+	}
+	return append(src, splitFields(expanded)...), nil
+}
+
+// QuoteArg quotes arg so it is read back by the shell as a single
+// word with the same value. Unless it has no special characters,
+// arg is enclosed in single quotes, with each single quote in it
+// written as '\''.
+func QuoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`|&;<>()*?[]{}~") {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+// SplitWords splits s into words around unquoted whitespace,
+// keeping the quoting of each word for Expansion.
+func SplitWords(s string) []string {
+	return splitFields(s)
+}
+
+// splitFields splits s around unquoted whitespace.
+func splitFields(s string) (fields []string) {
+	for {
+		s = strings.TrimLeft(s, " \t\n")
+		if s == "" {
+			return fields
+		}
+		i := indexUnquotedFunc(s, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\n'
+		})
+		if i == -1 {
+			return append(fields, s)
+		}
+		fields = append(fields, s[:i])
+		s = s[i:]
+	}
 }
 
 // paths expansion (*, ?, [)
+//
+// As before ** was supported, a pattern that matches no paths
+// expands to nothing rather than to itself.
 func pathsExpand(src []string, arg string, params Params) (res []string, err error) {
 	res = src
+	// Quoted metacharacters, as in ab"*".c, are escaped in
+	// the pattern so they only match themselves.
 	isGlob := false
-	for i := 0; i < len(arg); i++ {
-		switch arg[i] {
-		case '\\':
-			i++
+	unclosed := false // an unquoted [ with no unquoted ] after it
+	pattern := new(bytes.Buffer)
+	walkQuoted(arg, func(c byte, quoted bool) {
+		switch c {
+		case ']':
+			if !quoted {
+				unclosed = false
+			}
 		case '*', '?', '[':
-			isGlob = true
+			if !quoted {
+				isGlob = true
+				if c == '[' {
+					unclosed = true
+				}
+				break
+			}
+			fallthrough
+		case '\\':
+			pattern.WriteByte('\\')
 		}
-	}
+		pattern.WriteByte(c)
+	})
 	if !isGlob {
 		return append(res, arg), nil
 	}
-	// TODO to support interior quoting (like ab"*".c) this will need a rewrite.
-	matches, err := filepath.Glob(arg)
-	if err != nil {
+	matches, err := globStar(pattern.String())
+	if err == filepath.ErrBadPattern && unclosed {
+		// Not a pattern, such as the test builtin [.
+		return append(res, arg), nil
+	} else if err != nil {
 		return nil, err
 	}
-	return append(res, matches...), nil
+	for _, m := range matches {
+		// Quote the match so the final unquoting leaves it as is.
+		res = append(res, globMatchQuoter.Replace(m))
+	}
+	return res, nil
+}
+
+var globMatchQuoter = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `"`, `\"`)
+
+// globStar is filepath.Glob extended with the path segment "**",
+// which matches any number of directories, so **/*.go matches the
+// .go files at any depth. Following bash, ** matches neither hidden
+// files nor hidden directories. Matches are in lexical order of
+// directory, and a pattern that matches nothing yields no matches.
+func globStar(pattern string) ([]string, error) {
+	const sep = string(filepath.Separator)
+	segs := strings.Split(pattern, sep)
+	i := 0
+	for i < len(segs) && segs[i] != "**" {
+		i++
+	}
+	if i == len(segs) {
+		return filepath.Glob(pattern)
+	}
+	rest := strings.Join(segs[i+1:], sep)
+	roots := []string{"."}
+	if i > 0 {
+		root := strings.Join(segs[:i], sep)
+		if root == "" {
+			root = sep
+		}
+		var err error
+		roots, err = filepath.Glob(root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var matches []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			matches = append(matches, path)
+		}
+	}
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // skip unreadable directories
+			}
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if rest == "" {
+				// A trailing ** matches everything below root.
+				if path != root {
+					add(path)
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			m, err := globStar(filepath.Join(globEscaper.Replace(path), rest))
+			if err != nil {
+				return err
+			}
+			for _, path := range m {
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
 }
 
+var globEscaper = strings.NewReplacer(`*`, `\*`, `?`, `\?`, `[`, `\[`, `\`, `\\`)
+
 // indexUnquoted returns the index of the first unquoted Unicode code
 // point r, or -1. A code point r is quoted if it is directly preceded
 // by a '\' or enclosed in "" or ''.
 func indexUnquoted(s string, r rune) int {
+	return indexUnquotedFunc(s, func(v rune) bool { return v == r })
+}
+
+// indexUnquotedFunc returns the index of the first unquoted Unicode
+// code point satisfying f, or -1.
+func indexUnquotedFunc(s string, f func(rune) bool) int {
 	prevSlash := false
 	inBlock := rune(-1)
 	for i, v := range s {
@@ -298,10 +1062,10 @@ func indexUnquoted(s string, r rune) int {
 		}
 
 		if !prevSlash {
-			switch v {
-			case r:
+			switch {
+			case f(v):
 				return i
-			case '\'', '"':
+			case v == '\'' || v == '"':
 				inBlock = v
 			}
 		}
@@ -0,0 +1,112 @@
+// Copyright 2017 The Neugram Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shell
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var quoteArgTests = []struct {
+	arg, want string
+}{
+	{"ls", "ls"},
+	{"-ldflags=-w", "-ldflags=-w"},
+	{"", "''"},
+	{"a b", "'a b'"},
+	{"it's", `'it'\''s'`},
+	{"$HOME", "'$HOME'"},
+	{"*.go", "'*.go'"},
+}
+
+func TestQuoteArg(t *testing.T) {
+	for _, test := range quoteArgTests {
+		got := QuoteArg(test.arg)
+		if got != test.want {
+			t.Errorf("QuoteArg(%q)=%s, want %s", test.arg, got, test.want)
+		}
+		// Quoting must round-trip through expansion.
+		words, err := Expansion(SplitWords(got), nil)
+		if err != nil {
+			t.Errorf("Expansion(%s): %v", got, err)
+			continue
+		}
+		if len(words) != 1 || words[0] != test.arg {
+			t.Errorf("Expansion(%s)=%q, want [%q]", got, words, test.arg)
+		}
+	}
+}
+
+type mapParams map[string]string
+
+func (p mapParams) Get(name string) string { return p[name] }
+
+var expandHeredocTests = []struct {
+	body, want string
+	exps       []string
+}{
+	{"plain\n", "plain\n", nil},
+	{"hello $x\n", "hello 1\n", []string{"$x"}},
+	{"${x}y '$x' \"$x\"\n", "1y '1' \"1\"\n", []string{"${x}", "$x", "$x"}},
+	{`\$x \\$x \a` + "\n", `$x \1 \a` + "\n", []string{"$x"}},
+	{"a\\\nb $ $?\n", "ab $ 0\n", []string{"$?"}},
+	{"$(echo $x) $((x + 1))\n", " \n", []string{"$(echo $x)", "$((x + 1))"}},
+}
+
+func TestExpandHeredoc(t *testing.T) {
+	params := mapParams{"x": "1", "?": "0"}
+	for _, test := range expandHeredocTests {
+		got, err := ExpandHeredoc(test.body, params)
+		if err != nil {
+			t.Errorf("ExpandHeredoc(%q): %v", test.body, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ExpandHeredoc(%q)=%q, want %q", test.body, got, test.want)
+		}
+		exps := HeredocExpansions(test.body)
+		if len(exps) != len(test.exps) {
+			t.Errorf("HeredocExpansions(%q)=%q, want %q", test.body, exps, test.exps)
+			continue
+		}
+		for i := range exps {
+			if exps[i] != test.exps[i] {
+				t.Errorf("HeredocExpansions(%q)=%q, want %q", test.body, exps, test.exps)
+				break
+			}
+		}
+	}
+}
+
+var badPatternTests = []struct {
+	arg  string
+	want []string // nil for ErrBadPattern
+}{
+	{"[", []string{"["}},
+	{"x[ab", []string{"x[ab"}},
+	{`"]"[`, []string{`"]"[`}},
+	{"x[a-]", nil},
+	{"[x]y[", []string{"[x]y["}},
+	{"[x]y[a-]", nil},
+}
+
+func TestBadPattern(t *testing.T) {
+	for _, test := range badPatternTests {
+		got, err := pathsExpand(nil, test.arg, nil)
+		if test.want == nil {
+			if err != filepath.ErrBadPattern {
+				t.Errorf("pathsExpand(%q)=%q, %v, want ErrBadPattern", test.arg, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("pathsExpand(%q): %v", test.arg, err)
+			continue
+		}
+		if len(got) != 1 || got[0] != test.want[0] {
+			t.Errorf("pathsExpand(%q)=%q, want %q", test.arg, got, test.want)
+		}
+	}
+}
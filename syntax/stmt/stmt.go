@@ -32,6 +32,7 @@ type TypeDecl struct {
 	Position src.Pos
 	Name     string
 	Type     *tipe.Named
+	Alias    bool // type Name = Type.Type
 }
 
 type TypeDeclSet struct {
@@ -28,6 +28,7 @@ type Node interface {
 type File struct {
 	Filename string
 	Stmts    []stmt.Stmt
+	Comments map[Node][]string // comments attached to statements
 }
 
 func (f File) Pos() src.Pos { return src.Pos{Filename: f.Filename} }
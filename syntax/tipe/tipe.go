@@ -18,12 +18,13 @@ type Type interface {
 }
 
 type Func struct {
-	Spec     Specialization
-	Params   *Tuple
-	Results  *Tuple
-	Variadic bool // last value of Params is a slice
-	FreeVars []string
-	FreeMdik []*Named
+	Spec       Specialization
+	TypeParams []*TypeParam // func F[T any](x T) T
+	Params     *Tuple
+	Results    *Tuple
+	Variadic   bool // last value of Params is a slice
+	FreeVars   []string
+	FreeMdik   []*Named
 }
 
 type Struct struct {
@@ -57,8 +58,10 @@ type Named struct {
 	// TODO: need to track the definition package so the evaluator can
 	// extract the mscope from the right place. Is this the only
 	// instance of needing the source package? What about debug printing?
-	Spec Specialization
-	Type Type
+	Spec       Specialization
+	TypeParams []*TypeParam // type List[T any] ...
+	TypeArgs   []Type       // instantiated from a generic type, List[string]
+	Type       Type
 
 	PkgName string
 	PkgPath string
@@ -68,6 +71,17 @@ type Named struct {
 	Methods     []*Func
 }
 
+// TypeParam is a Go-style type parameter of a function or
+// named type declaration, the T any of func F[T any](x T) T.
+// Within the declaration, the parameter is itself a type.
+//
+// Parameters declared together, as in [K, V any], share the
+// same Constraint.
+type TypeParam struct {
+	Name       string
+	Constraint Type
+}
+
 type Ellipsis struct {
 	Elem Type
 }
@@ -75,7 +89,8 @@ type Ellipsis struct {
 type Array struct {
 	Len      int64
 	Elem     Type
-	Ellipsis bool // array was defined as [...]T
+	Ellipsis bool        // array was defined as [...]T
+	LenExpr  interface{} // unevaluated constant length expr.Expr, set by parser
 }
 
 type Slice struct {
@@ -120,6 +135,23 @@ type Package struct {
 
 type Interface struct {
 	Methods map[string]*Func
+	Embeds  []Type // embedded interfaces, merged into Methods by the typechecker, and unions
+}
+
+// Union is a type-constraint element of an interface,
+// a union of type terms such as ~int | string.
+//
+// After typechecking, the Embeds of an interface hold its unions
+// and those of the interfaces it embeds.
+type Union struct {
+	Terms []*Term
+}
+
+// Term is a type term of a Union. A Tilde term, ~T, stands for
+// all types whose underlying type is T.
+type Term struct {
+	Tilde bool
+	Type  Type
 }
 
 type Alias struct {
@@ -130,6 +162,7 @@ type Alias struct {
 var (
 	Byte = &Alias{Name: "byte", Type: Uint8}
 	Rune = &Alias{Name: "rune", Type: Int32}
+	Any  = &Alias{Name: "any", Type: &Interface{Methods: map[string]*Func{}}}
 )
 
 // Specialization carries any type specialization data particular to this type.
@@ -210,8 +243,9 @@ const (
 )
 
 type Unresolved struct {
-	Package string
-	Name    string
+	Package  string
+	Name     string
+	TypeArgs []Type // generic type instantiation, List[string]
 }
 
 var (
@@ -230,8 +264,10 @@ var (
 	_ = Type((*Map)(nil))
 	_ = Type((*Package)(nil))
 	_ = Type((*Interface)(nil))
+	_ = Type((*Union)(nil))
 	_ = Type((*Alias)(nil))
 	_ = Type((*Unresolved)(nil))
+	_ = Type((*TypeParam)(nil))
 )
 
 func (t Basic) tipe()       {}
@@ -249,8 +285,10 @@ func (t *Chan) tipe()       {}
 func (t *Map) tipe()        {}
 func (t *Package) tipe()    {}
 func (t *Interface) tipe()  {}
+func (t *Union) tipe()      {}
 func (t *Alias) tipe()      {}
 func (t *Unresolved) tipe() {}
+func (t *TypeParam) tipe()  {}
 
 func IsNumeric(t Type) bool {
 	t = Unalias(t)
@@ -395,6 +433,9 @@ func (eq *equaler) equal(x, y Type) bool {
 		if x.Spec != y.Spec {
 			return false
 		}
+		if !eq.typeParams(x.TypeParams, y.TypeParams) {
+			return false
+		}
 		if !eq.equal(x.Params, y.Params) {
 			return false
 		}
@@ -442,6 +483,12 @@ func (eq *equaler) equal(x, y Type) bool {
 		if x.Spec != y.Spec {
 			return false
 		}
+		if !eq.typeParams(x.TypeParams, y.TypeParams) {
+			return false
+		}
+		if !eq.types(x.TypeArgs, y.TypeArgs) {
+			return false
+		}
 		if !eq.equal(x.Type, y.Type) {
 			return false
 		}
@@ -477,6 +524,9 @@ func (eq *equaler) equal(x, y Type) bool {
 		if x.Len != y.Len {
 			return false
 		}
+		if (x.LenExpr == nil) != (y.LenExpr == nil) {
+			return false
+		}
 		return eq.equal(x.Elem, y.Elem)
 	case *Slice:
 		y, ok := y.(*Slice)
@@ -563,6 +613,29 @@ func (eq *equaler) equal(x, y Type) bool {
 				return false
 			}
 		}
+		if len(x.Embeds) != len(y.Embeds) {
+			return false
+		}
+		for i := range x.Embeds {
+			if !eq.equal(x.Embeds[i], y.Embeds[i]) {
+				return false
+			}
+		}
+		return true
+	case *Union:
+		y, ok := y.(*Union)
+		if !ok {
+			return false
+		}
+		if len(x.Terms) != len(y.Terms) {
+			return false
+		}
+		for i, xt := range x.Terms {
+			yt := y.Terms[i]
+			if xt.Tilde != yt.Tilde || !eq.equal(xt.Type, yt.Type) {
+				return false
+			}
+		}
 		return true
 	case *Pointer:
 		y, ok := y.(*Pointer)
@@ -611,11 +684,37 @@ func (eq *equaler) equal(x, y Type) bool {
 		if x.Name != y.Name {
 			return false
 		}
-		return true
+		return eq.types(x.TypeArgs, y.TypeArgs)
+	case *TypeParam:
+		return x == y
 	}
 	panic(fmt.Sprintf("tipe.Equal TODO %T\n", x))
 }
 
+func (eq *equaler) types(x, y []Type) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !eq.equal(x[i], y[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (eq *equaler) typeParams(x, y []*TypeParam) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i].Name != y[i].Name || !eq.equal(x[i].Constraint, y[i].Constraint) {
+			return false
+		}
+	}
+	return true
+}
+
 func (t Interface) String() string {
 	if len(t.Methods) == 0 {
 		return "interface{}"
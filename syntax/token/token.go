@@ -25,46 +25,57 @@ const (
 
 	// Expression Operators
 
-	Add          // +
-	Sub          // -
-	Mul          // *
-	Div          // /
-	Rem          // %
-	Pow          // ^
-	Ref          // &
-	RefPow       // &^
-	LogicalAnd   // &&
-	LogicalOr    // ||
-	Equal        // ==
-	Less         // <
-	Greater      // >
-	Assign       // =
-	Not          // !
-	NotEqual     // !=
-	LessEqual    // <=
-	GreaterEqual // >=
-	Shell        // $$
-	ShellWord    // [^\s|&;<>()]+
-	ShellPipe    // |
-	ShellNewline // \n
-	GreaterAnd   // >&
-	AndGreater   // &>
-	TwoGreater   // >>
-	TwoLess      // <<
-	ChanOp       // <-
-	Ellipsis     // ...
+	Add           // +
+	Sub           // -
+	Mul           // *
+	Div           // /
+	Rem           // %
+	Xor           // ^
+	Ref           // &
+	RefPow        // &^
+	LogicalAnd    // &&
+	LogicalOr     // ||
+	Equal         // ==
+	Less          // <
+	Greater       // >
+	Assign        // =
+	Not           // !
+	NotEqual      // !=
+	LessEqual     // <=
+	GreaterEqual  // >=
+	Shell         // $$
+	ShellWord     // [^\s|&;<>()]+
+	ShellPipe     // |
+	ShellPipeAnd  // |&
+	ShellNewline  // \n
+	GreaterAnd    // >&
+	AndGreater    // &>
+	AndTwoGreater // &>>
+	TwoGreater    // >>
+	TwoLess       // <<
+	ThreeLess     // <<<
+	ChanOp        // <-
+	Ellipsis      // ...
+	TwoPeriod     // ..
+	TwoPeriodEq   // ..=
+	Tilde         // ~
 
 	// Statement Operators
 
-	Inc       // ++
-	Dec       // --
-	AddAssign // +=
-	SubAssign // -=
-	MulAssign // *=
-	DivAssign // /=
-	RemAssign // %=
-	PowAssign // ^=
-	Define    // :=
+	Inc              // ++
+	Dec              // --
+	AddAssign        // +=
+	SubAssign        // -=
+	MulAssign        // *=
+	DivAssign        // /=
+	RemAssign        // %=
+	XorAssign        // ^=
+	ShiftLeftAssign  // <<=
+	ShiftRightAssign // >>=
+	AndAssign        // &=
+	OrAssign         // |=
+	AndNotAssign     // &^=
+	Define           // :=
 
 	LeftParen       // (
 	LeftBracket     // [
@@ -131,7 +142,7 @@ var tokens = map[string]Token{
 	"*":            Mul,
 	"/":            Div,
 	"%":            Rem,
-	"^":            Pow,
+	"^":            Xor,
 	"&":            Ref,
 	"&^":           RefPow,
 	"&&":           LogicalAnd,
@@ -147,13 +158,19 @@ var tokens = map[string]Token{
 	"$$":           Shell,
 	"shellword":    ShellWord,
 	"shellpipe":    ShellPipe, // TODO: use Pipe
+	"|&":           ShellPipeAnd,
 	"shellnewline": ShellNewline,
 	">&":           GreaterAnd,
 	"&>":           AndGreater,
+	"&>>":          AndTwoGreater,
 	">>":           TwoGreater,
 	"<<":           TwoLess,
+	"<<<":          ThreeLess,
 	"<-":           ChanOp,
 	"...":          Ellipsis,
+	"..":           TwoPeriod,
+	"..=":          TwoPeriodEq,
+	"~":            Tilde,
 	"++":           Inc,
 	"--":           Dec,
 	"+=":           AddAssign,
@@ -161,7 +178,12 @@ var tokens = map[string]Token{
 	"*=":           MulAssign,
 	"/=":           DivAssign,
 	"RemAssign":    RemAssign,
-	"PowAssign":    PowAssign,
+	"^=":           XorAssign,
+	"<<=":          ShiftLeftAssign,
+	">>=":          ShiftRightAssign,
+	"&=":           AndAssign,
+	"|=":           OrAssign,
+	"&^=":          AndNotAssign,
 	":=":           Define,
 	"(":            LeftParen,
 	"[":            LeftBracket,
@@ -238,7 +260,7 @@ func (t Token) Precedence() int {
 		return 2
 	case Equal, NotEqual, Less, LessEqual, Greater, GreaterEqual:
 		return 3
-	case Add, Sub, Pipe, Pow:
+	case Add, Sub, Pipe, Xor:
 		return 4
 	case Mul, Div, Ref, Rem, TwoLess, RefPow, TwoGreater:
 		return 5
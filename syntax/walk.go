@@ -191,6 +191,9 @@ func (w *walker) walk(parent, node Node, fieldName string, iter *iterator) {
 	case *expr.Unary:
 		w.walk(node, node.Expr, "Expr", nil)
 
+	case *expr.Paren:
+		w.walk(node, node.Expr, "Expr", nil)
+
 	case *expr.Bad:
 
 	case *expr.Selector:
@@ -206,9 +209,18 @@ func (w *walker) walk(parent, node Node, fieldName string, iter *iterator) {
 		w.walk(node, node.Left, "Left", nil)
 		w.walkSlice(node, "Indicies")
 
+	case *expr.TableIndex:
+		w.walk(node, node.Expr, "Expr", nil)
+		w.walk(node, &node.Rows, "Rows", nil)
+
 	case *expr.TypeAssert:
 		w.walk(node, node.Left, "Left", nil)
 
+	case *expr.IfExpr:
+		w.walk(node, node.Cond, "Cond", nil)
+		w.walk(node, node.Then, "Then", nil)
+		w.walk(node, node.Else, "Else", nil)
+
 	case *expr.BasicLiteral:
 
 	case *expr.FuncLiteral:
@@ -261,10 +273,22 @@ func (w *walker) walk(parent, node Node, fieldName string, iter *iterator) {
 	case *expr.ShellCmd:
 		w.walk(node, node.SimpleCmd, "SimpleCmd", nil)
 		w.walk(node, node.Subshell, "Subshell", nil)
+		w.walk(node, node.FuncDef, "FuncDef", nil)
+
+	case *expr.ShellFuncDef:
+		w.walk(node, node.Body, "Body", nil)
 
 	case *expr.ShellSimpleCmd:
 		w.walkSlice(node, "Redirect")
 		w.walkSlice(node, "Assign")
+		w.walkSlice(node, "CmdSubst")
+		w.walkSlice(node, "Arith")
+
+	case *expr.ShellCmdSubst:
+		w.walk(node, node.Cmd, "Cmd", nil)
+
+	case *expr.ShellArith:
+		w.walk(node, node.Expr, "Expr", nil)
 
 	case *expr.ShellRedirect:
 
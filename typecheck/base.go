@@ -17,6 +17,7 @@ var errorType = &tipe.Named{
 	Type: &tipe.Interface{
 		Methods: map[string]*tipe.Func{
 			"Error": {
+				Params: &tipe.Tuple{},
 				Results: &tipe.Tuple{
 					Elems: []tipe.Type{tipe.String},
 				},
@@ -109,4 +110,5 @@ func init() {
 	}
 	Universe.Objs["byte"] = &Obj{Kind: ObjType, Type: tipe.Byte}
 	Universe.Objs["rune"] = &Obj{Kind: ObjType, Type: tipe.Rune}
+	Universe.Objs["any"] = &Obj{Kind: ObjType, Type: tipe.Any}
 }
@@ -45,9 +45,16 @@ type Checker struct {
 	importWalk    []string // in-process pkgs, used to detect cycles
 	memory        *tipe.Memory
 	resolveWalked map[*tipe.Named]bool
+	instances     map[*tipe.Named][]*tipe.Named // generic type -> instantiations
+	inferred      map[*expr.Call]*tipe.Func     // call of generic func -> inferred signature
+	tableCols     map[*Obj][]string             // table var -> column names, best-effort, see tableColNames
 
 	cur    *Scope
 	curPkg *Package
+	labels []*stmt.Labeled // enclosing labeled statements
+
+	funcLabels map[string]*stmt.Labeled // labels of the function body
+	breakable  []stmt.Stmt              // enclosing for, range, switch and select
 }
 
 func New(initPkg string) *Checker {
@@ -84,6 +91,9 @@ func New(initPkg string) *Checker {
 		importWalk:    []string{initPkg},
 		memory:        tipe.NewMemory(),
 		resolveWalked: make(map[*tipe.Named]bool),
+		instances:     make(map[*tipe.Named][]*tipe.Named),
+		inferred:      make(map[*expr.Call]*tipe.Func),
+		tableCols:     make(map[*Obj][]string),
 	}
 }
 
@@ -147,12 +157,12 @@ func IsError(t tipe.Type) bool {
 func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe.Type {
 	switch s := s.(type) {
 	case *stmt.ConstSet:
-		for _, v := range s.Consts {
-			c.checkConst(v)
+		for i, v := range s.Consts {
+			c.checkConst(v, i)
 		}
 		return nil
 	case *stmt.Const:
-		return c.checkConst(s)
+		return c.checkConst(s, 0)
 	case *stmt.VarSet:
 		for _, v := range s.Vars {
 			c.checkVar(v)
@@ -234,6 +244,11 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 				}
 				c.addObj(obj)
 				c.idents[lhs.(*expr.Ident)] = obj
+				if len(s.Left) == len(s.Right) {
+					if cols, known := c.tableColNames(s.Right[i]); known {
+						c.tableCols[obj] = cols
+					}
+				}
 			}
 		} else {
 			for i, lhs := range s.Left {
@@ -243,12 +258,30 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 					continue
 				}
 				lhsP := c.expr(lhs)
+				if lhsP.mode == modeInvalid {
+					continue
+				}
+				if lhsP.mode != modeVar || !c.canAssignTo(lhs) {
+					c.errorfmt("cannot assign to %s", format.Expr(lhs))
+					continue
+				}
 				c.assign(&p, lhsP.typ)
+				c.forgetTableCols(lhs)
 			}
 		}
 		return nil
 
 	case *stmt.Simple:
+		if fn, isFunc := s.Expr.(*expr.FuncLiteral); isFunc && fn.Name != "" {
+			// Declare a named function before checking its
+			// body, so the function may refer to itself.
+			c.addObj(&Obj{
+				Name: fn.Name,
+				Kind: ObjVar,
+				Type: fn.Type,
+				Decl: fn,
+			})
+		}
 		p := c.exprNoElide(s.Expr)
 		// TODO: explain why thi isn't just c.exprPartial(s.Expr, hintElideErr)
 		isError := IsError(p.typ)
@@ -260,17 +293,6 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 		if isError {
 			markElideError(s.Expr)
 		}
-		if p.mode == modeFunc {
-			fn := p.expr.(*expr.FuncLiteral)
-			if fn.Name != "" {
-				c.addObj(&Obj{
-					Name: fn.Name,
-					Kind: ObjVar,
-					Type: p.typ,
-					Decl: fn,
-				})
-			}
-		}
 		return p.typ
 
 	case *stmt.Block:
@@ -299,6 +321,8 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 		return nil
 
 	case *stmt.For:
+		c.breakable = append(c.breakable, s)
+		defer c.popBreakable()
 		if s.Init != nil {
 			c.pushScope()
 			defer c.popScope()
@@ -314,12 +338,28 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 		return nil
 
 	case *stmt.Range:
+		c.breakable = append(c.breakable, s)
+		defer c.popBreakable()
 		c.pushScope()
 		defer c.popScope()
 
 		p := c.expr(s.Expr)
+		if p.typ == tipe.UntypedInteger || p.typ == tipe.UntypedRune {
+			// for i := range 10
+			c.convert(&p, tipe.Int)
+		}
 		var kt, vt tipe.Type
-		switch t := p.typ.(type) {
+		switch t := tipe.Underlying(p.typ).(type) {
+		case tipe.Basic:
+			if !isInteger(t) {
+				c.errorfmt("cannot range over %s (type %s)", format.Expr(s.Expr), format.Type(p.typ))
+				break
+			}
+			if s.Val != nil {
+				c.errorfmt("range over %s permits only one iteration variable", format.Expr(s.Expr))
+				break
+			}
+			kt = p.typ
 		case *tipe.Array:
 			kt = tipe.Int
 			vt = t.Elem
@@ -373,6 +413,33 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 		return nil
 
 	case *stmt.TypeDecl:
+		if len(s.Type.TypeParams) > 0 {
+			// A generic type is resolved with its type parameters
+			// in scope, and substituted when instantiated.
+			c.addObj(&Obj{
+				Name: s.Name,
+				Kind: ObjType,
+				Type: s.Type,
+				Decl: s,
+			})
+			c.pushScope()
+			if c.declareTypeParams(s.Type.TypeParams) {
+				c.resolve(s.Type)
+			}
+			c.popScope()
+			return nil
+		}
+		if s.Alias {
+			// An alias denotes the same type as the one it names.
+			t, _ := c.resolve(s.Type.Type)
+			c.addObj(&Obj{
+				Name: s.Name,
+				Kind: ObjType,
+				Type: t,
+				Decl: s,
+			})
+			return nil
+		}
 		c.addObj(&Obj{
 			Name: s.Name,
 			Kind: ObjType,
@@ -383,9 +450,31 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 		if t.(*tipe.Named) != s.Type {
 			panic(fmt.Sprintf("resolve changed type decl: %s", s.Type.Name))
 		}
+		if containsValue(s.Type.Type, s.Type) {
+			c.errorfmt("invalid recursive type %s", s.Name)
+		}
 		return nil
 
 	case *stmt.TypeDeclSet:
+		// Declare and resolve the names of the set before
+		// checking any of them, so the types can refer to one
+		// another and a cycle among them is seen whole.
+		var named []*tipe.Named
+		for _, s := range s.TypeDecls {
+			if s.Alias || len(s.Type.TypeParams) > 0 {
+				continue
+			}
+			c.addObj(&Obj{
+				Name: s.Name,
+				Kind: ObjType,
+				Type: s.Type,
+				Decl: s,
+			})
+			named = append(named, s.Type)
+		}
+		for _, t := range named {
+			c.resolve(t)
+		}
 		for _, s := range s.TypeDecls {
 			c.stmt(s, retType, retNames)
 		}
@@ -402,6 +491,9 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 		if t.(*tipe.Named) != s.Type {
 			panic(fmt.Sprintf("resolve changed methodik decl: %s", s.Type.Name))
 		}
+		if containsValue(s.Type.Type, s.Type) {
+			c.errorfmt("invalid recursive type %s", s.Name)
+		}
 
 		var usesNum bool
 		for _, f := range s.Type.Methods {
@@ -553,14 +645,68 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 		return nil
 
 	case *stmt.Branch:
-		// TODO: make sure the branch is valid
+		switch s.Type {
+		case token.Goto:
+			if c.funcLabels[s.Label] == nil {
+				c.errorfmt("label %s not defined", s.Label)
+			}
+			return nil
+		case token.Break, token.Continue:
+		default:
+			return nil
+		}
+		if s.Label == "" {
+			for i := len(c.breakable) - 1; i >= 0; i-- {
+				switch c.breakable[i].(type) {
+				case *stmt.For, *stmt.Range:
+					return nil
+				}
+				if s.Type == token.Break {
+					return nil
+				}
+			}
+			if s.Type == token.Break {
+				c.errorfmt("break is not in a loop, switch, or select")
+			} else {
+				c.errorfmt("continue is not in a loop")
+			}
+			return nil
+		}
+		for i := len(c.labels) - 1; i >= 0; i-- {
+			l := c.labels[i]
+			if l.Label != s.Label {
+				continue
+			}
+			switch l.Stmt.(type) {
+			case *stmt.For, *stmt.Range:
+				return nil
+			case *stmt.Switch, *stmt.TypeSwitch, *stmt.Select:
+				if s.Type == token.Break {
+					return nil
+				}
+			}
+			break
+		}
+		c.errorfmt("invalid %s label %s", s.Type, s.Label)
 		return nil
 
 	case *stmt.Labeled:
+		if c.funcLabels == nil {
+			c.funcLabels = make(map[string]*stmt.Labeled)
+		}
+		if l := c.funcLabels[s.Label]; l != nil && l != s {
+			c.errorfmt("label %s already defined", s.Label)
+			return nil
+		}
+		c.funcLabels[s.Label] = s
+		c.labels = append(c.labels, s)
 		c.stmt(s.Stmt, retType, retNames)
+		c.labels = c.labels[:len(c.labels)-1]
 		return nil
 
 	case *stmt.Switch:
+		c.breakable = append(c.breakable, s)
+		defer c.popBreakable()
 		if s.Init != nil {
 			c.pushScope()
 			defer c.popScope()
@@ -579,6 +725,7 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 		}
 		ndefaults := 0
 		set := make(map[expr.Expr]struct{})
+		var consts []partial // constant case values seen so far
 		for _, cse := range s.Cases {
 			if cse.Default {
 				ndefaults++
@@ -587,12 +734,6 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 				}
 			}
 			for _, cond := range cse.Conds {
-				for k := range set {
-					if parser.EqualExpr(cond, k) {
-						c.errorfmt("duplicate case %s in switch", cond)
-					}
-				}
-				set[cond] = struct{}{}
 				p := c.expr(cond)
 				if p.mode == modeInvalid {
 					return nil
@@ -608,12 +749,33 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 				if isUntyped(p.typ) {
 					c.constrainUntyped(&p, typ)
 				}
+				if p.mode == modeConst && p.val != nil {
+					// Go rejects duplicate constant cases by value,
+					// so case 1 and case 0+1 collide.
+					for _, prev := range consts {
+						if prev.val.Kind() == p.val.Kind() && constant.Compare(p.val, gotoken.EQL, prev.val) {
+							c.errorfmt("duplicate case %s in switch (previous case %s)", format.Expr(cond), format.Expr(prev.expr))
+							break
+						}
+					}
+					p.expr = cond
+					consts = append(consts, p)
+				} else {
+					for k := range set {
+						if parser.EqualExpr(cond, k) {
+							c.errorfmt("duplicate case %s in switch", format.Expr(cond))
+						}
+					}
+					set[cond] = struct{}{}
+				}
 			}
 			c.stmt(cse.Body, retType, retNames)
 		}
 		return nil
 
 	case *stmt.TypeSwitch:
+		c.breakable = append(c.breakable, s)
+		defer c.popBreakable()
 		if s.Init != nil {
 			c.pushScope()
 			defer c.popScope()
@@ -686,6 +848,8 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 		return nil
 
 	case *stmt.Select:
+		c.breakable = append(c.breakable, s)
+		defer c.popBreakable()
 		dflts := 0
 		set := make(map[stmt.Stmt]struct{})
 		for _, cse := range s.Cases {
@@ -741,21 +905,32 @@ func (c *Checker) stmt(s stmt.Stmt, retType *tipe.Tuple, retNames []string) tipe
 	}
 }
 
-func (c *Checker) checkConst(s *stmt.Const) tipe.Type {
+// checkConst checks s, the iota'th constant specification
+// in its declaration.
+func (c *Checker) checkConst(s *stmt.Const, iota int) tipe.Type {
 	if s.Type != nil {
 		if t, ok := c.resolve(s.Type); ok {
 			s.Type = t
 		}
 	}
+	c.pushScope()
+	c.addObj(&Obj{
+		Name: "iota",
+		Kind: ObjConst,
+		Type: tipe.UntypedInteger,
+		Decl: constant.MakeInt64(int64(iota)),
+	})
 	var partials []partial
 	for _, rhs := range s.Values {
 		p := c.exprNoElide(rhs)
 		if p.mode == modeInvalid {
+			c.popScope()
 			return nil
 		}
 		if tuple, isTuple := p.typ.(*tipe.Tuple); isTuple {
 			if len(s.Values) > 1 {
 				c.errorfmt("multiple value %s in single-value context", rhs)
+				c.popScope()
 				return nil
 			}
 			for _, t := range tuple.Elems {
@@ -768,6 +943,7 @@ func (c *Checker) checkConst(s *stmt.Const) tipe.Type {
 		}
 		partials = append(partials, c.exprNoElide(rhs))
 	}
+	c.popScope()
 	if len(s.Values) == 1 && len(s.NameList) == 2 && len(s.NameList) == len(partials)+1 {
 		partials = c.checkCommaOK(s.Values[0], partials)
 	}
@@ -994,6 +1170,7 @@ func (c *Checker) checkCommaOK(e expr.Expr, partials []partial) []partial {
 var goErrorID = gotypes.Universe.Lookup("error").Id()
 
 func (c *Checker) fromGoType(t gotypes.Type) (res tipe.Type) {
+	t = gotypes.Unalias(t)
 	if res = c.goTypes[t]; res != nil {
 		return res
 	}
@@ -1224,6 +1401,37 @@ func (c *Checker) Pkg(path string) *Package {
 	return c.pkgs[path]
 }
 
+// Exported returns the exported objects of the Neugram or Go package
+// imported by importPath, sorted by name. The package is loaded if
+// the type checker has not yet processed it.
+func (c *Checker) Exported(importPath string) ([]*Obj, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var pkg *Package
+	var err error
+	if strings.HasSuffix(importPath, ".ng") {
+		pkg, err = c.ngPkg(importPath)
+	} else {
+		pkg, err = c.goPkg(importPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, obj := range pkg.Globals {
+		if isExported(obj.Name) {
+			names = append(names, obj.Name)
+		}
+	}
+	sort.Strings(names)
+	objs := make([]*Obj, len(names))
+	for i, name := range names {
+		objs[i] = pkg.GlobalNames[name]
+	}
+	return objs, nil
+}
+
 func (c *Checker) ngPkg(path string) (*Package, error) {
 	var filename string
 	if strings.HasPrefix(path, "./") {
@@ -1306,6 +1514,12 @@ func (c *Checker) parseFile(filename string, source []byte) error {
 	}
 	c.curPkg.Syntax = f
 
+	funcLabels := c.funcLabels
+	c.funcLabels = nil
+	defer func() { c.funcLabels = funcLabels }()
+	for _, s := range f.Stmts {
+		c.addLabels(s)
+	}
 	for _, s := range f.Stmts {
 		c.stmt(s, nil, nil)
 		if len(c.errs) > 0 {
@@ -1403,7 +1617,43 @@ func (c *Checker) resolve(t tipe.Type) (ret tipe.Type, resolved bool) {
 			m[name] = f.(*tipe.Func)
 			resolved = resolved && r1
 		}
+		// The union elements of t and of the interfaces it
+		// embeds are kept in Embeds. A type in the type set of t
+		// is in every one of them.
+		var unions []tipe.Type
+		for _, e := range t.Embeds {
+			if u, isUnion := e.(*tipe.Union); isUnion {
+				for _, term := range u.Terms {
+					var r bool
+					term.Type, r = c.resolve(term.Type)
+					resolved = resolved && r
+				}
+				unions = append(unions, u)
+				continue
+			}
+			e, r1 := c.resolve(e)
+			resolved = resolved && r1
+			if !r1 {
+				continue
+			}
+			embed, ok := tipe.Underlying(e).(*tipe.Interface)
+			if !ok {
+				c.errorfmt("interface contains embedded non-interface %s", format.Type(e))
+				resolved = false
+				continue
+			}
+			for name, f := range embed.Methods {
+				if m[name] != nil && !tipe.Equal(m[name], f) {
+					c.errorfmt("duplicate method %s", name)
+					resolved = false
+					continue
+				}
+				m[name] = f
+			}
+			unions = append(unions, embed.Embeds...)
+		}
 		t.Methods = m
+		t.Embeds = unions
 		return t, resolved
 	case *tipe.Map:
 		var r1, r2 bool
@@ -1426,6 +1676,12 @@ func (c *Checker) resolve(t tipe.Type) (ret tipe.Type, resolved bool) {
 		t.Elem, resolved = c.resolve(t.Elem)
 		return t, resolved
 	case *tipe.Array:
+		if x, ok := t.LenExpr.(expr.Expr); ok {
+			if !c.resolveArrayLen(t, x) {
+				return t, false
+			}
+			t.LenExpr = nil
+		}
 		t.Elem, resolved = c.resolve(t.Elem)
 		return t, resolved
 	case *tipe.Slice:
@@ -1481,6 +1737,16 @@ func (c *Checker) resolve(t tipe.Type) (ret tipe.Type, resolved bool) {
 			c.errorfmt("symbol %s is not a type", t.Name)
 			return t, false
 		}
+		if named, ok := obj.Type.(*tipe.Named); ok && len(named.TypeParams) > 0 {
+			if len(t.TypeArgs) == 0 {
+				c.errorfmt("cannot use generic type %s without instantiation", t.Name)
+				return t, false
+			}
+			return c.instantiateType(named, t.TypeArgs)
+		} else if len(t.TypeArgs) > 0 {
+			c.errorfmt("%s is not a generic type", t.Name)
+			return t, false
+		}
 		return obj.Type, true
 		// TODO many more types
 	default:
@@ -1488,104 +1754,587 @@ func (c *Checker) resolve(t tipe.Type) (ret tipe.Type, resolved bool) {
 	}
 }
 
-func (c *Checker) lookupPkgType(pkgName, sel string) tipe.Type {
-	name := pkgName + "." + sel
-	obj := c.cur.LookupRec(pkgName)
-	if obj == nil {
-		c.errorfmt("undefined %s in %s", pkgName, name)
-		return nil
+// declareTypeParams resolves the constraints of the type parameters
+// params and declares each parameter as a type in the current scope.
+func (c *Checker) declareTypeParams(params []*tipe.TypeParam) bool {
+	resolved := true
+	constraints := make(map[tipe.Type]tipe.Type) // shared by [K, V any]
+	for _, tp := range params {
+		if t, ok := constraints[tp.Constraint]; ok {
+			tp.Constraint = t
+		} else {
+			orig := tp.Constraint
+			if u, isUnion := tp.Constraint.(*tipe.Union); isUnion {
+				for _, term := range u.Terms {
+					var r bool
+					term.Type, r = c.resolve(term.Type)
+					resolved = resolved && r
+				}
+			} else {
+				var r bool
+				tp.Constraint, r = c.resolve(tp.Constraint)
+				resolved = resolved && r
+			}
+			constraints[orig] = tp.Constraint
+		}
+		if c.cur.Objs[tp.Name] != nil {
+			c.errorfmt("type parameter %s redeclared", tp.Name)
+			resolved = false
+			continue
+		}
+		c.addObj(&Obj{
+			Name: tp.Name,
+			Kind: ObjType,
+			Type: tp,
+		})
 	}
-	if obj.Kind != ObjPkg {
-		c.errorfmt("%s is not a packacge", pkgName)
-		return nil
+	return resolved
+}
+
+// typeArgs resolves the type arguments args of an instantiation
+// of name, which has the type parameters params.
+func (c *Checker) typeArgs(name string, params []*tipe.TypeParam, args []tipe.Type) (map[*tipe.TypeParam]tipe.Type, bool) {
+	if len(args) != len(params) {
+		c.errorfmt("wrong number of type arguments for %s: got %d, want %d", name, len(args), len(params))
+		return nil, false
 	}
-	pkg := obj.Decl.(*Package)
-	res := pkg.GlobalNames[sel]
-	if res == nil || !isExported(sel) {
-		c.errorfmt("%s not in package %s", name, pkgName)
-		return nil
+	m := make(map[*tipe.TypeParam]tipe.Type, len(params))
+	for i, arg := range args {
+		t, resolved := c.resolve(arg)
+		if !resolved {
+			return nil, false
+		}
+		m[params[i]] = t
 	}
-	return res.Type
+	if !c.satisfy(params, m) {
+		return nil, false
+	}
+	return m, true
 }
 
-func (c *Checker) exprBuiltinCall(e *expr.Call) partial {
-	p := c.expr(e.Func)
-	p.expr = e
+// satisfy checks that each type argument in m satisfies the
+// constraint of its type parameter.
+func (c *Checker) satisfy(params []*tipe.TypeParam, m map[*tipe.TypeParam]tipe.Type) bool {
+	for _, tp := range params {
+		if !c.satisfies(m[tp], tp.Constraint) {
+			c.errorfmt("%s does not satisfy %s", m[tp], tp.Constraint)
+			return false
+		}
+	}
+	return true
+}
 
-	switch p.typ.(tipe.Builtin) {
-	case tipe.Append:
-		if len(e.Args) == 0 {
-			p.mode = modeInvalid
-			c.errorfmt("too few arguments to append")
-			return p
+// satisfies reports whether the type argument t satisfies
+// constraint. An interface constraint is satisfied by the types
+// that implement it. A union constraint, ~int | string, is
+// satisfied by the types it lists, and for a ~T term, by every
+// type whose underlying type is T. An interface with union
+// elements, interface{ ~int | ~float64 }, must be satisfied both
+// ways.
+//
+// A type parameter satisfies a union if every term of its own
+// constraint does.
+func (c *Checker) satisfies(t, constraint tipe.Type) bool {
+	u := typeSet(constraint)
+	if _, isIface := tipe.Underlying(constraint).(*tipe.Interface); isIface {
+		if !c.assignable(constraint, t) {
+			return false
+		}
+		if u == nil {
+			return true
 		}
-		arg0 := c.expr(e.Args[0])
-		slice, isSlice := tipe.Underlying(arg0.typ).(*tipe.Slice)
-		if !isSlice {
-			p.mode = modeInvalid
-			c.errorfmt("first argument to append must be a slice, got %s", arg0.typ)
-			return p
+	}
+	tp, isParam := t.(*tipe.TypeParam)
+	if !isParam {
+		return inUnion(&tipe.Term{Type: t}, u)
+	}
+	tu := typeSet(tp.Constraint)
+	if tu == nil {
+		return false
+	}
+	for _, term := range tu.Terms {
+		if !inUnion(term, u) {
+			return false
 		}
-		p.typ = arg0.typ
-		// TODO: append(x, y...)
-		for _, arg := range e.Args[1:] {
-			argp := c.expr(arg)
-			argpTyp := argp.typ
-			c.convert(&argp, slice.Elem)
-			if argp.mode == modeInvalid {
-				p.mode = modeInvalid
-				c.errorfmt("cannot use %s (type %s) as type %s in argument to append", arg, argpTyp, slice.Elem)
-				return p
+	}
+	return true
+}
+
+// typeSet returns the union of types allowed by constraint.
+// A constraint that is a single type, [T int], is a union
+// of one term. An interface constraint allows the types in all
+// of its union elements, and typeSet returns nil if it has none.
+func typeSet(constraint tipe.Type) *tipe.Union {
+	if u, isUnion := constraint.(*tipe.Union); isUnion {
+		return u
+	}
+	iface, isIface := tipe.Underlying(constraint).(*tipe.Interface)
+	if !isIface {
+		return &tipe.Union{Terms: []*tipe.Term{{Type: constraint}}}
+	}
+	var u *tipe.Union
+	for _, e := range iface.Embeds {
+		eu := e.(*tipe.Union)
+		if u == nil {
+			u = eu
+			continue
+		}
+		both := &tipe.Union{}
+		for _, term := range u.Terms {
+			if inUnion(term, eu) {
+				both.Terms = append(both.Terms, term)
 			}
 		}
-		return p
-	case tipe.Close:
-		p.typ = nil
-		if len(e.Args) != 1 {
-			p.mode = modeInvalid
-			c.errorfmt("too few arguments to close")
-			return p
+		u = both
+	}
+	return u
+}
+
+// inUnion reports whether the types of term are in the union u.
+func inUnion(term *tipe.Term, u *tipe.Union) bool {
+	for _, ut := range u.Terms {
+		if ut.Tilde {
+			if tipe.Equal(tipe.Underlying(term.Type), ut.Type) {
+				return true
+			}
+		} else if !term.Tilde && tipe.Equal(term.Type, ut.Type) {
+			return true
 		}
-		arg := c.expr(e.Args[0])
-		ch, isChan := tipe.Underlying(arg.typ).(*tipe.Chan)
-		if !isChan {
-			p.mode = modeInvalid
-			c.errorfmt("argument to close must be a chan, got %s", arg.typ)
-			return p
+	}
+	return false
+}
+
+// instantiateType returns the instantiation of the generic type t
+// with the type arguments args, List[string] for type List[T any].
+// Equal instantiations return the same *tipe.Named.
+func (c *Checker) instantiateType(t *tipe.Named, args []tipe.Type) (tipe.Type, bool) {
+	m, ok := c.typeArgs(t.Name, t.TypeParams, args)
+	if !ok {
+		return t, false
+	}
+	targs := make([]tipe.Type, len(t.TypeParams))
+	for i, tp := range t.TypeParams {
+		targs[i] = m[tp]
+	}
+	for _, inst := range c.instances[t] {
+		if tipe.Equal(&tipe.Tuple{Elems: inst.TypeArgs}, &tipe.Tuple{Elems: targs}) {
+			return inst, true
 		}
-		if ch.Direction == tipe.ChanRecv {
-			p.mode = modeInvalid
-			c.errorfmt("%s: cannot close receive-only channel", e)
+	}
+	inst := &tipe.Named{
+		Name:     t.Name,
+		PkgName:  t.PkgName,
+		PkgPath:  t.PkgPath,
+		TypeArgs: targs,
+		Type:     subst(t.Type, m),
+	}
+	c.instances[t] = append(c.instances[t], inst)
+	return inst, true
+}
+
+// instantiateFunc checks the explicit instantiation e, F[int], of the
+// generic function fn. Its type is the signature of fn with the type
+// arguments substituted for the type parameters.
+func (c *Checker) instantiateFunc(e *expr.Index, left partial, fn *tipe.Func) partial {
+	var args []tipe.Type
+	for _, ind := range e.Indicies {
+		p := c.exprPartial(ind, hintNone)
+		if p.mode == modeInvalid {
 			return p
 		}
-		return p
-	case tipe.ComplexFunc:
-		if len(e.Args) != 2 {
+		if p.mode != modeTypeExpr {
 			p.mode = modeInvalid
-			c.errorfmt("complex takes two arguments, got %d", len(e.Args))
+			c.errorfmt("%s is not a type", format.Expr(ind))
 			return p
 		}
-		arg0 := c.expr(e.Args[0])
-		arg1 := c.expr(e.Args[1])
-		switch arg0.typ {
-		case tipe.UntypedInteger:
-			switch arg1.typ {
-			case tipe.UntypedInteger, tipe.UntypedFloat:
-				// FIXME: return UntypedComplex
-				p.typ = tipe.Complex128
-			case tipe.Float:
-				p.typ = tipe.Complex
-			case tipe.Float32:
-				p.typ = tipe.Complex64
-			case tipe.Float64:
-				p.typ = tipe.Complex128
-			default:
-				p.mode = modeInvalid
-				c.errorfmt("second argument to complex must be a float, got %s", arg1.typ)
-				return p
+		args = append(args, p.typ)
+	}
+	m, ok := c.typeArgs(format.Expr(e.Left), fn.TypeParams, args)
+	if !ok {
+		left.mode = modeInvalid
+		return left
+	}
+	left.expr = e
+	left.typ = subst(&tipe.Func{
+		Params:   fn.Params,
+		Results:  fn.Results,
+		Variadic: fn.Variadic,
+	}, m)
+	return left
+}
+
+// infer infers the type arguments of the call e of the generic
+// function fn from the types of the arguments args, and returns
+// the signature of fn instantiated with them.
+//
+// Each parameter type is unified with the type of its argument.
+// Untyped constant arguments are considered last, so F(x, 1)
+// takes T from x, and an untyped argument otherwise gives its
+// type parameter the default type of the constant.
+func (c *Checker) infer(e *expr.Call, fn *tipe.Func, args []partial) (*tipe.Func, bool) {
+	m := make(map[*tipe.TypeParam]tipe.Type, len(fn.TypeParams))
+	for _, tp := range fn.TypeParams {
+		m[tp] = nil
+	}
+	var params []tipe.Type
+	if fn.Params != nil {
+		params = fn.Params.Elems
+	}
+	paramType := func(i int) tipe.Type {
+		if i < len(params)-1 || !fn.Variadic {
+			if i < len(params) {
+				return params[i]
 			}
-		case tipe.UntypedFloat:
-			switch arg1.typ {
+			return nil
+		}
+		elem := params[len(params)-1].(*tipe.Ellipsis).Elem
+		if e.Ellipsis {
+			return &tipe.Slice{Elem: elem}
+		}
+		return elem
+	}
+	for i, arg := range args {
+		if pt := paramType(i); pt != nil && !isUntyped(arg.typ) && !unify(pt, arg.typ, m) {
+			c.errorfmt("type %s of %s does not match inferred type %s for %s", arg.typ, format.Expr(arg.expr), subst(pt, m), format.Type(pt))
+			return fn, false
+		}
+	}
+	fromUntyped := make(map[*tipe.TypeParam]bool)
+	for i, arg := range args {
+		tp, isParam := paramType(i).(*tipe.TypeParam)
+		if !isParam || !isUntyped(arg.typ) || tipe.IsUntypedNil(arg.typ) {
+			continue // checked against the instantiated signature
+		}
+		argt := defaultType(arg.typ)
+		switch inferred, isOwn := m[tp]; {
+		case !isOwn:
+		case inferred == nil:
+			m[tp] = argt
+			fromUntyped[tp] = true
+		case fromUntyped[tp] && !tipe.Equal(inferred, argt):
+			c.errorfmt("default type %s of %s does not match inferred type %s for %s", argt, format.Expr(arg.expr), inferred, tp.Name)
+			return fn, false
+		}
+	}
+	for _, tp := range fn.TypeParams {
+		if m[tp] == nil {
+			c.errorfmt("in call to %s, cannot infer %s", format.Expr(e.Func), tp.Name)
+			return fn, false
+		}
+	}
+	if !c.satisfy(fn.TypeParams, m) {
+		return fn, false
+	}
+	inst := subst(&tipe.Func{
+		Params:   fn.Params,
+		Results:  fn.Results,
+		Variadic: fn.Variadic,
+	}, m).(*tipe.Func)
+	c.inferred[e] = inst
+	return inst, true
+}
+
+// unify matches the parameter type x against the argument type y,
+// recording in m the type argument of each type parameter in x
+// that is a key of m. It reports false if a type parameter is
+// matched by two different types. A y that is not of the shape
+// of x is left for the assignment check of the argument.
+func unify(x, y tipe.Type, m map[*tipe.TypeParam]tipe.Type) bool {
+	switch x := x.(type) {
+	case *tipe.TypeParam:
+		arg, isOwn := m[x]
+		switch {
+		case !isOwn:
+			return true
+		case arg == nil:
+			m[x] = y
+			return true
+		default:
+			return tipe.Equal(arg, y)
+		}
+	case *tipe.Pointer:
+		if y, ok := y.(*tipe.Pointer); ok {
+			return unify(x.Elem, y.Elem, m)
+		}
+	case *tipe.Slice:
+		if y, ok := y.(*tipe.Slice); ok {
+			return unify(x.Elem, y.Elem, m)
+		}
+	case *tipe.Array:
+		if y, ok := y.(*tipe.Array); ok {
+			return unify(x.Elem, y.Elem, m)
+		}
+	case *tipe.Chan:
+		if y, ok := y.(*tipe.Chan); ok {
+			return unify(x.Elem, y.Elem, m)
+		}
+	case *tipe.Map:
+		if y, ok := y.(*tipe.Map); ok {
+			return unify(x.Key, y.Key, m) && unify(x.Value, y.Value, m)
+		}
+	case *tipe.Func:
+		if y, ok := y.(*tipe.Func); ok {
+			return unify(x.Params, y.Params, m) && unify(x.Results, y.Results, m)
+		}
+	case *tipe.Tuple:
+		if y, ok := y.(*tipe.Tuple); ok && x != nil && y != nil && len(x.Elems) == len(y.Elems) {
+			for i := range x.Elems {
+				if !unify(x.Elems[i], y.Elems[i], m) {
+					return false
+				}
+			}
+		}
+	case *tipe.Named:
+		// An instantiated generic type, List[T] matching List[int].
+		if y, ok := y.(*tipe.Named); ok && x.Name == y.Name && x.PkgPath == y.PkgPath && len(x.TypeArgs) == len(y.TypeArgs) {
+			for i := range x.TypeArgs {
+				if !unify(x.TypeArgs[i], y.TypeArgs[i], m) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// subst returns t with each type parameter in m replaced by its
+// type argument.
+func subst(t tipe.Type, m map[*tipe.TypeParam]tipe.Type) tipe.Type {
+	switch t := t.(type) {
+	case *tipe.TypeParam:
+		if arg := m[t]; arg != nil {
+			return arg
+		}
+		return t
+	case *tipe.Func:
+		f := *t
+		f.Params, _ = subst(t.Params, m).(*tipe.Tuple)
+		f.Results, _ = subst(t.Results, m).(*tipe.Tuple)
+		return &f
+	case *tipe.Tuple:
+		if t == nil {
+			return t
+		}
+		res := &tipe.Tuple{Elems: make([]tipe.Type, len(t.Elems))}
+		for i, elem := range t.Elems {
+			res.Elems[i] = subst(elem, m)
+		}
+		return res
+	case *tipe.Struct:
+		res := &tipe.Struct{Spec: t.Spec, Fields: make([]tipe.StructField, len(t.Fields))}
+		for i, f := range t.Fields {
+			f.Type = subst(f.Type, m)
+			res.Fields[i] = f
+		}
+		return res
+	case *tipe.Pointer:
+		return &tipe.Pointer{Elem: subst(t.Elem, m)}
+	case *tipe.Slice:
+		return &tipe.Slice{Elem: subst(t.Elem, m)}
+	case *tipe.Array:
+		a := *t
+		a.Elem = subst(t.Elem, m)
+		return &a
+	case *tipe.Ellipsis:
+		return &tipe.Ellipsis{Elem: subst(t.Elem, m)}
+	case *tipe.Map:
+		return &tipe.Map{Key: subst(t.Key, m), Value: subst(t.Value, m)}
+	case *tipe.Chan:
+		return &tipe.Chan{Direction: t.Direction, Elem: subst(t.Elem, m)}
+	case *tipe.Table:
+		return &tipe.Table{Type: subst(t.Type, m)}
+	case *tipe.Interface:
+		res := &tipe.Interface{Methods: make(map[string]*tipe.Func, len(t.Methods))}
+		for name, f := range t.Methods {
+			res.Methods[name] = subst(f, m).(*tipe.Func)
+		}
+		return res
+	default:
+		return t
+	}
+}
+
+// resolveArrayLen evaluates the constant length expression x of t.
+func (c *Checker) resolveArrayLen(t *tipe.Array, x expr.Expr) bool {
+	p := c.expr(x)
+	if p.mode == modeInvalid {
+		return false
+	}
+	if p.mode != modeConst {
+		c.errorfmt("array length %s must be constant", format.Expr(x))
+		return false
+	}
+	c.convert(&p, tipe.Int)
+	if p.mode == modeInvalid {
+		return false
+	}
+	n, ok := constant.Int64Val(constant.ToInt(p.val))
+	if !ok || n < 0 {
+		c.errorfmt("invalid array length %s", format.Expr(x))
+		return false
+	}
+	t.Len = n
+	return true
+}
+
+// checkShift reports whether the operands of the shift e are valid.
+// The left operand must be an integer. The shift count must be an
+// unsigned integer or a non-negative integer constant.
+func (c *Checker) checkShift(e *expr.Binary, lt, rt tipe.Type, right partial) bool {
+	switch tipe.Underlying(rt) {
+	case tipe.Uint, tipe.Uint8, tipe.Uint16, tipe.Uint32, tipe.Uint64, tipe.Uintptr:
+		// ok
+	case tipe.UntypedInteger, tipe.UntypedRune:
+		if right.mode == modeConst && constant.Sign(right.val) < 0 {
+			c.errorfmt("invalid operation: %s is a negative integer", format.Expr(e.Right))
+			return false
+		}
+	default:
+		c.errorfmt("invalid operation: %s (shift count type %v, must be unsigned integer)",
+			format.Expr(e), format.Type(rt),
+		)
+		return false
+	}
+	switch tipe.Underlying(lt) {
+	case tipe.UntypedInteger, tipe.UntypedRune,
+		tipe.Int, tipe.Int8, tipe.Int16, tipe.Int32, tipe.Int64,
+		tipe.Uint, tipe.Uint8, tipe.Uint16, tipe.Uint32, tipe.Uint64, tipe.Uintptr:
+		return true
+	}
+	c.errorfmt("invalid operation: %s (shift of type %v)",
+		format.Expr(e), format.Type(lt),
+	)
+	return false
+}
+
+func (c *Checker) lookupPkgType(pkgName, sel string) tipe.Type {
+	name := pkgName + "." + sel
+	obj := c.cur.LookupRec(pkgName)
+	if obj == nil {
+		c.errorfmt("undefined %s in %s", pkgName, name)
+		return nil
+	}
+	if obj.Kind != ObjPkg {
+		c.errorfmt("%s is not a packacge", pkgName)
+		return nil
+	}
+	pkg := obj.Decl.(*Package)
+	res := pkg.GlobalNames[sel]
+	if res == nil || !isExported(sel) {
+		c.errorfmt("%s not in package %s", name, pkgName)
+		return nil
+	}
+	return res.Type
+}
+
+func (c *Checker) exprBuiltinCall(e *expr.Call) partial {
+	p := c.expr(e.Func)
+	p.expr = e
+
+	switch p.typ.(tipe.Builtin) {
+	case tipe.Append:
+		if len(e.Args) == 0 {
+			p.mode = modeInvalid
+			c.errorfmt("too few arguments to append")
+			return p
+		}
+		arg0 := c.expr(e.Args[0])
+		slice, isSlice := tipe.Underlying(arg0.typ).(*tipe.Slice)
+		if !isSlice {
+			p.mode = modeInvalid
+			c.errorfmt("first argument to append must be a slice, got %s", arg0.typ)
+			return p
+		}
+		p.typ = arg0.typ
+		if e.Ellipsis {
+			if len(e.Args) != 2 {
+				p.mode = modeInvalid
+				c.errorfmt("can only use ... with final argument to append")
+				return p
+			}
+			arg := e.Args[1]
+			argp := c.expr(arg)
+			if argp.mode == modeInvalid {
+				p.mode = modeInvalid
+				return p
+			}
+			if isString(argp.typ) {
+				// Special case: append([]byte, string...)
+				if !tipe.Equal(tipe.Unalias(slice.Elem), tipe.Uint8) {
+					p.mode = modeInvalid
+					c.errorfmt("cannot use %s (type %s) as type %s in argument to append", arg, argp.typ, arg0.typ)
+					return p
+				}
+				if isUntyped(argp.typ) {
+					c.constrainUntyped(&argp, tipe.String)
+				}
+				return p
+			}
+			if !c.assignable(&tipe.Slice{Elem: slice.Elem}, argp.typ) {
+				p.mode = modeInvalid
+				c.errorfmt("cannot use %s (type %s) as type %s in argument to append", arg, argp.typ, arg0.typ)
+				return p
+			}
+			return p
+		}
+		for _, arg := range e.Args[1:] {
+			argp := c.expr(arg)
+			argpTyp := argp.typ
+			c.convert(&argp, slice.Elem)
+			if argp.mode == modeInvalid {
+				p.mode = modeInvalid
+				c.errorfmt("cannot use %s (type %s) as type %s in argument to append", arg, argpTyp, slice.Elem)
+				return p
+			}
+		}
+		return p
+	case tipe.Close:
+		p.typ = nil
+		if len(e.Args) != 1 {
+			p.mode = modeInvalid
+			c.errorfmt("too few arguments to close")
+			return p
+		}
+		arg := c.expr(e.Args[0])
+		ch, isChan := tipe.Underlying(arg.typ).(*tipe.Chan)
+		if !isChan {
+			p.mode = modeInvalid
+			c.errorfmt("argument to close must be a chan, got %s", arg.typ)
+			return p
+		}
+		if ch.Direction == tipe.ChanRecv {
+			p.mode = modeInvalid
+			c.errorfmt("%s: cannot close receive-only channel", e)
+			return p
+		}
+		return p
+	case tipe.ComplexFunc:
+		if len(e.Args) != 2 {
+			p.mode = modeInvalid
+			c.errorfmt("complex takes two arguments, got %d", len(e.Args))
+			return p
+		}
+		arg0 := c.expr(e.Args[0])
+		arg1 := c.expr(e.Args[1])
+		switch arg0.typ {
+		case tipe.UntypedInteger:
+			switch arg1.typ {
+			case tipe.UntypedInteger, tipe.UntypedFloat:
+				// FIXME: return UntypedComplex
+				p.typ = tipe.Complex128
+			case tipe.Float:
+				p.typ = tipe.Complex
+			case tipe.Float32:
+				p.typ = tipe.Complex64
+			case tipe.Float64:
+				p.typ = tipe.Complex128
+			default:
+				p.mode = modeInvalid
+				c.errorfmt("second argument to complex must be a float, got %s", arg1.typ)
+				return p
+			}
+		case tipe.UntypedFloat:
+			switch arg1.typ {
 			case tipe.UntypedInteger, tipe.UntypedFloat:
 				// FIXME: return UntypedComplex
 				p.typ = tipe.Complex128
@@ -1735,7 +2484,12 @@ func (c *Checker) exprBuiltinCall(e *expr.Call) partial {
 		}
 		arg0 := c.expr(e.Args[0])
 		switch t := tipe.Underlying(arg0.typ).(type) {
-		case *tipe.Array, *tipe.Slice, *tipe.Map, *tipe.Chan:
+		case *tipe.Array:
+			// The length of an array is a constant.
+			p.mode = modeConst
+			p.val = constant.MakeInt64(t.Len)
+			return p
+		case *tipe.Slice, *tipe.Map, *tipe.Chan:
 			return p
 		case tipe.Basic:
 			switch t {
@@ -1754,8 +2508,12 @@ func (c *Checker) exprBuiltinCall(e *expr.Call) partial {
 			return p
 		}
 		arg0 := c.expr(e.Args[0])
-		switch tipe.Underlying(arg0.typ).(type) {
-		case *tipe.Array, *tipe.Slice, *tipe.Map, *tipe.Chan:
+		switch t := tipe.Underlying(arg0.typ).(type) {
+		case *tipe.Array:
+			p.mode = modeConst
+			p.val = constant.MakeInt64(t.Len)
+			return p
+		case *tipe.Slice, *tipe.Map, *tipe.Chan:
 			return p
 		}
 		p.mode = modeInvalid
@@ -1899,6 +2657,24 @@ func (c *Checker) exprPartialCall(e *expr.Call) partial {
 	p.mode = modeVar
 	p.expr = e
 	funct := tipe.Underlying(p.typ).(*tipe.Func)
+
+	// When we have exactly one argument, the Go spec allows this
+	// to be treated as multiple arguments in a few cases, such as
+	// when calling f(g()) and g returns multiple values. Handle this.
+	// TODO: also handle the comma-ok cases.
+	unpacked, ok := c.unpackExprs(hintNone, e.Args...)
+	if !ok {
+		p.mode = modeInvalid
+		return p
+	}
+
+	if len(funct.TypeParams) > 0 {
+		funct, ok = c.infer(e, funct, unpacked)
+		if !ok {
+			p.mode = modeInvalid
+			return p
+		}
+	}
 	var params, results []tipe.Type
 	if funct.Params != nil {
 		params = funct.Params.Elems
@@ -1916,16 +2692,6 @@ func (c *Checker) exprPartialCall(e *expr.Call) partial {
 		p.typ = funct.Results
 	}
 
-	// When we have exactly one argument, the Go spec allows this
-	// to be treated as multiple arguments in a few cases, such as
-	// when calling f(g()) and g returns multiple values. Handle this.
-	// TODO: also handle the comma-ok cases.
-	unpacked, ok := c.unpackExprs(hintNone, e.Args...)
-	if !ok {
-		p.mode = modeInvalid
-		return p
-	}
-
 	// If we have f([a, b,] c...), check whether that is permissible.
 	if e.Ellipsis {
 		if !funct.Variadic {
@@ -2090,11 +2856,11 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 		case string:
 			p.mode = modeConst
 			p.typ = tipe.UntypedString
-			p.val = constant.MakeFromLiteral(v, gotoken.STRING, 0)
+			p.val = constant.MakeString(v)
 		case rune:
 			p.mode = modeConst
 			p.typ = tipe.UntypedRune
-			p.val = constant.MakeFromLiteral(string(v), gotoken.CHAR, 0)
+			p.val = constant.MakeInt64(int64(v))
 		case bool:
 			p.mode = modeConst
 			p.typ = tipe.UntypedBool
@@ -2106,6 +2872,10 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 		defer c.popScope()
 		c.cur.foundInParent = make(map[string]bool)
 		c.cur.foundMdikInParent = make(map[*tipe.Named]bool)
+		if !c.declareTypeParams(e.Type.TypeParams) {
+			p.mode = modeInvalid
+			return p
+		}
 		if e.Type.Params != nil {
 			for i, t := range e.Type.Params.Elems {
 				t, _ = c.resolve(t)
@@ -2142,7 +2912,12 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 				}
 			}
 		}
-		c.stmt(e.Body.(*stmt.Block), e.Type.Results, retNames)
+		labels, funcLabels, breakable := c.labels, c.funcLabels, c.breakable
+		c.labels, c.funcLabels, c.breakable = nil, nil, nil
+		body := e.Body.(*stmt.Block)
+		c.addLabels(body)
+		c.stmt(body, e.Type.Results, retNames)
+		c.labels, c.funcLabels, c.breakable = labels, funcLabels, breakable
 		for _, pname := range e.ParamNames {
 			delete(c.cur.foundInParent, pname)
 		}
@@ -2303,6 +3078,64 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 		p.mode = modeInvalid
 		return p
 
+	case *expr.IfExpr:
+		cond := c.expr(e.Cond)
+		if cond.mode == modeInvalid {
+			p.mode = modeInvalid
+			return p
+		}
+		c.constrainUntyped(&cond, tipe.Bool)
+		if tipe.Underlying(cond.typ) != tipe.Bool {
+			c.errorfmt("non-boolean condition %s in if expression", format.Expr(e.Cond))
+			p.mode = modeInvalid
+			return p
+		}
+		then := c.expr(e.Then)
+		els := c.expr(e.Else)
+		if then.mode == modeInvalid || els.mode == modeInvalid {
+			p.mode = modeInvalid
+			return p
+		}
+		if isUntyped(then.typ) && isUntyped(els.typ) {
+			// Two untyped constants take the default type
+			// of the wider one, as in if c { 1 } else { 2.5 }.
+			t := then.typ
+			if then.typ != els.typ {
+				if untypedRank(then.typ) == 0 || untypedRank(els.typ) == 0 {
+					c.errorfmt("mismatched types %s and %s in if expression", format.Type(then.typ), format.Type(els.typ))
+					p.mode = modeInvalid
+					return p
+				}
+				if untypedRank(els.typ) > untypedRank(t) {
+					t = els.typ
+				}
+			}
+			if t == tipe.UntypedNil {
+				c.errorfmt("use of untyped nil in if expression")
+				p.mode = modeInvalid
+				return p
+			}
+			t = defaultType(t)
+			c.constrainUntyped(&then, t)
+			c.constrainUntyped(&els, t)
+		} else {
+			c.constrainUntyped(&then, els.typ)
+			c.constrainUntyped(&els, then.typ)
+		}
+		if _, isTuple := then.typ.(*tipe.Tuple); isTuple || then.typ == nil {
+			c.errorfmt("if expression branch %s is not a single value", format.Expr(e.Then))
+			p.mode = modeInvalid
+			return p
+		}
+		if !tipe.Equal(then.typ, els.typ) {
+			c.errorfmt("mismatched types %s and %s in if expression", format.Type(then.typ), format.Type(els.typ))
+			p.mode = modeInvalid
+			return p
+		}
+		p.mode = modeVar
+		p.typ = then.typ
+		return p
+
 	case *expr.TypeAssert:
 		left := c.expr(e.Left)
 		if left.mode == modeInvalid {
@@ -2336,9 +3169,16 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 		p.mode = modeInvalid
 		return p
 
+	case *expr.Paren:
+		sub := c.exprPartial(e.Expr, hintElideErr)
+		p.mode = sub.mode
+		p.typ = sub.typ
+		p.val = sub.val
+		return p
+
 	case *expr.Unary:
 		switch e.Op {
-		case token.LeftParen, token.Not, token.Sub, token.Add:
+		case token.Not, token.Sub, token.Add:
 			sub := c.exprPartial(e.Expr, hintElideErr)
 			p.mode = sub.mode
 			p.typ = sub.typ
@@ -2347,11 +3187,47 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 				p.val = constant.UnaryOp(gotoken.SUB, sub.val, 0)
 			}
 			return p
+		case token.Xor:
+			sub := c.exprPartial(e.Expr, hintElideErr)
+			if sub.mode == modeInvalid {
+				return sub
+			}
+			var prec uint // bit size of an unsigned operand
+			switch typ := tipe.Underlying(sub.typ); typ {
+			case tipe.UntypedInteger, tipe.Int, tipe.Int8, tipe.Int16, tipe.Int32, tipe.Int64:
+			case tipe.Uint8:
+				prec = 8
+			case tipe.Uint16:
+				prec = 16
+			case tipe.Uint32:
+				prec = 32
+			case tipe.Uint, tipe.Uint64:
+				prec = 64
+			default:
+				c.errorfmt("invalid operation: operator ^ not defined on %s (type %s)", e.Expr, sub.typ)
+				p.mode = modeInvalid
+				return p
+			}
+			p.mode = sub.mode
+			p.typ = sub.typ
+			if sub.val != nil {
+				p.val = constant.UnaryOp(gotoken.XOR, sub.val, prec)
+			}
+			return p
 		case token.Ref:
 			sub := c.expr(e.Expr)
 			if sub.mode == modeInvalid {
 				return p
 			}
+			// Composite literals are not addressable, but
+			// Go allows &T{} to allocate a new value.
+			_, isCall := e.Expr.(*expr.Call)
+			if sub.mode == modeConst || sub.mode == modeTypeExpr || isCall {
+				c.errorfmt("cannot take the address of %s", format.Expr(e.Expr))
+				p.mode = modeInvalid
+				return p
+			}
+			c.forgetTableCols(e.Expr)
 			p.mode = modeVar
 			p.typ = &tipe.Pointer{Elem: sub.typ}
 			return p
@@ -2407,6 +3283,8 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 		case token.Equal, token.NotEqual, token.LessEqual, token.GreaterEqual, token.Less, token.Greater:
 			// comparison
 			lt, rt := left.typ, right.typ
+			_, lIface := tipe.Underlying(lt).(*tipe.Interface)
+			_, rIface := tipe.Underlying(rt).(*tipe.Interface)
 			if !c.assignable(lt, rt) && !c.assignable(rt, lt) {
 				c.errorfmt("incomparable types %s and %s", lt, rt)
 				left.mode = modeInvalid
@@ -2414,6 +3292,18 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 			}
 			switch e.Op {
 			case token.Equal, token.NotEqual:
+				if lIface != rIface && rtOrig != tipe.UntypedNil && ltOrig != tipe.UntypedNil {
+					// Interface compared with a concrete value.
+					concrete := lt
+					if lIface {
+						concrete = rt
+					}
+					if !isComparable(concrete) {
+						c.errorfmt("incomparable type %s", concrete)
+						left.mode = modeInvalid
+						return left
+					}
+				}
 				if !isComparable(lt) {
 					if canBeNil(lt) || canBeNil(rt) {
 						if ltOrig != tipe.UntypedNil && rtOrig != tipe.UntypedNil {
@@ -2434,21 +3324,30 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 					return left
 				}
 			}
+			if left.mode == modeConst && right.mode == modeConst {
+				left.val = constant.MakeBool(constant.Compare(left.val, convGoOp(e.Op), right.val))
+				left.typ = tipe.Bool
+				return left
+			}
+			left.mode = modeVar
+			left.val = nil
 			left.typ = tipe.Bool
 			return left
 		}
 
+		if e.Op == token.TwoLess || e.Op == token.TwoGreater {
+			if !c.checkShift(e, ltOrig, rtOrig, right) {
+				left.mode = modeInvalid
+				return left
+			}
+		}
+
 		// TODO check for division by zero
 		if left.mode == modeConst && right.mode == modeConst {
 			switch e.Op {
-			case token.TwoLess, token.Greater:
-				rhs, ok := big.NewInt(0).SetString(right.val.ExactString(), 0)
-				if !ok {
-					c.errorfmt("constant %s is not an integer", right.val.ExactString())
-					left.mode = modeInvalid
-					return left
-				}
-				left.val = constant.Shift(left.val, convGoOp(e.Op), uint(rhs.Uint64()))
+			case token.TwoLess, token.TwoGreater:
+				n, _ := constant.Uint64Val(constant.ToInt(right.val))
+				left.val = constant.Shift(left.val, convGoOp(e.Op), uint(n))
 			default:
 				left.val = constant.BinaryOp(left.val, convGoOp(e.Op), right.val)
 			}
@@ -2460,61 +3359,6 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 		switch e.Op {
 		case token.TwoLess, token.TwoGreater:
 			c.constrainUntyped(&left, right.typ)
-			// right operand must be an unsigned integer
-			switch typ := rtOrig.(type) {
-			case tipe.Basic:
-				switch typ {
-				case tipe.Uint, tipe.Uint8, tipe.Uint16, tipe.Uint32, tipe.Uint64:
-					// ok
-				case tipe.UntypedInteger:
-					rhs, ok := big.NewInt(0).SetString(right.val.ExactString(), 0)
-					if !ok {
-						c.errorfmt("%s is not an integer", right.val.ExactString())
-						right.mode = modeInvalid
-						return right
-					}
-					if rhs.Sign() < 0 {
-						c.errorfmt("invalid operation: %s is a negative integer", format.Expr(e.Right))
-						right.mode = modeInvalid
-						return right
-					}
-
-				default:
-					c.errorfmt("invalid operation: %s (shift count type %v, must be unsigned integer)",
-						format.Expr(e), format.Type(typ),
-					)
-					right.mode = modeInvalid
-					return right
-				}
-			default:
-				c.errorfmt("invalid operation: %s (shift count type %v, must be unsigned integer)",
-					format.Expr(e), format.Type(typ),
-				)
-				right.mode = modeInvalid
-				return right
-			}
-			// left operand must be an integer
-			switch typ := ltOrig.(type) {
-			case tipe.Basic:
-				switch typ {
-				case tipe.UntypedInteger,
-					tipe.Int, tipe.Int8, tipe.Int16, tipe.Int32, tipe.Int64,
-					tipe.Uint, tipe.Uint8, tipe.Uint16, tipe.Uint32, tipe.Uint64:
-					// ok
-				default:
-					c.errorfmt("invalid operation: %s (shift of type %v)",
-						format.Expr(e), format.Type(typ),
-					)
-					left.mode = modeInvalid
-					return left
-				}
-			default:
-				c.errorfmt("invalid operation: %s (shift of type %v)",
-					format.Expr(e), format.Type(typ),
-				)
-				left.mode = modeInvalid
-				return left
-			}
 		default:
 			if !tipe.Equal(left.typ, right.typ) {
 				c.errorfmt("inoperable types %s and %s", left.typ, right.typ)
@@ -2552,56 +3396,26 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 			return left
 		}
 
-		methodNames, methods := c.memory.Methods(left.typ)
-		for i, name := range methodNames {
-			if name == right {
-				p.mode = modeVar // modeFunc?
-				p.typ = methods[i]
-				return
-			}
+		typ, ambiguous := c.lookupFieldOrMethod(left.typ, right)
+		if ambiguous {
+			p.mode = modeInvalid
+			c.errorfmt("ambiguous selector %s", format.Expr(e))
+			return p
+		}
+		if typ != nil {
+			p.mode = modeVar // modeFunc for methods?
+			p.typ = typ
+			return p
 		}
 
 		lt := tipe.Underlying(left.typ)
 		if t, isPtr := lt.(*tipe.Pointer); isPtr {
 			lt = tipe.Underlying(t.Elem)
 		}
-		var found = false
-		var getField func(typ tipe.Type)
-		getField = func(typ tipe.Type) {
-			if found {
-				return
-			}
-			switch typ := typ.(type) {
-			case *tipe.Struct:
-				for _, sf := range typ.Fields {
-					if sf.Name == right {
-						p.mode = modeVar
-						p.typ = sf.Type
-						found = true
-						return
-					}
-				}
-				// try deeper depths after the shallowest ones have been exhausted.
-				for _, sf := range typ.Fields {
-					if !sf.Embedded {
-						continue
-					}
-					typ := tipe.Underlying(sf.Type)
-					if t, isPtr := typ.(*tipe.Pointer); isPtr {
-						typ = tipe.Underlying(t.Elem)
-					}
-					getField(tipe.Underlying(typ))
-					if found {
-						return
-					}
-				}
-				p.mode = modeInvalid
-				c.errorfmt("%s undefined (type %s has no field or method %s)", e, typ, right)
-			}
-		}
 		switch lt := lt.(type) {
 		case *tipe.Struct:
-			getField(lt)
+			p.mode = modeInvalid
+			c.errorfmt("%s undefined (type %s has no field or method %s)", e, left.typ, right)
 			return p
 		case *tipe.Package:
 			for name, t := range lt.Exports {
@@ -2631,7 +3445,58 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 		if left.mode == modeInvalid {
 			return left
 		}
+		if fn, ok := left.typ.(*tipe.Func); ok && len(fn.TypeParams) > 0 {
+			return c.instantiateFunc(e, left, fn)
+		}
+		ints := func(exprs ...expr.Expr) (p partial) {
+			for _, e := range exprs {
+				if e == nil {
+					continue
+				}
+				p := c.expr(e)
+				if p.mode == modeInvalid {
+					return p
+				}
+				c.convert(&p, tipe.Int)
+				if p.mode == modeInvalid {
+					return p
+				}
+			}
+			p.mode = modeVar
+			return p
+		}
 		lt := tipe.Underlying(left.typ)
+		if r, isRange := e.Indicies[0].(*expr.Range); isRange && len(e.Indicies) == 1 {
+			// x[low..high] or x[low..=high]
+			if p := ints(r.Start, r.End); p.mode == modeInvalid {
+				return p
+			}
+			p.mode = modeVar
+			switch lt := lt.(type) {
+			case *tipe.Slice:
+				p.typ = left.typ
+			case tipe.Basic:
+				if lt == tipe.String || lt == tipe.UntypedString {
+					p.typ = left.typ
+				}
+			case *tipe.Array:
+				if !c.addressable(e.Left) {
+					p.mode = modeInvalid
+					c.errorfmt("cannot slice %s (value of type %s is not addressable)", format.Expr(e.Left), format.Type(left.typ))
+					return p
+				}
+				p.typ = &tipe.Slice{Elem: lt.Elem}
+			case *tipe.Pointer:
+				if at, isArray := tipe.Underlying(lt.Elem).(*tipe.Array); isArray {
+					p.typ = &tipe.Slice{Elem: at.Elem}
+				}
+			}
+			if p.typ == nil {
+				p.mode = modeInvalid
+				c.errorfmt("cannot slice %s (type %s)", format.Expr(e.Left), format.Type(left.typ))
+			}
+			return p
+		}
 		switch lt := lt.(type) {
 		case *tipe.Map:
 			if len(e.Indicies) != 1 {
@@ -2679,25 +3544,13 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 				return p
 			}
 			if s, isSlice := e.Indicies[0].(*expr.Slice); isSlice {
-				p.mode = modeVar
-				p.typ = left.typ
-				ints := func(exprs ...expr.Expr) (p partial) {
-					for _, e := range exprs {
-						if e == nil {
-							continue
-						}
-						p := c.expr(e)
-						if p.mode == modeInvalid {
-							return p
-						}
-						c.convert(&p, tipe.Int)
-						if p.mode == modeInvalid {
-							return p
-						}
-					}
-					p.mode = modeVar
+				if _, isBasic := lt.(tipe.Basic); isBasic && s.Max != nil {
+					p.mode = modeInvalid
+					c.errorfmt("invalid operation %s (3-index slice of string)", e)
 					return p
 				}
+				p.mode = modeVar
+				p.typ = left.typ
 				if p := ints(s.Low, s.High, s.Max); p.mode == modeInvalid {
 					return p
 				}
@@ -2720,8 +3573,44 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 			}
 			return p
 		case *tipe.Table:
-			p.mode = modeInvalid
-			c.errorfmt("TODO table slicing support")
+			// A table is two-dimensional, indexed by row then column.
+			if len(e.Indicies) != 2 {
+				p.mode = modeInvalid
+				c.errorfmt("cannot index %s (type %s) with %d indices, table requires 2", e.Left, left.typ, len(e.Indicies))
+				return p
+			}
+			slices := 0
+			for _, ind := range e.Indicies {
+				var exprs []expr.Expr
+				if s, isSlice := ind.(*expr.Slice); isSlice {
+					slices++
+					exprs = []expr.Expr{s.Low, s.High, s.Max}
+				} else {
+					exprs = []expr.Expr{ind}
+				}
+				for _, e := range exprs {
+					if e == nil {
+						continue
+					}
+					ip := c.expr(e)
+					if ip.mode == modeInvalid {
+						return ip
+					}
+					c.convert(&ip, tipe.Int)
+					if ip.mode == modeInvalid {
+						return ip
+					}
+				}
+			}
+			p.mode = modeVar
+			switch slices {
+			case 0: // m[i, j]
+				p.typ = lt.Type
+			case 1: // m[i, :] or m[:, j]
+				p.typ = &tipe.Slice{Elem: lt.Type}
+			case 2: // m[:, :]
+				p.typ = left.typ
+			}
 			return p
 		default:
 			p.mode = modeInvalid
@@ -2751,6 +3640,48 @@ func (c *Checker) exprPartial(e expr.Expr, hint typeHint) (p partial) {
 		}
 
 		panic(fmt.Sprintf("typecheck.expr TODO Index: %s", format.Debug(e))) //, format.Debug(tipe.Underlying(left.typ))))
+	case *expr.TableIndex:
+		left := c.expr(e.Expr)
+		if left.mode == modeInvalid {
+			return left
+		}
+		if _, isTable := tipe.Underlying(left.typ).(*tipe.Table); !isTable {
+			p.mode = modeInvalid
+			c.errorfmt("cannot select columns of %s (type %s)", format.Expr(e.Expr), format.Type(left.typ))
+			return p
+		}
+		for _, e := range []expr.Expr{e.Rows.Start, e.Rows.End, e.Rows.Exact} {
+			if e == nil {
+				continue
+			}
+			ip := c.expr(e)
+			if ip.mode == modeInvalid {
+				return ip
+			}
+			c.convert(&ip, tipe.Int)
+			if ip.mode == modeInvalid {
+				return ip
+			}
+		}
+		if cols, known := c.tableColNames(e.Expr); known {
+			for _, name := range e.ColNames {
+				found := false
+				for _, col := range cols {
+					if col == name {
+						found = true
+						break
+					}
+				}
+				if !found {
+					p.mode = modeInvalid
+					c.errorfmt("unknown column %q in %s", name, format.Expr(e.Expr))
+					return p
+				}
+			}
+		}
+		p.mode = modeVar
+		p.typ = left.typ
+		return p
 	case *expr.Shell:
 		c.pushScope()
 		defer c.popScope()
@@ -2956,6 +3887,11 @@ func (c *Checker) shell(cmd expr.Expr) {
 			defer c.popScope()
 			c.shell(cmd.Subshell)
 		}
+		if cmd.FuncDef != nil {
+			c.pushScope()
+			defer c.popScope()
+			c.shell(cmd.FuncDef.Body)
+		}
 	case *expr.ShellSimpleCmd:
 		if len(cmd.Args) > 0 {
 			if cmd.Args[0] == "export" {
@@ -2985,13 +3921,33 @@ func (c *Checker) shell(cmd expr.Expr) {
 			})
 		}
 
-		params, err := shell.Parameters(cmd.Args)
+		words := cmd.Args
+		for _, r := range cmd.Redirect {
+			if r.Token == token.ThreeLess {
+				words = append(words[:len(words):len(words)], r.Filename)
+			}
+			if r.Token == token.TwoLess && shell.HeredocExpanded(r.Filename) {
+				words = append(words[:len(words):len(words)], shell.HeredocExpansions(r.Heredoc)...)
+			}
+		}
+		params, err := shell.Parameters(words)
 		if err != nil {
 			c.errorfmt("%v", err)
 		}
 		for _, name := range params {
 			c.cur.LookupRec(name) // foundInParent
 		}
+		for _, sub := range cmd.CmdSubst {
+			c.shell(sub.Cmd)
+		}
+		for _, arith := range cmd.Arith {
+			syntax.Walk(arith.Expr, func(cur *syntax.Cursor) bool {
+				if ident, ok := cur.Node.(*expr.Ident); ok {
+					c.cur.LookupRec(ident.Name) // foundInParent
+				}
+				return true
+			}, nil)
+		}
 	}
 }
 
@@ -3154,6 +4110,142 @@ func (c *Checker) assignable(dst, src tipe.Type) bool {
 	return false
 }
 
+// canAssignTo reports whether e can be assigned to, that is,
+// whether e is addressable or a map index expression.
+func (c *Checker) canAssignTo(e expr.Expr) bool {
+	switch e := e.(type) {
+	case *expr.Paren:
+		return c.canAssignTo(e.Expr)
+	case *expr.Index:
+		if _, isMap := tipe.Underlying(c.types[e.Left]).(*tipe.Map); isMap {
+			return true
+		}
+	}
+	return c.addressable(e)
+}
+
+// addressable reports whether e is addressable. Map index
+// expressions are not, so a field of a struct held in a map,
+// m[k].Field, cannot be assigned to.
+func (c *Checker) addressable(e expr.Expr) bool {
+	switch e := e.(type) {
+	case *expr.Ident:
+		obj := c.idents[e]
+		return obj == nil || obj.Kind == ObjVar
+	case *expr.Paren:
+		return c.addressable(e.Expr)
+	case *expr.Unary:
+		return e.Op == token.Mul
+	case *expr.Index:
+		switch tipe.Underlying(c.types[e.Left]).(type) {
+		case *tipe.Array:
+			return c.addressable(e.Left)
+		case tipe.Basic:
+			return false // strings are immutable
+		case *tipe.Map:
+			return false
+		}
+		return true
+	case *expr.Selector:
+		if _, isStruct := tipe.Underlying(c.types[e.Left]).(*tipe.Struct); isStruct {
+			return c.addressable(e.Left)
+		}
+		return true
+	case *expr.Call, *expr.Binary, *expr.BasicLiteral, *expr.FuncLiteral,
+		*expr.CompLiteral, *expr.MapLiteral, *expr.ArrayLiteral, *expr.SliceLiteral,
+		*expr.IfExpr:
+		return false
+	}
+	return true
+}
+
+// tableColNames returns the column names of the table value of e,
+// if they can be determined before evaluation.
+//
+// It is a best-effort check. A variable keeps the column names of
+// the table literal it is declared with until it is assigned to or
+// has its address taken. The checker does not follow control flow,
+// so it cannot always tell, and the evaluator checks every column
+// name again.
+func (c *Checker) tableColNames(e expr.Expr) (cols []string, known bool) {
+	switch e := e.(type) {
+	case *expr.Paren:
+		return c.tableColNames(e.Expr)
+	case *expr.Ident:
+		cols, known = c.tableCols[c.idents[e]]
+		return cols, known
+	case *expr.TableIndex:
+		return e.ColNames, true
+	case *expr.TableLiteral:
+		for _, colName := range e.ColNames {
+			lit, isLit := colName.(*expr.BasicLiteral)
+			if !isLit {
+				return nil, false
+			}
+			name, isString := lit.Value.(string)
+			if !isString {
+				return nil, false
+			}
+			cols = append(cols, name)
+		}
+		return cols, true
+	}
+	return nil, false
+}
+
+// forgetTableCols discards the known column names of a table
+// variable that is assigned to or has its address taken.
+func (c *Checker) forgetTableCols(e expr.Expr) {
+	switch e := e.(type) {
+	case *expr.Paren:
+		c.forgetTableCols(e.Expr)
+	case *expr.Ident:
+		delete(c.tableCols, c.idents[e])
+	}
+}
+
+// containsValue reports whether a value of type t holds a value of
+// the named type n, as a struct field or array element, directly or
+// through other named types. A type that contains itself this way
+// has infinite size. Pointers, slices, maps and the like break the
+// cycle, as they refer to other values.
+func containsValue(t tipe.Type, n *tipe.Named) bool {
+	return containsValueSeen(t, n, make(map[*tipe.Named]bool))
+}
+
+func containsValueSeen(t tipe.Type, n *tipe.Named, seen map[*tipe.Named]bool) bool {
+	switch t := t.(type) {
+	case *tipe.Named:
+		if t == n {
+			return true
+		}
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		return containsValueSeen(t.Type, n, seen)
+	case *tipe.Struct:
+		for _, f := range t.Fields {
+			if containsValueSeen(f.Type, n, seen) {
+				return true
+			}
+		}
+	case *tipe.Array:
+		return containsValueSeen(t.Elem, n, seen)
+	}
+	return false
+}
+
+func isInteger(t tipe.Type) bool {
+	switch tipe.Underlying(t) {
+	case tipe.Int, tipe.Int8, tipe.Int16, tipe.Int32, tipe.Int64,
+		tipe.Uint, tipe.Uint8, tipe.Uint16, tipe.Uint32, tipe.Uint64, tipe.Uintptr,
+		tipe.UntypedInteger, tipe.UntypedRune:
+		return true
+	}
+	return false
+}
+
 func isString(t tipe.Type) bool {
 	t = tipe.Underlying(t)
 	return t == tipe.String || t == tipe.UntypedString
@@ -3283,6 +4375,61 @@ func (c *Checker) typeAssert(iface *tipe.Interface, t tipe.Type) bool {
 	return true
 }
 
+// lookupFieldOrMethod finds the field or method with name in type t.
+//
+// Embedded fields are searched breadth-first, following the Go spec:
+// a selector at a shallower depth shadows any at a deeper depth, and
+// if more than one field or method is found at the shallowest depth
+// the selector is ambiguous. That includes the same embedded type
+// reached by two paths of equal depth.
+func (c *Checker) lookupFieldOrMethod(t tipe.Type, name string) (typ tipe.Type, ambiguous bool) {
+	seen := make(map[tipe.Type]bool) // types searched at a shallower depth
+	current := []tipe.Type{t}
+	for len(current) > 0 {
+		var found []tipe.Type
+		var next []tipe.Type
+		for _, t := range current {
+			if seen[t] {
+				continue
+			}
+
+			methodNames, methods := c.memory.Methods(t)
+			for i, mname := range methodNames {
+				if mname == name {
+					found = append(found, methods[i])
+				}
+			}
+
+			st := tipe.Underlying(t)
+			if tp, isPtr := st.(*tipe.Pointer); isPtr {
+				st = tipe.Underlying(tp.Elem)
+			}
+			if st, isStruct := st.(*tipe.Struct); isStruct {
+				for _, sf := range st.Fields {
+					if sf.Name == name {
+						found = append(found, sf.Type)
+					}
+					if sf.Embedded {
+						next = append(next, sf.Type)
+					}
+				}
+			}
+		}
+		for _, t := range current {
+			seen[t] = true
+		}
+		switch len(found) {
+		case 0:
+			current = next
+		case 1:
+			return found[0], false
+		default:
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
 // findMember finds the field or method with name in type t.
 //
 // TODO: there is a lot to do here re: embedding. We have to think
@@ -3354,6 +4501,16 @@ func (c *Checker) Type(e expr.Expr) (t tipe.Type) {
 	return t
 }
 
+// Inferred reports the signature of the generic function called by
+// e, instantiated with type arguments inferred from the arguments.
+// It is nil if e is not a call of a generic function.
+func (c *Checker) Inferred(e *expr.Call) *tipe.Func {
+	c.mu.Lock()
+	fn := c.inferred[e]
+	c.mu.Unlock()
+	return fn
+}
+
 // Ident reports the object an identifier refers to.
 func (c *Checker) Ident(e *expr.Ident) *Obj {
 	c.mu.Lock()
@@ -3407,13 +4564,30 @@ func convGoOp(op token.Token) gotoken.Token {
 		return gotoken.QUO // TODO: QUO_ASSIGN for int div
 	case token.Rem:
 		return gotoken.REM
-	case token.Pow:
-		panic("TODO token.Pow")
-		return gotoken.REM
+	case token.Xor:
+		return gotoken.XOR
 	case token.LogicalAnd:
 		return gotoken.LAND
 	case token.LogicalOr:
 		return gotoken.LOR
+	case token.Ref:
+		return gotoken.AND
+	case token.Pipe:
+		return gotoken.OR
+	case token.RefPow:
+		return gotoken.AND_NOT
+	case token.Equal:
+		return gotoken.EQL
+	case token.NotEqual:
+		return gotoken.NEQ
+	case token.Less:
+		return gotoken.LSS
+	case token.LessEqual:
+		return gotoken.LEQ
+	case token.Greater:
+		return gotoken.GTR
+	case token.GreaterEqual:
+		return gotoken.GEQ
 	case token.TwoLess:
 		return gotoken.SHL
 	case token.TwoGreater:
@@ -3591,9 +4765,35 @@ func round(v constant.Value, t tipe.Basic) constant.Value {
 func (c *Checker) Add(s stmt.Stmt) tipe.Type {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.addLabels(s)
 	return c.stmt(s, nil, nil)
 }
 
+// addLabels records the labels defined in s, outside of any function
+// literal, as labels of the current function body, so a goto can jump
+// forward to them. Only the first definition of a label is recorded,
+// checking a later one reports it as a duplicate.
+func (c *Checker) addLabels(s stmt.Stmt) {
+	syntax.Walk(s, func(cur *syntax.Cursor) bool {
+		switch n := cur.Node.(type) {
+		case *expr.FuncLiteral:
+			return false
+		case *stmt.Labeled:
+			if c.funcLabels == nil {
+				c.funcLabels = make(map[string]*stmt.Labeled)
+			}
+			if c.funcLabels[n.Label] == nil {
+				c.funcLabels[n.Label] = n
+			}
+		}
+		return true
+	}, nil)
+}
+
+func (c *Checker) popBreakable() {
+	c.breakable = c.breakable[:len(c.breakable)-1]
+}
+
 func (c *Checker) Lookup(name string) *Obj {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -3776,6 +4976,23 @@ func canBeNil(t tipe.Type) bool {
 	}
 }
 
+// untypedRank orders the untyped numeric constant types so that
+// a constant can be promoted to one of higher rank. It is 0 for
+// other types.
+func untypedRank(t tipe.Type) int {
+	switch t {
+	case tipe.UntypedInteger:
+		return 1
+	case tipe.UntypedRune:
+		return 2
+	case tipe.UntypedFloat:
+		return 3
+	case tipe.UntypedComplex:
+		return 4
+	}
+	return 0
+}
+
 func defaultType(t tipe.Type) tipe.Type {
 	b, ok := t.(tipe.Basic)
 	if !ok {
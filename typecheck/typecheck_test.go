@@ -5,6 +5,7 @@
 package typecheck
 
 import (
+	"strings"
 	"testing"
 
 	"neugram.io/ng/format"
@@ -100,6 +101,40 @@ var typeTests = []typeTest{
 		},
 		[]identType{{"a", &tipe.Table{tipe.Int64}}},
 	},
+	{
+		[]string{
+			`m := [|]int64{{1, 2}, {3, 4}}`,
+			`x := m[1, 0]`,
+			`y := m[1:2, :]`,
+			`z := m[:, 1]`,
+		},
+		[]identType{
+			{"x", tipe.Int64},
+			{"y", &tipe.Table{Type: tipe.Int64}},
+			{"z", &tipe.Slice{Elem: tipe.Int64}},
+		},
+	},
+	{
+		[]string{
+			`m := [|]int64{{|"a", "b"|}, {1, 2}, {3, 4}}`,
+			`x := m["a"|"b"]`,
+			`y := m["b", 1:]`,
+		},
+		[]identType{
+			{"x", &tipe.Table{Type: tipe.Int64}},
+			{"y", &tipe.Table{Type: tipe.Int64}},
+		},
+	},
+	{
+		[]string{
+			`m := [|]int64{{|"a"|}, {1}}`,
+			`m = [|]int64{{|"c"|}, {2}}`,
+			`x := m["c", 0]`,
+		},
+		[]identType{
+			{"x", &tipe.Table{Type: tipe.Int64}},
+		},
+	},
 	{
 		[]string{
 			`methodik A struct{ X int64 } {
@@ -119,6 +154,13 @@ var typeTests = []typeTest{
 		},
 		[]identType{{"err", Universe.Objs["error"].Type}},
 	},
+	{
+		[]string{
+			`b := []byte("x")`,
+			`c := append(b, "abc"...)`,
+		},
+		[]identType{{"c", &tipe.Slice{Elem: tipe.Byte}}},
+	},
 	{
 		[]string{"x := int32(int64(16))"},
 		[]identType{{"x", tipe.Int32}},
@@ -139,11 +181,13 @@ var typeTests = []typeTest{
 			`x := []int64{1,2}`,
 			`y := x[0]`,
 			`z := x[0:1]`,
+			`w := x[0:1:2]`,
 		},
 		[]identType{
 			{"x", &tipe.Slice{Elem: tipe.Int64}},
 			{"y", tipe.Int64},
 			{"z", &tipe.Slice{Elem: tipe.Int64}},
+			{"w", &tipe.Slice{Elem: tipe.Int64}},
 		},
 	},
 	{
@@ -187,6 +231,374 @@ var typeTests = []typeTest{
 			{"m", tipe.Int64},
 		},
 	},
+	{
+		[]string{
+			`type I interface { M() int64 }`,
+			`type J interface { I; N() }`,
+			`methodik T struct{ X int64 } {
+				func (t) M() int64 { return t.X }
+			}`,
+			`i := I(nil)`,
+			`j := J(nil)`,
+			`t := T{1}`,
+			`b1 := i == j`,
+			`b2 := i == t`,
+			`b3 := t != i`,
+		},
+		[]identType{
+			{"b1", tipe.Bool},
+			{"b2", tipe.Bool},
+			{"b3", tipe.Bool},
+		},
+	},
+	{
+		[]string{
+			`methodik E struct{ Msg string } {
+				func (e) Error() string { return e.Msg }
+			}`,
+			`err := error(nil)`,
+			`err = E{"bad"}`,
+			`err2 := error(E{"worse"})`,
+			`err3 := errorf("%d", 3)`,
+		},
+		[]identType{
+			{"err", Universe.Objs["error"].Type},
+			{"err2", Universe.Objs["error"].Type},
+			{"err3", Universe.Objs["error"].Type},
+		},
+	},
+	{
+		[]string{
+			`type Byte = uint8`,
+			`b := Byte(1)`,
+			`var u uint8 = b`,
+		},
+		[]identType{
+			{"b", tipe.Uint8},
+			{"u", tipe.Uint8},
+		},
+	},
+	{
+		[]string{
+			`type Point struct { X, Y int }`,
+			`p := &Point{X: 1}`,
+			`q := &[]int{1, 2}`,
+		},
+		[]identType{
+			{"p", &tipe.Pointer{Elem: &tipe.Named{
+				Name: "Point",
+				Type: &tipe.Struct{Fields: []tipe.StructField{
+					{Name: "X", Type: tipe.Int},
+					{Name: "Y", Type: tipe.Int},
+				}},
+			}}},
+			{"q", &tipe.Pointer{Elem: &tipe.Slice{Elem: tipe.Int}}},
+		},
+	},
+	{
+		[]string{
+			`type Reader interface { Read(p []byte) (int, error) }`,
+			`type ReadFlusher interface {
+				Reader
+				Flush() error
+			}`,
+			`rf := ReadFlusher(nil)`,
+			`r := Reader(rf)`,
+			`n, err := rf.Read(nil)`,
+		},
+		[]identType{
+			{"rf", &tipe.Named{
+				Name: "ReadFlusher",
+				Type: &tipe.Interface{Methods: map[string]*tipe.Func{
+					"Read": {
+						Params: &tipe.Tuple{Elems: []tipe.Type{
+							&tipe.Slice{Elem: tipe.Byte},
+						}},
+						Results: &tipe.Tuple{Elems: []tipe.Type{
+							tipe.Int, Universe.Objs["error"].Type,
+						}},
+					},
+					"Flush": {
+						Params: &tipe.Tuple{},
+						Results: &tipe.Tuple{Elems: []tipe.Type{
+							Universe.Objs["error"].Type,
+						}},
+					},
+				}},
+			}},
+			{"n", tipe.Int},
+		},
+	},
+	{
+		[]string{
+			`x := 1`,
+			`y := 0`,
+			`switch x {
+			case 1, 2:
+				y = 1
+			case 3, x + 1:
+				y = 2
+			default:
+				y = 3
+			}`,
+		},
+		[]identType{
+			{"y", tipe.Int},
+		},
+	},
+	{
+		[]string{
+			`const A, B = 1, 2`,
+			`ok := A != 0 || B < 1`,
+		},
+		[]identType{
+			{"ok", tipe.Bool},
+		},
+	},
+	{
+		[]string{
+			`a := [3]int{}`,
+			`const n = len(a)`,
+			`var b [len(a)]int`,
+			`var c [cap(b) * 2]string`,
+			`s := []int{1}`,
+			`m := len(s)`,
+		},
+		[]identType{
+			{"n", tipe.Int},
+			{"b", &tipe.Array{Len: 3, Elem: tipe.Int}},
+			{"c", &tipe.Array{Len: 6, Elem: tipe.String}},
+			{"m", tipe.Int},
+		},
+	},
+	{
+		[]string{
+			`x := 1 << 3`,
+			`var b [1 << 2]int`,
+			`var u uint = 2`,
+			`var i int64 = 1`,
+			`y := i << u`,
+		},
+		[]identType{
+			{"x", tipe.Int},
+			{"b", &tipe.Array{Len: 4, Elem: tipe.Int}},
+			{"y", tipe.Int64},
+		},
+	},
+	{
+		[]string{
+			`type Count uint`,
+			`x := 1 << Count(2)`,
+			`var n Count = 3`,
+			`var i int64 = 1`,
+			`y := i << n`,
+		},
+		[]identType{
+			{"x", tipe.Int},
+			{"y", tipe.Int64},
+		},
+	},
+	{
+		[]string{
+			`m := map[string]int{}`,
+			`m["k"] = 1`,
+			`s := []int{1}`,
+			`s[0] = 2`,
+			`[]int{1}[0] = 3`,
+			`var a [2]int`,
+			`a[1] = 4`,
+			`p := &a[0]`,
+			`*p = 5`,
+			`(a[0]) = 6`,
+			`type T struct{ X int }`,
+			`var t T`,
+			`t.X = 7`,
+			`pt := &t`,
+			`pt.X = 8`,
+			`x := a[0]`,
+		},
+		[]identType{
+			{"x", tipe.Int},
+		},
+	},
+	{
+		[]string{
+			"func F[T any](x T) T { return x }",
+			"x := F[int](3)",
+			`y := F[string]("s")`,
+		},
+		[]identType{
+			{"x", tipe.Int},
+			{"y", tipe.String},
+		},
+	},
+	{
+		[]string{
+			"func F[T any](x T) T { return x }",
+			"x := F(3)",
+			"var i8 int8",
+			"y := F(i8)",
+			"z := F([]string{})",
+		},
+		[]identType{
+			{"x", tipe.Int},
+			{"y", tipe.Int8},
+			{"z", &tipe.Slice{Elem: tipe.String}},
+		},
+	},
+	{
+		[]string{
+			"func Swap[A, B any](a A, b B) (B, A) { return b, a }",
+			"func Get[K, V any](m map[K]V, k K, def V) V { return def }",
+			`b, a := Swap(1, "b")`,
+			"var i64 int64",
+			`v := Get(map[string]float32{}, "k", 0)`,
+			"w := Get(map[int]int64{}, 1, i64)",
+			"func Last[T any](xs ...T) T { return xs[0] }",
+			`l := Last("a", "b")`,
+		},
+		[]identType{
+			{"a", tipe.Int},
+			{"b", tipe.String},
+			{"v", tipe.Float32},
+			{"w", tipe.Int64},
+			{"l", tipe.String},
+		},
+	},
+	{
+		[]string{
+			"type Pair[K, V any] struct { Key K; Val V }",
+			`p := Pair[string, int]{Key: "a", Val: 1}`,
+			"k := p.Key",
+			"v := p.Val",
+		},
+		[]identType{
+			{"k", tipe.String},
+			{"v", tipe.Int},
+		},
+	},
+	{
+		[]string{
+			"func G[T ~int | ~string](x T) T { return x }",
+			"x := G[int](1)",
+		},
+		[]identType{{"x", tipe.Int}},
+	},
+	{
+		[]string{
+			"type Number interface { ~int | ~float64 }",
+			"type MyInt int",
+			"func G[T Number](x T) T { return x }",
+			"func H[T ~int](x T) T { return G(x) }",
+			"x := G(MyInt(1))",
+			"y := G(2.5)",
+			"z := H(3)",
+		},
+		[]identType{
+			{"x", &tipe.Named{Name: "MyInt", Type: tipe.Int}},
+			{"y", tipe.Float64},
+			{"z", tipe.Int},
+		},
+	},
+	{
+		[]string{
+			"type MyInt int",
+			"func G[T ~int](x T) T { return x }",
+			"func H[T int | ~int](x T) T { return G(x) }",
+			"x := G[MyInt](1)",
+			"y := G(MyInt(2))",
+			"z := H(3)",
+		},
+		[]identType{
+			{"x", &tipe.Named{Name: "MyInt", Type: tipe.Int}},
+			{"y", &tipe.Named{Name: "MyInt", Type: tipe.Int}},
+			{"z", tipe.Int},
+		},
+	},
+	{
+		[]string{"var (\n\ta int\n\tb string\n\tc []byte\n)"},
+		[]identType{
+			{"a", tipe.Int},
+			{"b", tipe.String},
+			{"c", &tipe.Slice{Elem: tipe.Byte}},
+		},
+	},
+	{
+		[]string{
+			"type T struct{ X int }",
+			"func f() *T { return &T{} }",
+			"f().X = 1",
+			"m := map[string]*T{}",
+			`m["a"] = &T{}`,
+			`m["a"].X = 2`,
+			`(m["b"]) = nil`,
+			"a := [][2]int{[2]int{1, 2}}",
+			"a[0][1] = 3",
+			"p := &a[0][0]",
+			"*p = 4",
+		},
+		[]identType{
+			{"m", &tipe.Map{Key: tipe.String, Value: &tipe.Pointer{Elem: &tipe.Named{Name: "T", Type: &tipe.Struct{Fields: []tipe.StructField{{Name: "X", Type: tipe.Int}}}}}}},
+			{"p", &tipe.Pointer{Elem: tipe.Int}},
+		},
+	},
+	{
+		// A goto may jump forward to a label of its function body.
+		[]string{
+			"func f() int {\n\tgoto M\nM:\n\tfor {\n\t\tswitch {\n\t\tdefault:\n\t\t\tbreak\n\t\t}\n\t\tbreak\n\t}\n\treturn 1\n}",
+			"x := f()",
+		},
+		[]identType{{"x", tipe.Int}},
+	},
+	{
+		// Recursive types are fine when a reference breaks the cycle.
+		[]string{
+			"type L struct{ next *L }",
+			"type S struct{ kids []S }",
+			"type M struct{ m map[string]M }",
+			"type F func(F) F",
+			"type (\n\tA struct{ b *B }\n\tB struct{ a *A }\n)",
+			"type (\n\tC struct{ d []D }\n\tD struct{ c C }\n)",
+			"n := len(S{}.kids) + len(M{}.m) + len(C{}.d)",
+		},
+		[]identType{{"n", tipe.Int}},
+	},
+	{
+		[]string{
+			"methodik V struct{ n int } { func (v) add(x int) int { return v.n + x } }",
+			"v := V{n: 2}",
+			"m := v.add",
+			"x := m(3)",
+		},
+		[]identType{
+			{"m", &tipe.Func{
+				Params:  &tipe.Tuple{Elems: []tipe.Type{tipe.Int}},
+				Results: &tipe.Tuple{Elems: []tipe.Type{tipe.Int}},
+			}},
+			{"x", tipe.Int},
+		},
+	},
+	{
+		[]string{
+			"c := true",
+			`s := if c { "a" } else { "b" }`,
+			"f := if c { 1 } else if !c { 2.5 } else { 3 }",
+			"var e error",
+			"err := if c { nil } else { e }",
+		},
+		[]identType{
+			{"s", tipe.String},
+			{"f", tipe.Float64},
+			{"err", Universe.Objs["error"].Type},
+		},
+	},
+	{
+		[]string{
+			"func fact(n int) int { if n <= 1 { return 1 }; return n * fact(n-1) }",
+			"x := fact(5)",
+		},
+		[]identType{{"x", tipe.Int}},
+	},
 }
 
 func TestBasic(t *testing.T) {
@@ -225,3 +637,306 @@ func TestBasic(t *testing.T) {
 		}
 	}
 }
+
+var typeErrTests = []struct {
+	stmts []string
+	err   string
+}{
+	{
+		[]string{
+			`m := [|]int64{{1, 2}, {3, 4}}`,
+			`x := m[1]`,
+		},
+		"table requires 2",
+	},
+	{
+		[]string{
+			`m := [|]int64{{1, 2}, {3, 4}}`,
+			`x := m[0, 1, 2]`,
+		},
+		"table requires 2",
+	},
+	{
+		[]string{
+			`s := "abc"`,
+			`t := s[0:1:2]`,
+		},
+		"3-index slice of string",
+	},
+	{
+		[]string{
+			`t := [2]int{1, 2}[0..1]`,
+		},
+		"not addressable",
+	},
+	{
+		[]string{
+			`m := map[int]int{}`,
+			`t := m[0..1]`,
+		},
+		"cannot slice m",
+	},
+	{
+		[]string{
+			`type I interface { M() int64 }`,
+			`i := I(nil)`,
+			`s := "x"`,
+			`b := i == s`,
+		},
+		"incomparable types",
+	},
+	{
+		[]string{
+			`type I interface { M() int64 }`,
+			`type J interface { N() }`,
+			`i := I(nil)`,
+			`j := J(nil)`,
+			`b := i == j`,
+		},
+		"incomparable types I and J",
+	},
+	{
+		[]string{
+			`e := interface{}(nil)`,
+			`b := e == []int{1}`,
+		},
+		"incomparable type []int",
+	},
+	{
+		[]string{
+			`methodik N struct{ X int64 } {
+				func (n) String() string { return "n" }
+			}`,
+			`err := error(nil)`,
+			`err = N{1}`,
+		},
+		"cannot assign N to error",
+	},
+	{
+		[]string{
+			`type MyByte uint8`,
+			`m := MyByte(1)`,
+			`var u uint8 = m`,
+		},
+		"cannot use m (type MyByte) as type uint8",
+	},
+	{
+		[]string{`p := &1`},
+		"cannot take the address of 1",
+	},
+	{
+		[]string{
+			`func f() int { return 1 }`,
+			`p := &f()`,
+		},
+		"cannot take the address of f()",
+	},
+	{
+		[]string{
+			`type T struct{}`,
+			`type I interface { T }`,
+		},
+		"interface contains embedded non-interface T",
+	},
+	{
+		[]string{
+			`x := 1`,
+			`switch x {
+			case 1:
+			case 2, 1:
+			}`,
+		},
+		"duplicate case 1 in switch",
+	},
+	{
+		[]string{
+			`s := "a"`,
+			`switch s {
+			case "a":
+			case "b":
+			case "a":
+			}`,
+		},
+		"duplicate case",
+	},
+	{
+		[]string{
+			`const one = 1`,
+			`x := 1`,
+			`switch x {
+			case one:
+			case 2 - 1:
+			}`,
+		},
+		"duplicate case 2-1 in switch (previous case one)",
+	},
+	{
+		[]string{
+			`var b int8`,
+			`switch b {
+			case 1:
+			case 300:
+			}`,
+		},
+		"cannot convert const untyped integer to int8",
+	},
+	{
+		[]string{
+			`x := 2`,
+			`var b [x]int`,
+		},
+		"array length x must be constant",
+	},
+	{
+		[]string{
+			`const m = -1`,
+			`var b [m]int`,
+		},
+		"invalid array length m",
+	},
+	{
+		[]string{
+			`s := []int{1}`,
+			`var b [len(s)]int`,
+		},
+		"array length len(s) must be constant",
+	},
+	{
+		[]string{
+			`s := []int{1, 2}`,
+			`x := s["a"|"b"]`,
+		},
+		"cannot select columns of s",
+	},
+	{
+		[]string{
+			`m := [|]int64{{|"a"|}, {1}}`,
+			`x := m["a", "b":]`,
+		},
+		`constant "b" does not fit in int`,
+	},
+	{
+		[]string{
+			`m := [|]int64{{|"a", "b"|}, {1, 2}}`,
+			`x := m["a"|"c"]`,
+		},
+		`unknown column "c" in m`,
+	},
+	{
+		[]string{
+			`m := [|]int64{{|"a", "b"|}, {1, 2}}`,
+			`x := m["a", 1:]`,
+			`y := x["b", 1:]`,
+		},
+		`unknown column "b" in x`,
+	},
+	{[]string{`for { break L }`}, "invalid break label L"},
+	{[]string{`L: switch { default: continue L }`}, "invalid continue label L"},
+	{[]string{`L: { for { break L } }`}, "invalid break label L"},
+	{[]string{`L: for { L: for {} }`}, "label L already defined"},
+	{[]string{`L: for { f := func() { break L } }`}, "invalid break label L"},
+	{[]string{`L: for {}`, `L: for {}`}, "label L already defined"},
+	{[]string{`func f() { L: for {}; L: switch {} }`}, "label L already defined"},
+	{[]string{`func f() { goto L }`}, "label L not defined"},
+	{[]string{`L: for { f := func() { goto L } }`}, "label L not defined"},
+	{[]string{`break`}, "break is not in a loop, switch, or select"},
+	{[]string{`switch { default: continue }`}, "continue is not in a loop"},
+	{[]string{`for { f := func() { continue } }`}, "continue is not in a loop"},
+	{[]string{`for i := range 1.5 {}`}, "cannot range over 1.5"},
+	{[]string{`for i, j := range 3 {}`}, "permits only one iteration variable"},
+	{[]string{`func f() int { return 0 }`, `f() = 3`}, "cannot assign to f()"},
+	{[]string{`const c = 1`, `c = 2`}, "cannot assign to c"},
+	{[]string{`[2]int{}[0] = 3`}, "cannot assign to [2]int{}[0]"},
+	{[]string{`s := "str"`, `s[0] = 'x'`}, "cannot assign to s[0]"},
+	{[]string{`type T struct{ X int }`, `func f() T { return T{} }`, `f().X = 1`}, "cannot assign to f().X"},
+	{[]string{`type T struct{ X int }`, `m := map[int]T{}`, `m[0].X = 1`}, "cannot assign to m[0].X"},
+	{[]string{`m := map[int][2]int{}`, `m[0][1] = 1`}, "cannot assign to m[0][1]"},
+	{[]string{`x := 1`, `x + 1 = 2`}, "cannot assign to x+1"},
+	{[]string{`x := 1`, `(x + 1) = 2`}, "cannot assign to (x+1)"},
+	{[]string{`x := 1`, `1 = x`}, "cannot assign to 1"},
+	{[]string{`type Number interface { ~int | ~float64 }`, `func G[T Number](x T) T { return x }`, `G("s")`}, "string does not satisfy Number"},
+	{[]string{`type Number interface { ~int | ~float64 }`, `type Integer interface { Number; int | string }`, `func G[T Integer](x T) T { return x }`, `G(1.5)`}, "float64 does not satisfy Integer"},
+	{[]string{`func F[T any]() T { var x T; return x }`, `F()`}, "in call to F, cannot infer T"},
+	{[]string{`func F[T any](x, y T) {}`, `F(1, 2.5)`}, "default type float64 of 2.5 does not match inferred type int for T"},
+	{[]string{`func F[T any](x, y T) {}`, `var i int`, `s := "s"`, `F(i, s)`}, "type string of s does not match inferred type int for T"},
+	{[]string{`func F[T any](x T, y []T) {}`, `F(1, []string{})`}, "constant 1 does not fit in string"},
+	{[]string{`func F[T any](x T) T { return x }`, `F[int, string](1)`}, "wrong number of type arguments for F: got 2, want 1"},
+	{[]string{`func F[T any](x T) T { return x }`, `s := "s"`, `F[int](s)`}, "cannot convert string to int"},
+	{[]string{`func F[T any](x T) int { return x }`}, "cannot use"},
+	{[]string{`type List[T any] []T`, `var l List`}, "cannot use generic type List without instantiation"},
+	{[]string{`type T int`, `x := T[int]{}`}, "T is not a generic type"},
+	{[]string{`type MyString string`, `func G[T ~int](x T) T { return x }`, `G[MyString]("s")`}, "MyString does not satisfy ~int"},
+	{[]string{`type MyString string`, `func G[T ~int](x T) T { return x }`, `G(MyString("s"))`}, "MyString does not satisfy ~int"},
+	{[]string{`type MyInt int`, `func G[T int | string](x T) T { return x }`, `G(MyInt(1))`}, "MyInt does not satisfy int | string"},
+	{[]string{`func G[T ~int](x T) T { return x }`, `func H[T any](x T) T { return G(x) }`}, "T does not satisfy ~int"},
+	{[]string{`func G[T ~int](x T) T { return x }`, `func H[T ~int | string](x T) T { return G(x) }`}, "T does not satisfy ~int"},
+	{[]string{`x := if true { 1 } else { "a" }`}, "mismatched types untyped integer and untyped string"},
+	{[]string{`var a int`, `var b string`, `x := if true { a } else { b }`}, "mismatched types int and string"},
+	{[]string{`x := if 1 { 2 } else { 3 }`}, "cannot convert const untyped integer to bool"},
+	{[]string{`x := if true { nil } else { nil }`}, "use of untyped nil"},
+	{[]string{`var a, b int`, `(if true { a } else { b }) = 7`}, "cannot assign to (if true"},
+	{[]string{`type X struct{ x X }`}, "invalid recursive type X"},
+	{[]string{`type X struct{ y struct{ a [2]X } }`}, "invalid recursive type X"},
+	{[]string{`type X [1]X`}, "invalid recursive type X"},
+	{[]string{"type (\n\tA struct{ b B }\n\tB struct{ a A }\n)"}, "invalid recursive type A"},
+	{[]string{"type (\n\tA struct{ b [2]B }\n\tB struct{ c C }\n\tC struct{ a A }\n)"}, "invalid recursive type A"},
+	{[]string{`methodik X struct{ x X } {}`}, "invalid recursive type X"},
+	{[]string{`x := 1 << -1`}, "is a negative integer"},
+	{[]string{`x := 1.0 << 2`}, "shift of type untyped float"},
+	{[]string{`var f float64 = 1`, `x := 1 << f`}, "must be unsigned integer"},
+	{[]string{`ints := []int{1, 2}`, `ints = append(ints, "abc"...)`}, "cannot use abc (type untyped string) as type []int in argument to append"},
+}
+
+func TestErrs(t *testing.T) {
+	for i, test := range typeErrTests {
+		c := New("")
+		var errs []error
+		for _, str := range test.stmts {
+			s, err := parser.ParseStmt([]byte(str))
+			if err != nil {
+				t.Fatalf("%d: parser.ParseStmt(%q): %v", i, str, err)
+			}
+			c.Add(s)
+			if errs = c.Errs(); len(errs) > 0 {
+				break
+			}
+		}
+		if len(errs) == 0 {
+			t.Errorf("%d: missing error %q", i, test.err)
+			continue
+		}
+		if got := errs[0].Error(); !strings.Contains(got, test.err) {
+			t.Errorf("%d: error %q does not contain %q", i, got, test.err)
+		}
+	}
+}
+
+func TestExported(t *testing.T) {
+	c := New("")
+	objs, err := c.Exported("strings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var toUpper *Obj
+	for i, obj := range objs {
+		if !isExported(obj.Name) {
+			t.Errorf("unexported object %s", obj.Name)
+		}
+		if i > 0 && objs[i-1].Name >= obj.Name {
+			t.Errorf("object %s follows %s, want objects sorted by name", obj.Name, objs[i-1].Name)
+		}
+		if obj.Name == "ToUpper" {
+			toUpper = obj
+		}
+	}
+	if toUpper == nil {
+		t.Fatal("strings.ToUpper is missing")
+	}
+	want := &tipe.Func{
+		Params:  &tipe.Tuple{Elems: []tipe.Type{tipe.String}},
+		Results: &tipe.Tuple{Elems: []tipe.Type{tipe.String}},
+	}
+	if !tipe.Equal(toUpper.Type, want) {
+		t.Errorf("strings.ToUpper type %s, want %s", format.Type(toUpper.Type), format.Type(want))
+	}
+}